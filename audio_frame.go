@@ -0,0 +1,198 @@
+package astits
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrAudioFrameInvalid          = errors.New("astits: audio frame invalid")
+	ErrAudioStreamTypeUnsupported = errors.New("astits: audio stream type unsupported")
+)
+
+// AudioFrame represents an audio frame found in the PES data of an audio elementary stream
+type AudioFrame struct {
+	Offset     int // Offset of the frame in the PES data
+	SampleRate int
+	Samples    int
+	Size       int
+}
+
+// Duration returns the duration of the frame
+func (f *AudioFrame) Duration() time.Duration {
+	return time.Duration(int64(f.Samples) * int64(time.Second) / int64(f.SampleRate))
+}
+
+// ParseAudioFrames splits the PES data of an audio elementary stream into frames, so that segments can be cut at
+// audio frame boundaries: the PTS of a frame is the PTS of the PES plus the duration of the frames preceding it.
+// ADTS AAC, MPEG audio, AC-3 and E-AC-3 are supported. AC-3 and E-AC-3 streams carried as private data must be
+// parsed with StreamTypeAC3Audio and StreamTypeEAC3Audio.
+func ParseAudioFrames(t StreamType, data []byte) (fs []*AudioFrame, err error) {
+	// Get frame parser
+	var fn func(bs []byte) (*AudioFrame, error)
+	switch t {
+	case StreamTypeAACAudio:
+		fn = parseADTSFrame
+	case StreamTypeMPEG1Audio, StreamTypeMPEG2Audio:
+		fn = parseMPEGAudioFrame
+	case StreamTypeAC3Audio:
+		fn = parseAC3Frame
+	case StreamTypeEAC3Audio:
+		fn = parseEAC3Frame
+	default:
+		err = fmt.Errorf("astits: stream type is %s: %w", t, ErrAudioStreamTypeUnsupported)
+		return
+	}
+
+	// Loop through frames
+	for offset := 0; offset < len(data); {
+		var f *AudioFrame
+		if f, err = fn(data[offset:]); err != nil {
+			err = fmt.Errorf("astits: parsing audio frame at offset %d failed: %w", offset, err)
+			return
+		}
+		if f.Size <= 0 || offset+f.Size > len(data) {
+			err = fmt.Errorf("astits: audio frame at offset %d is %d bytes long but only %d bytes are left: %w", offset, f.Size, len(data)-offset, ErrAudioFrameInvalid)
+			return
+		}
+		f.Offset = offset
+		fs = append(fs, f)
+		offset += f.Size
+	}
+	return
+}
+
+// Chapter: 1.A.2.2.1 | Link: https://www.iso.org/standard/43345.html
+var adtsSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// parseADTSFrame parses the header of an ADTS frame
+func parseADTSFrame(bs []byte) (*AudioFrame, error) {
+	if len(bs) < 7 || bs[0] != 0xff || bs[1]&0xf0 != 0xf0 {
+		return nil, fmt.Errorf("astits: ADTS sync word not found: %w", ErrAudioFrameInvalid)
+	}
+	idx := int(bs[2] >> 2 & 0xf)
+	if idx >= len(adtsSampleRates) {
+		return nil, fmt.Errorf("astits: ADTS sampling frequency index is %d: %w", idx, ErrAudioFrameInvalid)
+	}
+	return &AudioFrame{
+		SampleRate: adtsSampleRates[idx],
+		Samples:    1024 * (int(bs[6]&0x3) + 1), // 1024 samples per raw data block
+		Size:       int(bs[3]&0x3)<<11 | int(bs[4])<<3 | int(bs[5]>>5),
+	}, nil
+}
+
+// MPEG audio bitrates in kbps, indexed by MPEG-1 or not, layer and bitrate index
+// Link: https://www.iso.org/standard/22412.html
+var mpegAudioBitrates = [2][3][15]int{
+	{ // MPEG-2 and MPEG-2.5
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+	{ // MPEG-1
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+}
+
+// MPEG-1 sample rates, halved for MPEG-2 and quartered for MPEG-2.5
+var mpegAudioSampleRates = []int{44100, 48000, 32000}
+
+// parseMPEGAudioFrame parses the header of a MPEG audio frame
+func parseMPEGAudioFrame(bs []byte) (*AudioFrame, error) {
+	if len(bs) < 4 || bs[0] != 0xff || bs[1]&0xe0 != 0xe0 {
+		return nil, fmt.Errorf("astits: MPEG audio sync word not found: %w", ErrAudioFrameInvalid)
+	}
+
+	// Version is 3 for MPEG-1, 2 for MPEG-2 and 0 for MPEG-2.5, layer is 3 for layer I, 2 for layer II and 1 for
+	// layer III
+	version, layer := bs[1]>>3&0x3, bs[1]>>1&0x3
+	bitrateIdx, sampleRateIdx, padding := int(bs[2]>>4), int(bs[2]>>2&0x3), int(bs[2]>>1&0x1)
+	if version == 1 || layer == 0 || bitrateIdx == 0 || bitrateIdx == 0xf || sampleRateIdx == 3 {
+		return nil, fmt.Errorf("astits: MPEG audio header %#x is not supported: %w", bs[:4], ErrAudioFrameInvalid)
+	}
+
+	// Get bitrate and sample rate
+	isMPEG1 := 0
+	if version == 3 {
+		isMPEG1 = 1
+	}
+	bitrate := mpegAudioBitrates[isMPEG1][3-layer][bitrateIdx] * 1000
+	f := &AudioFrame{SampleRate: mpegAudioSampleRates[sampleRateIdx]}
+	switch version {
+	case 2:
+		f.SampleRate /= 2
+	case 0:
+		f.SampleRate /= 4
+	}
+
+	// Get samples and size
+	switch {
+	case layer == 3:
+		f.Samples = 384
+		f.Size = (12*bitrate/f.SampleRate + padding) * 4
+	case layer == 1 && isMPEG1 == 0:
+		f.Samples = 576
+		f.Size = 72*bitrate/f.SampleRate + padding
+	default:
+		f.Samples = 1152
+		f.Size = 144*bitrate/f.SampleRate + padding
+	}
+	return f, nil
+}
+
+// AC-3 bitrates in kbps, indexed by frame size code divided by 2
+// Chapter: 5.4.1.4 | Link: https://www.atsc.org/wp-content/uploads/2015/03/A52-201212-17.pdf
+var ac3Bitrates = []int{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 576, 640}
+
+// parseAC3Frame parses the header of an AC-3 frame, which always holds 1536 samples
+func parseAC3Frame(bs []byte) (*AudioFrame, error) {
+	if len(bs) < 5 || bs[0] != 0x0b || bs[1] != 0x77 {
+		return nil, fmt.Errorf("astits: AC-3 sync word not found: %w", ErrAudioFrameInvalid)
+	}
+	fscod, frmsizecod := bs[4]>>6, int(bs[4]&0x3f)
+	if fscod == 3 || frmsizecod/2 >= len(ac3Bitrates) {
+		return nil, fmt.Errorf("astits: AC-3 sample rate code is %d and frame size code is %d: %w", fscod, frmsizecod, ErrAudioFrameInvalid)
+	}
+
+	// Frame size is a number of 16 bits words
+	bitrate := ac3Bitrates[frmsizecod/2]
+	f := &AudioFrame{Samples: 1536}
+	switch fscod {
+	case 0:
+		f.SampleRate = 48000
+		f.Size = 2 * bitrate * 2
+	case 1:
+		f.SampleRate = 44100
+		f.Size = (bitrate*1000*1536/44100/16 + frmsizecod&0x1) * 2
+	case 2:
+		f.SampleRate = 32000
+		f.Size = 3 * bitrate * 2
+	}
+	return f, nil
+}
+
+// parseEAC3Frame parses the header of an E-AC-3 frame
+// Chapter: E.1.2.2 | Link: https://www.atsc.org/wp-content/uploads/2015/03/A52-201212-17.pdf
+func parseEAC3Frame(bs []byte) (*AudioFrame, error) {
+	if len(bs) < 5 || bs[0] != 0x0b || bs[1] != 0x77 {
+		return nil, fmt.Errorf("astits: E-AC-3 sync word not found: %w", ErrAudioFrameInvalid)
+	}
+	f := &AudioFrame{Size: (int(bs[2]&0x7)<<8 | int(bs[3]) + 1) * 2}
+	fscod, numblkscod := bs[4]>>6, bs[4]>>4&0x3
+	if fscod == 3 {
+		// Reduced sample rates always have 6 blocks
+		fscod2 := numblkscod
+		if fscod2 == 3 {
+			return nil, fmt.Errorf("astits: E-AC-3 reduced sample rate code is %d: %w", fscod2, ErrAudioFrameInvalid)
+		}
+		f.SampleRate = []int{24000, 22050, 16000}[fscod2]
+		f.Samples = 6 * 256
+	} else {
+		f.SampleRate = []int{48000, 44100, 32000}[fscod]
+		f.Samples = []int{1, 2, 3, 6}[numblkscod] * 256
+	}
+	return f, nil
+}
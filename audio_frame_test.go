@@ -0,0 +1,49 @@
+package astits
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func audioFrame(header []byte, size int) []byte {
+	return append(append([]byte{}, header...), bytes.Repeat([]byte{0x0}, size-len(header))...)
+}
+
+func TestParseAudioFrames(t *testing.T) {
+	// ADTS
+	adts := audioFrame([]byte{0xff, 0xf1, 0x4c, 0x80, 0x02, 0x9f, 0xfc}, 20)
+	fs, err := ParseAudioFrames(StreamTypeAACAudio, append(adts, adts...))
+	assert.NoError(t, err)
+	assert.Equal(t, []*AudioFrame{
+		{SampleRate: 48000, Samples: 1024, Size: 20},
+		{Offset: 20, SampleRate: 48000, Samples: 1024, Size: 20},
+	}, fs)
+	assert.Equal(t, 21333333*time.Nanosecond, fs[0].Duration())
+
+	// MPEG-1 layer III at 128kbps
+	fs, err = ParseAudioFrames(StreamTypeMPEG1Audio, audioFrame([]byte{0xff, 0xfb, 0x90, 0x64}, 417))
+	assert.NoError(t, err)
+	assert.Equal(t, []*AudioFrame{{SampleRate: 44100, Samples: 1152, Size: 417}}, fs)
+
+	// AC-3 at 32kbps
+	fs, err = ParseAudioFrames(StreamTypeAC3Audio, audioFrame([]byte{0x0b, 0x77, 0x0, 0x0, 0x40}, 138))
+	assert.NoError(t, err)
+	assert.Equal(t, []*AudioFrame{{SampleRate: 44100, Samples: 1536, Size: 138}}, fs)
+
+	// E-AC-3
+	fs, err = ParseAudioFrames(StreamTypeEAC3Audio, audioFrame([]byte{0x0b, 0x77, 0x0, 0x9, 0x30}, 20))
+	assert.NoError(t, err)
+	assert.Equal(t, []*AudioFrame{{SampleRate: 48000, Samples: 1536, Size: 20}}, fs)
+
+	// Errors
+	_, err = ParseAudioFrames(StreamTypeH264Video, adts)
+	assert.True(t, errors.Is(err, ErrAudioStreamTypeUnsupported))
+	_, err = ParseAudioFrames(StreamTypeAACAudio, adts[:19])
+	assert.True(t, errors.Is(err, ErrAudioFrameInvalid))
+	_, err = ParseAudioFrames(StreamTypeAACAudio, append(adts, 0x0))
+	assert.True(t, errors.Is(err, ErrAudioFrameInvalid))
+}
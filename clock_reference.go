@@ -27,3 +27,78 @@ func (p ClockReference) Duration() time.Duration {
 func (p ClockReference) Time() time.Time {
 	return time.Unix(0, p.Duration().Nanoseconds())
 }
+
+const (
+	ptsFrequency  = 90000
+	ptsWrapAround = pcrBaseMask + 1 // PTS, DTS and PCR bases are 33 bits
+)
+
+// PTSToDuration converts a 90kHz PTS or DTS into a duration, bits above the 33 bits of the field being ignored
+func PTSToDuration(v uint64) time.Duration {
+	return clockToDuration(v%ptsWrapAround, ptsFrequency)
+}
+
+// DurationToPTS converts a duration into a 90kHz PTS or DTS, wrapping around after 2^33 ticks which is about 26.5
+// hours. Negative durations wrap around as well.
+func DurationToPTS(d time.Duration) uint64 {
+	return durationToClock(d, ptsFrequency, ptsWrapAround)
+}
+
+// PCRToDuration converts a 27MHz PCR, which is its base multiplied by 300 plus its extension, into a duration, the PCR
+// wrapping around with its 33 bits base
+func PCRToDuration(v uint64) time.Duration {
+	return clockToDuration(v%pcrWrapAround, clockFrequency)
+}
+
+// DurationToPCR converts a duration into a 27MHz PCR, which is its base multiplied by 300 plus its extension, and
+// wraps around with its 33 bits base. Negative durations wrap around as well.
+func DurationToPCR(d time.Duration) uint64 {
+	return durationToClock(d, clockFrequency, pcrWrapAround)
+}
+
+// clockToDuration converts ticks of a clock running at frequency into a duration without overflowing
+func clockToDuration(v, frequency uint64) time.Duration {
+	return time.Duration(v/frequency)*time.Second + time.Duration(v%frequency*uint64(time.Second)/frequency)
+}
+
+// durationToClock converts a duration into ticks of a clock running at frequency, rounded to the nearest tick so that
+// durations returned by clockToDuration convert back to the same ticks, wrapping around after wrapAround ticks
+func durationToClock(d time.Duration, frequency, wrapAround uint64) uint64 {
+	if d < 0 {
+		return (wrapAround - durationToClock(-d, frequency, wrapAround)) % wrapAround
+	}
+	// Seconds of the longest duration times 27MHz fit in an uint64
+	s, ns := uint64(d/time.Second), uint64(d%time.Second)
+	return (s*frequency + (ns*frequency+uint64(time.Second)/2)/uint64(time.Second)) % wrapAround
+}
+
+// PCRUnwrapper unwraps successive PCRs of a program into a monotonic 27MHz clock that keeps counting past the 2^33
+// wrap around of the PCR base. Its zero value is ready to use.
+type PCRUnwrapper struct {
+	hasLast       bool
+	last          int64
+	lastUnwrapped int64
+}
+
+// Unwrap returns the PCR as a 27MHz value, which is its base multiplied by 300 plus its extension, offset by the wrap
+// arounds seen so far. When discontinuity is true, for instance when the packet carrying the PCR has its
+// discontinuity_indicator set, the clock is rebased so that it continues from the previous unwrapped value.
+func (u *PCRUnwrapper) Unwrap(pcr *ClockReference, discontinuity bool) int64 {
+	v := (pcr.Base*300 + pcr.Extension) % pcrWrapAround
+	switch {
+	case !u.hasLast:
+		u.lastUnwrapped = v
+	case discontinuity:
+		// Keep the last unwrapped value
+	default:
+		// Small backward steps are kept as is, larger ones are wrap arounds
+		if delta := (v - u.last + pcrWrapAround) % pcrWrapAround; delta < pcrWrapAround/2 {
+			u.lastUnwrapped += delta
+		} else {
+			u.lastUnwrapped -= pcrWrapAround - delta
+		}
+	}
+	u.hasLast = true
+	u.last = v
+	return u.lastUnwrapped
+}
@@ -13,3 +13,58 @@ func TestClockReference(t *testing.T) {
 	assert.Equal(t, 36344825768814*time.Nanosecond, clockReference.Duration())
 	assert.Equal(t, int64(36344), clockReference.Time().Unix())
 }
+
+func TestPTSToDuration(t *testing.T) {
+	assert.Equal(t, time.Second, PTSToDuration(90000))
+	assert.Equal(t, uint64(90000), DurationToPTS(time.Second))
+	assert.Equal(t, uint64(1), DurationToPTS(11112*time.Nanosecond))
+
+	// 33 bits wrap around after about 26.5 hours
+	max := PTSToDuration(1<<33 - 1)
+	assert.Equal(t, 95443717677777*time.Nanosecond, max)
+	assert.Equal(t, uint64(1<<33-1), DurationToPTS(max))
+	assert.Equal(t, time.Duration(0), PTSToDuration(1<<33))
+	assert.Equal(t, time.Second, PTSToDuration(1<<33+90000))
+	assert.Equal(t, uint64(0), DurationToPTS(max+11111*time.Nanosecond))
+	assert.Equal(t, uint64(90000), DurationToPTS(max+11111*time.Nanosecond+time.Second))
+	assert.Equal(t, uint64(1<<33-90000), DurationToPTS(-time.Second))
+}
+
+func TestPCRToDuration(t *testing.T) {
+	assert.Equal(t, time.Second, PCRToDuration(27000000))
+	assert.Equal(t, uint64(27000000), DurationToPCR(time.Second))
+	// Extension
+	assert.Equal(t, clockReference.Duration(), PCRToDuration(uint64(clockReference.Base*300+clockReference.Extension)))
+
+	// PCR wraps around with its 33 bits base
+	max := PCRToDuration(1<<33*300 - 1)
+	assert.Equal(t, 95443717688851*time.Nanosecond, max)
+	assert.Equal(t, uint64(1<<33*300-1), DurationToPCR(max))
+	assert.Equal(t, time.Duration(0), PCRToDuration(1<<33*300))
+	assert.Equal(t, uint64(0), DurationToPCR(max+38*time.Nanosecond))
+	assert.Equal(t, uint64(1<<33*300-27000000), DurationToPCR(-time.Second))
+
+	// Longest duration doesn't overflow
+	assert.Less(t, DurationToPCR(time.Duration(1<<63-1)), uint64(1<<33*300))
+}
+
+func TestPCRUnwrapper(t *testing.T) {
+	var u PCRUnwrapper
+	pcr := func(v int64) *ClockReference { return newClockReference(v/300, v%300) }
+
+	// Base and extension wrap around
+	assert.Equal(t, int64(pcrWrapAround-clockFrequency), u.Unwrap(pcr(pcrWrapAround-clockFrequency), false))
+	assert.Equal(t, int64(pcrWrapAround-1), u.Unwrap(pcr(pcrWrapAround-1), false))
+	assert.Equal(t, int64(pcrWrapAround+clockFrequency), u.Unwrap(pcr(clockFrequency), false))
+	assert.Equal(t, int64(pcrWrapAround+pcrWrapAround/2), u.Unwrap(pcr(pcrWrapAround/2), false))
+	assert.Equal(t, int64(2*pcrWrapAround-clockFrequency), u.Unwrap(pcr(pcrWrapAround-clockFrequency), false))
+	assert.Equal(t, int64(2*pcrWrapAround+clockFrequency), u.Unwrap(pcr(clockFrequency), false))
+
+	// Small backward steps are not wrap arounds
+	assert.Equal(t, int64(2*pcrWrapAround), u.Unwrap(pcr(0), false))
+	assert.Equal(t, int64(2*pcrWrapAround+clockFrequency), u.Unwrap(pcr(clockFrequency), false))
+
+	// Discontinuity rebases the clock
+	assert.Equal(t, int64(2*pcrWrapAround+clockFrequency), u.Unwrap(pcr(1<<32*300), true))
+	assert.Equal(t, int64(2*pcrWrapAround+2*clockFrequency), u.Unwrap(pcr(1<<32*300+clockFrequency), false))
+}
@@ -456,6 +456,22 @@ func descriptorToString(d *astits.Descriptor) string {
 		return fmt.Sprintf("[ISO639 language and audio type] language: %s | audio type: %d", d.ISO639LanguageAndAudioType.Language, d.ISO639LanguageAndAudioType.Type)
 	case astits.DescriptorTagMaximumBitrate:
 		return fmt.Sprintf("[Maximum bitrate] maximum bitrate: %d", d.MaximumBitrate.Bitrate)
+	case astits.DescriptorTagMVCExtension:
+		return fmt.Sprintf("[MVC extension] view order index min: %d | view order index max: %d | base view is left eyeview: %v", d.MVCExtension.ViewOrderIndexMin, d.MVCExtension.ViewOrderIndexMax, d.MVCExtension.BaseViewIsLeftEyeview)
+	case astits.DescriptorTagMVCOperationPoint:
+		var os []string
+		for _, l := range d.MVCOperationPoint.Levels {
+			for _, op := range l.OperationPoints {
+				os = append(os, fmt.Sprintf("level: %d | target output views: %d | es references: %v", l.LevelIDC, op.NumTargetOutputViews, op.ESReferences))
+			}
+		}
+		return fmt.Sprintf("[MVC operation point] profile: %d", d.MVCOperationPoint.ProfileIDC) + strings.Join(append([]string{""}, os...), " - ")
+	case astits.DescriptorTagMosaic:
+		var os []string
+		for _, c := range d.Mosaic.LogicalCells {
+			os = append(os, fmt.Sprintf("logical cell: %d | elementary cells: %v | linkage: %d", c.LogicalCellID, c.ElementaryCellIDs, c.CellLinkageInfo))
+		}
+		return fmt.Sprintf("[Mosaic] %dx%d", d.Mosaic.NumberOfHorizontalElementaryCells+1, d.Mosaic.NumberOfVerticalElementaryCells+1) + strings.Join(append([]string{""}, os...), " - ")
 	case astits.DescriptorTagNetworkName:
 		return fmt.Sprintf("[Network name] network name: %s", d.NetworkName.Name)
 	case astits.DescriptorTagParentalRating:
@@ -468,6 +484,10 @@ func descriptorToString(d *astits.Descriptor) string {
 		return fmt.Sprintf("[Private data specifier] specifier: %d", d.PrivateDataSpecifier.Specifier)
 	case astits.DescriptorTagService:
 		return fmt.Sprintf("[Service] service %s | provider: %s", d.Service.Name, d.Service.Provider)
+	case astits.DescriptorTagServiceAvailability:
+		return fmt.Sprintf("[Service availability] available: %v | cells: %v", d.ServiceAvailability.AvailabilityFlag, d.ServiceAvailability.CellIDs)
+	case astits.DescriptorTagServiceMove:
+		return fmt.Sprintf("[Service move] original network id: %d | transport stream id: %d | service id: %d", d.ServiceMove.NewOriginalNetworkID, d.ServiceMove.NewTransportStreamID, d.ServiceMove.NewServiceID)
 	case astits.DescriptorTagShortEvent:
 		return fmt.Sprintf("[Short event] language: %s | name: %s | text: %s", d.ShortEvent.Language, d.ShortEvent.EventName, d.ShortEvent.Text)
 	case astits.DescriptorTagStreamIdentifier:
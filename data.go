@@ -8,23 +8,38 @@ import (
 
 // PIDs
 const (
-	PIDPAT  uint16 = 0x0    // Program Association Table (PAT) contains a directory listing of all Program Map Tables.
-	PIDCAT  uint16 = 0x1    // Conditional Access Table (CAT) contains a directory listing of all ITU-T Rec. H.222 entitlement management message streams used by Program Map Tables.
-	PIDTSDT uint16 = 0x2    // Transport Stream Description Table (TSDT) contains descriptors related to the overall transport stream
-	PIDNull uint16 = 0x1fff // Null Packet (used for fixed bandwidth padding)
+	PIDPAT      uint16 = 0x0    // Program Association Table (PAT) contains a directory listing of all Program Map Tables.
+	PIDCAT      uint16 = 0x1    // Conditional Access Table (CAT) contains a directory listing of all ITU-T Rec. H.222 entitlement management message streams used by Program Map Tables.
+	PIDTSDT     uint16 = 0x2    // Transport Stream Description Table (TSDT) contains descriptors related to the overall transport stream
+	PIDNIT      uint16 = 0x10   // Network Information Table (NIT) contains information about the physical network
+	PIDSDT      uint16 = 0x11   // Service Description Table (SDT) contains the name and provider of services
+	PIDTDT      uint16 = 0x14   // Time and Date Table (TDT) and Time Offset Table (TOT) contain the UTC time
+	PIDATSCBase uint16 = 0x1ffb // ATSC PSIP base PID carrying the STT, MGT and VCTs
+	PIDNull     uint16 = 0x1fff // Null Packet (used for fixed bandwidth padding)
 )
 
 // DemuxerData represents a data parsed by Demuxer
 type DemuxerData struct {
-	EIT         *EITData
-	FirstPacket *Packet
-	NIT         *NITData
-	PAT         *PATData
-	PES         *PESData
-	PID         uint16
-	PMT         *PMTData
-	SDT         *SDTData
-	TOT         *TOTData
+	ATSCMGT  *ATSCMGTData
+	ATSCSTT  *ATSCSTTData
+	ATSCTVCT *ATSCTVCTData
+	CRCValid bool // Whether the CRC32 of the PSI section is valid, see PSISection.CRCValid
+	// Whether a packet the data was parsed from has its discontinuity_indicator set, in which case its PCR, PTS and
+	// DTS may not follow previous ones
+	DiscontinuityIndicator bool
+	EIT                    *EITData
+	FirstPacket            *Packet
+	NIT                    *NITData
+	PAT                    *PATData
+	PES                    *PESData
+	PID                    uint16
+	PMT                    *PMTData
+	SCTE35                 *SCTE35Data
+	SDT                    *SDTData
+	StreamTypeMismatch     *StreamTypeMismatch // Set with DemuxerOptDetectStreamTypes
+	TDT                    *TDTData
+	TOT                    *TOTData
+	TSDT                   *TSDTData
 }
 
 // MuxerData represents a data to be written by Muxer
@@ -35,7 +50,7 @@ type MuxerData struct {
 }
 
 // parseData parses a payload spanning over multiple packets and returns a set of data
-func parseData(ps []*Packet, prs PacketsParser, pm programMap) (ds []*DemuxerData, err error) {
+func parseData(ps []*Packet, prs PacketsParser, pm programMap, skipCRCCheck bool) (ds []*DemuxerData, err error) {
 	// Use custom parser first
 	if prs != nil {
 		var skip bool
@@ -73,7 +88,7 @@ func parseData(ps []*Packet, prs PacketsParser, pm programMap) (ds []*DemuxerDat
 	} else if isPSIPayload(pid, pm) {
 		// Parse PSI data
 		var psiData *PSIData
-		if psiData, err = parsePSIData(i); err != nil {
+		if psiData, err = parsePSIData(i, skipCRCCheck); err != nil {
 			err = fmt.Errorf("astits: parsing PSI data failed: %w", err)
 			return
 		}
@@ -95,6 +110,16 @@ func parseData(ps []*Packet, prs PacketsParser, pm programMap) (ds []*DemuxerDat
 			PID:         pid,
 		})
 	}
+
+	// Discontinuities
+	for _, p := range ps {
+		if p.AdaptationField != nil && p.AdaptationField.DiscontinuityIndicator {
+			for _, d := range ds {
+				d.DiscontinuityIndicator = true
+			}
+			break
+		}
+	}
 	return
 }
 
@@ -102,6 +127,9 @@ func parseData(ps []*Packet, prs PacketsParser, pm programMap) (ds []*DemuxerDat
 func isPSIPayload(pid uint16, pm programMap) bool {
 	return pid == PIDPAT || // PAT
 		pm.exists(pid) || // PMT
+		pid == PIDTSDT || // TSDT
+		pm.carriesSections(pid) || // Elementary streams carrying sections, such as SCTE-35
+		pid == PIDATSCBase || // ATSC PSIP
 		((pid >= 0x10 && pid <= 0x14) || (pid >= 0x1e && pid <= 0x1f)) //DVB
 }
 
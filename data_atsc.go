@@ -0,0 +1,473 @@
+package astits
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf16"
+
+	"github.com/asticode/go-astikit"
+)
+
+// ATSC PSIP table IDs
+// Chapter: 6 | Link: https://www.atsc.org/wp-content/uploads/2015/03/Program-System-Information-Protocol-for-Terrestrial-Broadcast-and-Cable.pdf
+const (
+	PSITableIDMGT  PSITableID = 0xc7
+	PSITableIDTVCT PSITableID = 0xc8
+	PSITableIDSTT  PSITableID = 0xcd
+)
+
+// ATSC MGT table types
+const (
+	ATSCMGTTableTypeTVCTCurrent = 0x0000
+	ATSCMGTTableTypeTVCTNext    = 0x0001
+	ATSCMGTTableTypeCVCTCurrent = 0x0002
+	ATSCMGTTableTypeCVCTNext    = 0x0003
+	ATSCMGTTableTypeETTChannel  = 0x0004
+	ATSCMGTTableTypeDCCSCT      = 0x0005
+)
+
+// ATSC modulation modes
+const (
+	ATSCModulationModeAnalog = 0x01
+	ATSCModulationModeSCTE1  = 0x02
+	ATSCModulationModeSCTE2  = 0x03
+	ATSCModulationModeATSC8  = 0x04
+	ATSCModulationModeATSC16 = 0x05
+)
+
+// ATSC service types
+const (
+	ATSCServiceTypeAnalogTelevision  = 0x01
+	ATSCServiceTypeDigitalTelevision = 0x02
+	ATSCServiceTypeAudio             = 0x03
+	ATSCServiceTypeData              = 0x04
+)
+
+// ATSC descriptor tags
+const (
+	ATSCDescriptorTagServiceLocation = 0xa1
+)
+
+// Number of UTF-16 code units in a virtual channel short name
+const atscShortNameLength = 7
+
+// GPS epoch is 1980-01-06T00:00:00Z
+var atscGPSEpoch = time.Date(1980, time.January, 6, 0, 0, 0, 0, time.UTC)
+
+// ATSCSTTData represents an ATSC STT data
+// Chapter: 6.1 | Link: https://www.atsc.org/wp-content/uploads/2015/03/Program-System-Information-Protocol-for-Terrestrial-Broadcast-and-Cable.pdf
+type ATSCSTTData struct {
+	DaylightSavingsDayOfMonth uint8 // 5 bits
+	DaylightSavingsHour       uint8
+	DaylightSavingsStatus     bool
+	GPSUTCOffset              uint8 // Number of leap seconds between GPS and UTC time
+	SystemTime                time.Time
+}
+
+// ATSCMGTData represents an ATSC MGT data
+// Chapter: 6.2 | Link: https://www.atsc.org/wp-content/uploads/2015/03/Program-System-Information-Protocol-for-Terrestrial-Broadcast-and-Cable.pdf
+type ATSCMGTData struct {
+	Descriptors   []*Descriptor
+	Tables        []*ATSCMGTTable
+	VersionNumber uint8 // 5 bits
+}
+
+// ATSCMGTTable represents an ATSC MGT table
+type ATSCMGTTable struct {
+	Descriptors   []*Descriptor
+	NumberBytes   uint32 // Total size of the table sections
+	PID           uint16
+	Type          uint16
+	VersionNumber uint8 // 5 bits
+}
+
+// ATSCTVCTData represents an ATSC TVCT data
+// Chapter: 6.3.1 | Link: https://www.atsc.org/wp-content/uploads/2015/03/Program-System-Information-Protocol-for-Terrestrial-Broadcast-and-Cable.pdf
+type ATSCTVCTData struct {
+	AdditionalDescriptors []*Descriptor
+	Channels              []*ATSCVirtualChannel
+	TransportStreamID     uint16
+	VersionNumber         uint8 // 5 bits
+}
+
+// ATSCVirtualChannel represents an ATSC virtual channel
+type ATSCVirtualChannel struct {
+	AccessControlled   bool
+	ChannelTSID        uint16
+	Descriptors        []*Descriptor
+	ETMLocation        uint8 // 2 bits
+	Hidden             bool
+	HideGuide          bool
+	MajorChannelNumber uint16 // 10 bits
+	MinorChannelNumber uint16 // 10 bits
+	ModulationMode     uint8
+	ProgramNumber      uint16
+	ServiceLocation    *ATSCServiceLocation // Written as a service location descriptor before other descriptors
+	ServiceType        uint8                // 6 bits
+	ShortName          string               // At most 7 characters
+	SourceID           uint16
+}
+
+// ATSCServiceLocation represents an ATSC service location descriptor
+// Chapter: 6.9.5 | Link: https://www.atsc.org/wp-content/uploads/2015/03/Program-System-Information-Protocol-for-Terrestrial-Broadcast-and-Cable.pdf
+type ATSCServiceLocation struct {
+	Elements []*ATSCServiceLocationElement
+	PCRPID   uint16
+}
+
+// ATSCServiceLocationElement represents an ATSC service location element
+type ATSCServiceLocationElement struct {
+	ElementaryPID uint16
+	Language      []byte // 3 bytes, zeroes when undefined
+	StreamType    StreamType
+}
+
+// atscGPSTime returns the number of GPS seconds since the GPS epoch
+func atscGPSTime(t time.Time, gpsUTCOffset uint8) uint32 {
+	return uint32(t.Sub(atscGPSEpoch)/time.Second) + uint32(gpsUTCOffset)
+}
+
+func writeATSCSTTSection(w *astikit.BitsWriter, d *ATSCSTTData) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(uint8(0)) // protocol version
+	b.Write(atscGPSTime(d.SystemTime, d.GPSUTCOffset))
+	b.Write(d.GPSUTCOffset)
+	b.Write(d.DaylightSavingsStatus)
+	b.WriteN(uint8(0xff), 2) // reserved
+	b.WriteN(d.DaylightSavingsDayOfMonth, 5)
+	b.Write(d.DaylightSavingsHour)
+
+	return b.Err()
+}
+
+func writeATSCMGTSection(w *astikit.BitsWriter, d *ATSCMGTData) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(uint8(0)) // protocol version
+	b.Write(uint16(len(d.Tables)))
+	if err := b.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range d.Tables {
+		b.Write(t.Type)
+		b.WriteN(uint8(0xff), 3) // reserved
+		b.WriteN(t.PID, 13)
+		b.WriteN(uint8(0xff), 3) // reserved
+		b.WriteN(t.VersionNumber, 5)
+		b.Write(t.NumberBytes)
+		if err := b.Err(); err != nil {
+			return err
+		}
+
+		if _, err := writeDescriptorsWithLength(w, t.Descriptors); err != nil {
+			return err
+		}
+	}
+
+	_, err := writeDescriptorsWithLength(w, d.Descriptors)
+	return err
+}
+
+func writeATSCTVCTSection(w *astikit.BitsWriter, d *ATSCTVCTData) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(uint8(0)) // protocol version
+	b.Write(uint8(len(d.Channels)))
+
+	for _, c := range d.Channels {
+		shortName := utf16.Encode([]rune(c.ShortName))
+		for i := 0; i < atscShortNameLength; i++ {
+			if i < len(shortName) {
+				b.Write(shortName[i])
+			} else {
+				b.Write(uint16(0))
+			}
+		}
+
+		b.WriteN(uint8(0xff), 4) // reserved
+		b.WriteN(c.MajorChannelNumber, 10)
+		b.WriteN(c.MinorChannelNumber, 10)
+		b.Write(c.ModulationMode)
+		b.Write(uint32(0)) // carrier frequency is deprecated
+		b.Write(c.ChannelTSID)
+		b.Write(c.ProgramNumber)
+		b.WriteN(c.ETMLocation, 2)
+		b.Write(c.AccessControlled)
+		b.Write(c.Hidden)
+		b.WriteN(uint8(0xff), 2) // reserved
+		b.Write(c.HideGuide)
+		b.WriteN(uint8(0xff), 3) // reserved
+		b.WriteN(c.ServiceType, 6)
+		b.Write(c.SourceID)
+
+		b.WriteN(uint8(0xff), 6) // reserved
+		b.WriteN(calcATSCServiceLocationLength(c.ServiceLocation)+calcDescriptorsLength(c.Descriptors), 10)
+		if err := b.Err(); err != nil {
+			return err
+		}
+
+		if err := writeATSCServiceLocation(w, c.ServiceLocation); err != nil {
+			return err
+		}
+		if _, err := writeDescriptors(w, c.Descriptors); err != nil {
+			return err
+		}
+	}
+
+	b.WriteN(uint8(0xff), 6) // reserved
+	b.WriteN(calcDescriptorsLength(d.AdditionalDescriptors), 10)
+	if err := b.Err(); err != nil {
+		return err
+	}
+
+	_, err := writeDescriptors(w, d.AdditionalDescriptors)
+	return err
+}
+
+// calcATSCServiceLocationLength returns the length of a service location descriptor, tag and length included
+func calcATSCServiceLocationLength(d *ATSCServiceLocation) uint16 {
+	if d == nil {
+		return 0
+	}
+	return 2 + 3 + uint16(len(d.Elements))*6
+}
+
+func writeATSCServiceLocation(w *astikit.BitsWriter, d *ATSCServiceLocation) error {
+	if d == nil {
+		return nil
+	}
+
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(uint8(ATSCDescriptorTagServiceLocation))
+	b.Write(uint8(calcATSCServiceLocationLength(d) - 2))
+	b.WriteN(uint8(0xff), 3) // reserved
+	b.WriteN(d.PCRPID, 13)
+	b.Write(uint8(len(d.Elements)))
+
+	for _, e := range d.Elements {
+		b.Write(uint8(e.StreamType))
+		b.WriteN(uint8(0xff), 3) // reserved
+		b.WriteN(e.ElementaryPID, 13)
+		if len(e.Language) == 3 {
+			b.Write(e.Language)
+		} else {
+			b.Write([]byte{0, 0, 0})
+		}
+	}
+
+	return b.Err()
+}
+
+// parseATSCSTTSection parses an ATSC STT section
+func parseATSCSTTSection(i *astikit.BytesIterator) (d *ATSCSTTData, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(8); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create data
+	// The first byte is the protocol version
+	d = &ATSCSTTData{
+		DaylightSavingsDayOfMonth: bs[6] & 0x1f,
+		DaylightSavingsHour:       bs[7],
+		DaylightSavingsStatus:     bs[6]&0x80 > 0,
+		GPSUTCOffset:              bs[5],
+	}
+
+	// System time
+	gpsTime := uint32(bs[1])<<24 | uint32(bs[2])<<16 | uint32(bs[3])<<8 | uint32(bs[4])
+	d.SystemTime = atscGPSEpoch.Add(time.Duration(int64(gpsTime)-int64(d.GPSUTCOffset)) * time.Second)
+	return
+}
+
+// parseATSCMGTSection parses an ATSC MGT section
+func parseATSCMGTSection(i *astikit.BytesIterator, versionNumber uint8) (d *ATSCMGTData, err error) {
+	// Create data
+	d = &ATSCMGTData{VersionNumber: versionNumber}
+
+	// Get next bytes
+	// The first byte is the protocol version
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(3); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Loop through tables
+	tablesDefined := int(uint16(bs[1])<<8 | uint16(bs[2]))
+	for idx := 0; idx < tablesDefined; idx++ {
+		// Get next bytes
+		if bs, err = i.NextBytesNoCopy(9); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+
+		// Create table
+		t := &ATSCMGTTable{
+			NumberBytes:   uint32(bs[5])<<24 | uint32(bs[6])<<16 | uint32(bs[7])<<8 | uint32(bs[8]),
+			PID:           uint16(bs[2]&0x1f)<<8 | uint16(bs[3]),
+			Type:          uint16(bs[0])<<8 | uint16(bs[1]),
+			VersionNumber: bs[4] & 0x1f,
+		}
+
+		// Descriptors
+		if t.Descriptors, err = parseDescriptors(i); err != nil {
+			err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+			return
+		}
+
+		// Append table
+		d.Tables = append(d.Tables, t)
+	}
+
+	// Descriptors
+	if d.Descriptors, err = parseDescriptors(i); err != nil {
+		err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+		return
+	}
+	return
+}
+
+// parseATSCTVCTSection parses an ATSC TVCT section
+func parseATSCTVCTSection(i *astikit.BytesIterator, tableIDExtension uint16, versionNumber uint8) (d *ATSCTVCTData, err error) {
+	// Create data
+	d = &ATSCTVCTData{
+		TransportStreamID: tableIDExtension,
+		VersionNumber:     versionNumber,
+	}
+
+	// Get next bytes
+	// The first byte is the protocol version
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(2); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Loop through channels
+	numChannels := int(bs[1])
+	for idx := 0; idx < numChannels; idx++ {
+		// Get next bytes
+		if bs, err = i.NextBytesNoCopy(2*atscShortNameLength + 16); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+
+		// Short name
+		var shortName []uint16
+		for j := 0; j < atscShortNameLength; j++ {
+			if c := uint16(bs[2*j])<<8 | uint16(bs[2*j+1]); c > 0 {
+				shortName = append(shortName, c)
+			}
+		}
+		bs = bs[2*atscShortNameLength:]
+
+		// Create channel
+		// Carrier frequency is deprecated
+		c := &ATSCVirtualChannel{
+			AccessControlled:   bs[12]&0x20 > 0,
+			ChannelTSID:        uint16(bs[8])<<8 | uint16(bs[9]),
+			ETMLocation:        bs[12] >> 6,
+			Hidden:             bs[12]&0x10 > 0,
+			HideGuide:          bs[12]&0x2 > 0,
+			MajorChannelNumber: uint16(bs[0]&0xf)<<6 | uint16(bs[1])>>2,
+			MinorChannelNumber: uint16(bs[1]&0x3)<<8 | uint16(bs[2]),
+			ModulationMode:     bs[3],
+			ProgramNumber:      uint16(bs[10])<<8 | uint16(bs[11]),
+			ServiceType:        bs[13] & 0x3f,
+			ShortName:          string(utf16.Decode(shortName)),
+			SourceID:           uint16(bs[14])<<8 | uint16(bs[15]),
+		}
+
+		// Descriptors
+		if c.ServiceLocation, c.Descriptors, err = parseATSCDescriptors(i); err != nil {
+			err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+			return
+		}
+
+		// Append channel
+		d.Channels = append(d.Channels, c)
+	}
+
+	// Additional descriptors
+	if _, d.AdditionalDescriptors, err = parseATSCDescriptors(i); err != nil {
+		err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+		return
+	}
+	return
+}
+
+// parseATSCDescriptors parses descriptors prefixed with a 10 bits length
+// The service location descriptor is returned apart from other descriptors
+func parseATSCDescriptors(i *astikit.BytesIterator) (sl *ATSCServiceLocation, ds []*Descriptor, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(2); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Parse descriptors
+	length := int(uint16(bs[0]&0x3)<<8 | uint16(bs[1]))
+	var all []*Descriptor
+	if all, err = parseDescriptorsUntil(i, i.Offset()+length); err != nil {
+		err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+		return
+	}
+
+	// Extract service location
+	for _, d := range all {
+		if d.Tag == ATSCDescriptorTagServiceLocation && sl == nil {
+			if sl, err = parseATSCServiceLocation(astikit.NewBytesIterator(d.UserDefined)); err != nil {
+				err = fmt.Errorf("astits: parsing service location descriptor failed: %w", err)
+				return
+			}
+			continue
+		}
+		ds = append(ds, d)
+	}
+	return
+}
+
+// parseATSCServiceLocation parses an ATSC service location descriptor, tag and length excluded
+func parseATSCServiceLocation(i *astikit.BytesIterator) (d *ATSCServiceLocation, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(3); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &ATSCServiceLocation{PCRPID: uint16(bs[0]&0x1f)<<8 | uint16(bs[1])}
+
+	// Loop through elements
+	numberElements := int(bs[2])
+	for idx := 0; idx < numberElements; idx++ {
+		// Get next bytes
+		if bs, err = i.NextBytesNoCopy(6); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+
+		// Create element
+		e := &ATSCServiceLocationElement{
+			ElementaryPID: uint16(bs[1]&0x1f)<<8 | uint16(bs[2]),
+			StreamType:    StreamType(bs[0]),
+		}
+
+		// Language is undefined when zeroed
+		if bs[3] != 0 || bs[4] != 0 || bs[5] != 0 {
+			e.Language = append([]byte(nil), bs[3:6]...)
+		}
+
+		// Append element
+		d.Elements = append(d.Elements, e)
+	}
+	return
+}
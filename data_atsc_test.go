@@ -0,0 +1,120 @@
+package astits
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteATSCSTTSection(t *testing.T) {
+	bufExpected := bytes.Buffer{}
+	wExpected := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &bufExpected})
+	wExpected.Write(uint8(0))      // Protocol version
+	wExpected.Write(uint32(1018))  // System time
+	wExpected.Write(uint8(18))     // GPS UTC offset
+	wExpected.Write("1")           // DS status
+	wExpected.Write("11")          // Reserved
+	wExpected.WriteN(uint8(15), 5) // DS day of month
+	wExpected.Write(uint8(2))      // DS hour
+
+	bufActual := bytes.Buffer{}
+	wActual := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &bufActual})
+	err := writeATSCSTTSection(wActual, &ATSCSTTData{
+		DaylightSavingsDayOfMonth: 15,
+		DaylightSavingsHour:       2,
+		DaylightSavingsStatus:     true,
+		GPSUTCOffset:              18,
+		SystemTime:                atscGPSEpoch.Add(1000 * time.Second),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bufExpected.Bytes(), bufActual.Bytes())
+}
+
+func TestWriteATSCMGTSection(t *testing.T) {
+	bufExpected := bytes.Buffer{}
+	wExpected := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &bufExpected})
+	wExpected.Write(uint8(0))                            // Protocol version
+	wExpected.Write(uint16(1))                           // Tables defined
+	wExpected.Write(uint16(ATSCMGTTableTypeTVCTCurrent)) // Table type
+	wExpected.Write("111")                               // Reserved
+	wExpected.WriteN(PIDATSCBase, 13)                    // Table type PID
+	wExpected.Write("111")                               // Reserved
+	wExpected.WriteN(uint8(3), 5)                        // Table type version number
+	wExpected.Write(uint32(42))                          // Number bytes
+	wExpected.Write("1111")                              // Reserved
+	wExpected.WriteN(uint16(0), 12)                      // Table type descriptors length
+	wExpected.Write("1111")                              // Reserved
+	wExpected.WriteN(uint16(0), 12)                      // Descriptors length
+
+	bufActual := bytes.Buffer{}
+	wActual := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &bufActual})
+	err := writeATSCMGTSection(wActual, &ATSCMGTData{Tables: []*ATSCMGTTable{{
+		NumberBytes:   42,
+		PID:           PIDATSCBase,
+		Type:          ATSCMGTTableTypeTVCTCurrent,
+		VersionNumber: 3,
+	}}})
+	assert.NoError(t, err)
+	assert.Equal(t, bufExpected.Bytes(), bufActual.Bytes())
+}
+
+func TestWriteATSCTVCTSection(t *testing.T) {
+	bufExpected := bytes.Buffer{}
+	wExpected := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &bufExpected})
+	wExpected.Write(uint8(0)) // Protocol version
+	wExpected.Write(uint8(1)) // Number of channels
+	for _, c := range []uint16{'K', 'A', 'B', 'C', 0, 0, 0} {
+		wExpected.Write(c) // Short name
+	}
+	wExpected.Write("1111")                                      // Reserved
+	wExpected.WriteN(uint16(7), 10)                              // Major channel number
+	wExpected.WriteN(uint16(1), 10)                              // Minor channel number
+	wExpected.Write(uint8(ATSCModulationModeATSC8))              // Modulation mode
+	wExpected.Write(uint32(0))                                   // Carrier frequency
+	wExpected.Write(uint16(2))                                   // Channel TSID
+	wExpected.Write(uint16(3))                                   // Program number
+	wExpected.Write("00")                                        // ETM location
+	wExpected.Write("0")                                         // Access controlled
+	wExpected.Write("1")                                         // Hidden
+	wExpected.Write("11")                                        // Reserved
+	wExpected.Write("0")                                         // Hide guide
+	wExpected.Write("111")                                       // Reserved
+	wExpected.WriteN(uint8(ATSCServiceTypeDigitalTelevision), 6) // Service type
+	wExpected.Write(uint16(4))                                   // Source ID
+	wExpected.Write("111111")                                    // Reserved
+	wExpected.WriteN(uint16(11), 10)                             // Descriptors length
+	wExpected.Write(uint8(ATSCDescriptorTagServiceLocation))     // Tag
+	wExpected.Write(uint8(9))                                    // Length
+	wExpected.Write("111")                                       // Reserved
+	wExpected.WriteN(uint16(0x100), 13)                          // PCR PID
+	wExpected.Write(uint8(1))                                    // Number of elements
+	wExpected.Write(uint8(StreamTypeH264Video))                  // Stream type
+	wExpected.Write("111")                                       // Reserved
+	wExpected.WriteN(uint16(0x100), 13)                          // Elementary PID
+	wExpected.Write([]byte{0, 0, 0})                             // Language
+	wExpected.Write("111111")                                    // Reserved
+	wExpected.WriteN(uint16(0), 10)                              // Additional descriptors length
+
+	bufActual := bytes.Buffer{}
+	wActual := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &bufActual})
+	err := writeATSCTVCTSection(wActual, &ATSCTVCTData{Channels: []*ATSCVirtualChannel{{
+		ChannelTSID:        2,
+		Hidden:             true,
+		MajorChannelNumber: 7,
+		MinorChannelNumber: 1,
+		ModulationMode:     ATSCModulationModeATSC8,
+		ProgramNumber:      3,
+		ServiceLocation: &ATSCServiceLocation{
+			Elements: []*ATSCServiceLocationElement{{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}},
+			PCRPID:   0x100,
+		},
+		ServiceType: ATSCServiceTypeDigitalTelevision,
+		ShortName:   "KABC",
+		SourceID:    4,
+	}}})
+	assert.NoError(t, err)
+	assert.Equal(t, bufExpected.Bytes(), bufActual.Bytes())
+}
@@ -38,3 +38,19 @@ func TestParseNITSection(t *testing.T) {
 	assert.Equal(t, d, nit)
 	assert.NoError(t, err)
 }
+
+func TestWriteNITSection(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &buf})
+	n, err := writeNITSection(w, nit)
+	assert.NoError(t, err)
+	assert.Equal(t, n, buf.Len())
+	assert.Equal(t, int(calcNITSectionLength(nit)), n)
+	// Reserved bits are set when writing
+	bs := nitBytes()
+	dl := int(calcDescriptorsLength(descriptors))
+	for _, i := range []int{0, 2 + dl, 2 + dl + 2 + 4} {
+		bs[i] |= 0xf0
+	}
+	assert.Equal(t, bs, buf.Bytes())
+}
@@ -46,6 +46,9 @@ func parsePATSection(i *astikit.BytesIterator, offsetSectionsEnd int, tableIDExt
 	return
 }
 
+// Maximum number of programs fitting in a single PAT section: 5 bytes of syntax header and 4 bytes of CRC32
+const patMaxProgramsPerSection = (psiSectionMaxLength - 5 - 4) / 4
+
 func calcPATSectionLength(d *PATData) uint16 {
 	return uint16(4 * len(d.Programs))
 }
@@ -1,6 +1,7 @@
 package astits
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/asticode/go-astikit"
@@ -20,6 +21,14 @@ const (
 	PTSDTSIndicatorOnlyPTS     = 2
 )
 
+// ErrPTSDTSIndicatorInvalid is returned when writing a PES optional header whose PTS DTS indicator is forbidden,
+// which is the case of DTS without PTS, or doesn't match its timestamps
+var ErrPTSDTSIndicatorInvalid = errors.New("astits: PTS DTS indicator invalid")
+
+// ErrRawOptionalHeaderInvalid is returned when writing a PES raw optional header whose PES_header_data_length doesn't
+// match its length or whose marker bits are not '10'
+var ErrRawOptionalHeaderInvalid = errors.New("astits: raw optional header invalid")
+
 // Stream IDs
 const (
 	StreamIDPrivateStream1 = 189
@@ -54,9 +63,11 @@ type PESData struct {
 
 // PESHeader represents a packet PES header
 type PESHeader struct {
-	OptionalHeader *PESOptionalHeader
-	PacketLength   uint16 // Specifies the number of bytes remaining in the packet after this field. Can be zero. If the PES packet length is set to zero, the PES packet can be of any length. A value of zero for the PES packet length can be used only when the PES packet payload is a video elementary stream.
-	StreamID       uint8  // Examples: Audio streams (0xC0-0xDF), Video streams (0xE0-0xEF)
+	OptionalHeader    *PESOptionalHeader
+	PacketLength      uint16 // Specifies the number of bytes remaining in the packet after this field. Can be zero. If the PES packet length is set to zero, the PES packet can be of any length. A value of zero for the PES packet length can be used only when the PES packet payload is a video elementary stream.
+	RawOptionalHeader []byte // Only used when writing, serialized optional header written verbatim instead of OptionalHeader
+	StreamID          uint8  // Examples: Audio streams (0xC0-0xDF), Video streams (0xE0-0xEF)
+	WritePacketLength bool   // Only used when writing, video streams get a packet length too when the payload fits
 }
 
 // PESOptionalHeader represents a PES optional header
@@ -96,6 +107,7 @@ type PESOptionalHeader struct {
 	PSTDBufferSize                  uint16
 	PTS                             *ClockReference
 	PTSDTSIndicator                 uint8
+	ReservedBits                    *ReservedBits // Only used when writing, spec values are used when nil
 	ScramblingControl               uint8
 }
 
@@ -431,7 +443,7 @@ func parseESCR(i *astikit.BytesIterator) (cr *ClockReference, err error) {
 func calcPESDataLength(h *PESHeader, payloadLeft []byte, isPayloadStart bool, bytesAvailable int) (totalBytes, payloadBytes int) {
 	totalBytes += pesHeaderLength
 	if isPayloadStart {
-		totalBytes += int(calcPESOptionalHeaderLength(h.OptionalHeader))
+		totalBytes += h.optionalHeaderLength()
 	}
 	bytesAvailable -= totalBytes
 
@@ -479,10 +491,11 @@ func writePESHeader(w *astikit.BitsWriter, h *PESHeader, payloadSize int) (int,
 
 	pesPacketLength := 0
 
-	if !h.IsVideoStream() {
+	// Packet length doesn't include the start code prefix, the stream ID and itself
+	if !h.IsVideoStream() || h.WritePacketLength {
 		pesPacketLength = payloadSize
 		if hasPESOptionalHeader(h.StreamID) {
-			pesPacketLength += int(calcPESOptionalHeaderLength(h.OptionalHeader))
+			pesPacketLength += h.optionalHeaderLength()
 		}
 		if pesPacketLength > 0xffff {
 			pesPacketLength = 0
@@ -494,16 +507,33 @@ func writePESHeader(w *astikit.BitsWriter, h *PESHeader, payloadSize int) (int,
 	bytesWritten := pesHeaderLength
 
 	if hasPESOptionalHeader(h.StreamID) {
-		n, err := writePESOptionalHeader(w, h.OptionalHeader)
-		if err != nil {
-			return 0, err
+		if h.RawOptionalHeader != nil {
+			// PES_header_data_length is the 3rd byte
+			if raw := h.RawOptionalHeader; len(raw) < 3 || raw[0]>>6 != 0b10 || int(raw[2]) != len(raw)-3 {
+				return 0, ErrRawOptionalHeaderInvalid
+			}
+			b.Write(h.RawOptionalHeader)
+			bytesWritten += len(h.RawOptionalHeader)
+		} else {
+			n, err := writePESOptionalHeader(w, h.OptionalHeader)
+			if err != nil {
+				return 0, err
+			}
+			bytesWritten += n
 		}
-		bytesWritten += n
 	}
 
 	return bytesWritten, b.Err()
 }
 
+// optionalHeaderLength returns the length of the optional header written, raw or not
+func (h *PESHeader) optionalHeaderLength() int {
+	if h.RawOptionalHeader != nil {
+		return len(h.RawOptionalHeader)
+	}
+	return int(calcPESOptionalHeaderLength(h.OptionalHeader))
+}
+
 func calcPESOptionalHeaderLength(h *PESOptionalHeader) uint8 {
 	if h == nil {
 		return 0
@@ -570,9 +600,23 @@ func writePESOptionalHeader(w *astikit.BitsWriter, h *PESOptionalHeader) (int, e
 		return 0, nil
 	}
 
+	switch h.PTSDTSIndicator {
+	case PTSDTSIndicatorIsForbidden:
+		return 0, ErrPTSDTSIndicatorInvalid
+	case PTSDTSIndicatorOnlyPTS:
+		if h.PTS == nil {
+			return 0, ErrPTSDTSIndicatorInvalid
+		}
+	case PTSDTSIndicatorBothPresent:
+		if h.PTS == nil || h.DTS == nil {
+			return 0, ErrPTSDTSIndicatorInvalid
+		}
+	}
+
+	rb := h.ReservedBits.orDefault()
 	b := astikit.NewBitsWriterBatch(w)
 
-	b.WriteN(uint8(0b10), 2) // marker bits
+	b.WriteN(rb.PESMarkerBits, 2)
 	b.WriteN(h.ScramblingControl, 2)
 	b.Write(h.Priority)
 	b.Write(h.DataAlignmentIndicator)
@@ -594,7 +638,7 @@ func writePESOptionalHeader(w *astikit.BitsWriter, h *PESOptionalHeader) (int, e
 	bytesWritten := 3
 
 	if h.PTSDTSIndicator == PTSDTSIndicatorOnlyPTS {
-		n, err := writePTSOrDTS(w, 0b0010, h.PTS)
+		n, err := writePTSOrDTS(w, 0b0010, h.PTS, rb)
 		if err != nil {
 			return 0, err
 		}
@@ -602,13 +646,13 @@ func writePESOptionalHeader(w *astikit.BitsWriter, h *PESOptionalHeader) (int, e
 	}
 
 	if h.PTSDTSIndicator == PTSDTSIndicatorBothPresent {
-		n, err := writePTSOrDTS(w, 0b0011, h.PTS)
+		n, err := writePTSOrDTS(w, 0b0011, h.PTS, rb)
 		if err != nil {
 			return 0, err
 		}
 		bytesWritten += n
 
-		n, err = writePTSOrDTS(w, 0b0001, h.DTS)
+		n, err = writePTSOrDTS(w, 0b0001, h.DTS, rb)
 		if err != nil {
 			return 0, err
 		}
@@ -616,7 +660,7 @@ func writePESOptionalHeader(w *astikit.BitsWriter, h *PESOptionalHeader) (int, e
 	}
 
 	if h.HasESCR {
-		n, err := writeESCR(w, h.ESCR)
+		n, err := writeESCR(w, h.ESCR, rb)
 		if err != nil {
 			return 0, err
 		}
@@ -624,14 +668,14 @@ func writePESOptionalHeader(w *astikit.BitsWriter, h *PESOptionalHeader) (int, e
 	}
 
 	if h.HasESRate {
-		b.Write(true)
+		b.Write(rb.MarkerBit)
 		b.WriteN(h.ESRate, 22)
-		b.Write(true)
+		b.Write(rb.MarkerBit)
 		bytesWritten += 3
 	}
 
 	if h.HasDSMTrickMode {
-		n, err := writeDSMTrickMode(w, h.DSMTrickMode)
+		n, err := writeDSMTrickMode(w, h.DSMTrickMode, rb)
 		if err != nil {
 			return 0, err
 		}
@@ -639,7 +683,7 @@ func writePESOptionalHeader(w *astikit.BitsWriter, h *PESOptionalHeader) (int, e
 	}
 
 	if h.HasAdditionalCopyInfo {
-		b.Write(true) // marker_bit
+		b.Write(rb.MarkerBit) // marker_bit
 		b.WriteN(h.AdditionalCopyInfo, 7)
 		bytesWritten++
 	}
@@ -656,7 +700,7 @@ func writePESOptionalHeader(w *astikit.BitsWriter, h *PESOptionalHeader) (int, e
 		//b.Write(h.HasPackHeaderField)
 		b.Write(h.HasProgramPacketSequenceCounter)
 		b.Write(h.HasPSTDBuffer)
-		b.WriteN(uint8(0xff), 3) // reserved
+		b.WriteN(rb.Reserved, 3)
 		b.Write(h.HasExtension2)
 		bytesWritten++
 
@@ -670,9 +714,9 @@ func writePESOptionalHeader(w *astikit.BitsWriter, h *PESOptionalHeader) (int, e
 		}
 
 		if h.HasProgramPacketSequenceCounter {
-			b.Write(true) // marker_bit
+			b.Write(rb.MarkerBit) // marker_bit
 			b.WriteN(h.PacketSequenceCounter, 7)
-			b.Write(true) // marker_bit
+			b.Write(rb.MarkerBit) // marker_bit
 			b.WriteN(h.MPEG1OrMPEG2ID, 1)
 			b.WriteN(h.OriginalStuffingLength, 6)
 			bytesWritten += 2
@@ -686,7 +730,7 @@ func writePESOptionalHeader(w *astikit.BitsWriter, h *PESOptionalHeader) (int, e
 		}
 
 		if h.HasExtension2 {
-			b.Write(true) // marker_bit
+			b.Write(rb.MarkerBit) // marker_bit
 			b.WriteN(uint8(len(h.Extension2Data)), 7)
 			b.Write(h.Extension2Data)
 			bytesWritten += 1 + len(h.Extension2Data)
@@ -696,7 +740,7 @@ func writePESOptionalHeader(w *astikit.BitsWriter, h *PESOptionalHeader) (int, e
 	return bytesWritten, b.Err()
 }
 
-func writeDSMTrickMode(w *astikit.BitsWriter, m *DSMTrickMode) (int, error) {
+func writeDSMTrickMode(w *astikit.BitsWriter, m *DSMTrickMode, rb *ReservedBits) (int, error) {
 	b := astikit.NewBitsWriterBatch(w)
 
 	b.WriteN(m.TrickModeControl, 3)
@@ -706,42 +750,42 @@ func writeDSMTrickMode(w *astikit.BitsWriter, m *DSMTrickMode) (int, error) {
 		b.WriteN(m.FrequencyTruncation, 2)
 	} else if m.TrickModeControl == TrickModeControlFreezeFrame {
 		b.WriteN(m.FieldID, 2)
-		b.WriteN(uint8(0xff), 3) // reserved
+		b.WriteN(rb.Reserved, 3)
 	} else if m.TrickModeControl == TrickModeControlSlowMotion || m.TrickModeControl == TrickModeControlSlowReverse {
 		b.WriteN(m.RepeatControl, 5)
 	} else {
-		b.WriteN(uint8(0xff), 5) // reserved
+		b.WriteN(rb.Reserved, 5)
 	}
 
 	return dsmTrickModeLength, b.Err()
 }
 
-func writeESCR(w *astikit.BitsWriter, cr *ClockReference) (int, error) {
+func writeESCR(w *astikit.BitsWriter, cr *ClockReference, rb *ReservedBits) (int, error) {
 	b := astikit.NewBitsWriterBatch(w)
 
-	b.WriteN(uint8(0xff), 2)
+	b.WriteN(rb.Reserved, 2)
 	b.WriteN(uint64(cr.Base>>30), 3)
-	b.Write(true)
+	b.Write(rb.MarkerBit)
 	b.WriteN(uint64(cr.Base>>15), 15)
-	b.Write(true)
+	b.Write(rb.MarkerBit)
 	b.WriteN(uint64(cr.Base), 15)
-	b.Write(true)
+	b.Write(rb.MarkerBit)
 	b.WriteN(uint64(cr.Extension), 9)
-	b.Write(true)
+	b.Write(rb.MarkerBit)
 
 	return escrLength, b.Err()
 }
 
-func writePTSOrDTS(w *astikit.BitsWriter, flag uint8, cr *ClockReference) (bytesWritten int, retErr error) {
+func writePTSOrDTS(w *astikit.BitsWriter, flag uint8, cr *ClockReference, rb *ReservedBits) (bytesWritten int, retErr error) {
 	b := astikit.NewBitsWriterBatch(w)
 
 	b.WriteN(flag, 4)
 	b.WriteN(uint64(cr.Base>>30), 3)
-	b.Write(true)
+	b.Write(rb.MarkerBit)
 	b.WriteN(uint64(cr.Base>>15), 15)
-	b.Write(true)
+	b.Write(rb.MarkerBit)
 	b.WriteN(uint64(cr.Base), 15)
-	b.Write(true)
+	b.Write(rb.MarkerBit)
 
 	return ptsOrDTSByteLength, b.Err()
 }
@@ -135,7 +135,7 @@ func TestWriteDSMTrickMode(t *testing.T) {
 			bufActual := &bytes.Buffer{}
 			wActual := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: bufActual})
 
-			n, err := writeDSMTrickMode(wActual, tc.trickMode)
+			n, err := writeDSMTrickMode(wActual, tc.trickMode, defaultReservedBits)
 			assert.NoError(t, err)
 			assert.Equal(t, 1, n)
 			assert.Equal(t, n, bufActual.Len())
@@ -183,7 +183,7 @@ func TestParsePTSOrDTS(t *testing.T) {
 func TestWritePTSOrDTS(t *testing.T) {
 	buf := &bytes.Buffer{}
 	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
-	n, err := writePTSOrDTS(w, uint8(0b0010), dtsClockReference)
+	n, err := writePTSOrDTS(w, uint8(0b0010), dtsClockReference, defaultReservedBits)
 	assert.NoError(t, err)
 	assert.Equal(t, n, 5)
 	assert.Equal(t, n, buf.Len())
@@ -214,7 +214,7 @@ func TestParseESCR(t *testing.T) {
 func TestWriteESCR(t *testing.T) {
 	buf := &bytes.Buffer{}
 	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
-	n, err := writeESCR(w, clockReference)
+	n, err := writeESCR(w, clockReference, defaultReservedBits)
 	assert.NoError(t, err)
 	assert.Equal(t, n, 6)
 	assert.Equal(t, n, buf.Len())
@@ -467,6 +467,36 @@ func TestWritePESHeader(t *testing.T) {
 	}
 }
 
+func TestWritePESHeaderPacketLength(t *testing.T) {
+	h := &PESHeader{
+		OptionalHeader: &PESOptionalHeader{
+			MarkerBits:      2,
+			PTS:             ptsClockReference,
+			PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+		},
+		StreamID: 0xe0,
+	}
+	for _, tc := range []struct {
+		name              string
+		writePacketLength bool
+		payloadSize       int
+		expected          uint16
+	}{
+		{name: "unbounded", payloadSize: 100},
+		{name: "bounded", writePacketLength: true, payloadSize: 100, expected: 108},
+		{name: "too long", writePacketLength: true, payloadSize: 0xffff},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h.WritePacketLength = tc.writePacketLength
+			buf := &bytes.Buffer{}
+			w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+			_, err := writePESHeader(w, h, tc.payloadSize)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, uint16(buf.Bytes()[4])<<8|uint16(buf.Bytes()[5]))
+		})
+	}
+}
+
 func TestWritePESOptionalHeader(t *testing.T) {
 	for _, tc := range pesTestCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -507,3 +537,78 @@ func BenchmarkParsePESData(b *testing.B) {
 		})
 	}
 }
+
+func TestWritePESOptionalHeaderReservedBits(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	n, err := writePESOptionalHeader(w, &PESOptionalHeader{
+		PTS:             dtsClockReference,
+		PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+		ReservedBits:    &ReservedBits{PESMarkerBits: 0b01, Reserved: 0xff},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 8, n)
+
+	bufExpected := &bytes.Buffer{}
+	wExpected := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: bufExpected})
+	wExpected.Write("01")       // Marker bits
+	wExpected.Write("000000")   // Flags
+	wExpected.Write("10000000") // Flags
+	wExpected.Write(uint8(5))   // Header length
+	wExpected.Write(dtsBytes("0010"))
+	bs := bufExpected.Bytes()
+	for _, i := range []int{3, 5, 7} {
+		bs[i] &^= 0x1 // Marker bit
+	}
+	assert.Equal(t, bs, buf.Bytes())
+}
+
+func TestWritePESOptionalHeaderPTSDTS(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		h         *PESOptionalHeader
+		expected  []byte
+		indicator string
+	}{
+		{
+			name:      "PTS only",
+			h:         &PESOptionalHeader{PTS: ptsClockReference, PTSDTSIndicator: PTSDTSIndicatorOnlyPTS},
+			expected:  ptsBytes("0010"),
+			indicator: "10",
+		},
+		{
+			name:      "PTS and DTS",
+			h:         &PESOptionalHeader{DTS: dtsClockReference, PTS: ptsClockReference, PTSDTSIndicator: PTSDTSIndicatorBothPresent},
+			expected:  append(ptsBytes("0011"), dtsBytes("0001")...),
+			indicator: "11",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, uint8(3+len(tc.expected)), calcPESOptionalHeaderLength(tc.h))
+
+			buf := &bytes.Buffer{}
+			w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+			n, err := writePESOptionalHeader(w, tc.h)
+			assert.NoError(t, err)
+			assert.Equal(t, 3+len(tc.expected), n)
+
+			bufExpected := &bytes.Buffer{}
+			wExpected := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: bufExpected})
+			wExpected.Write("10000000")              // Marker bits and flags
+			wExpected.Write(tc.indicator + "000000") // PTS DTS indicator and flags
+			wExpected.Write(uint8(len(tc.expected))) // Header length
+			wExpected.Write(tc.expected)
+			assert.Equal(t, bufExpected.Bytes(), buf.Bytes())
+		})
+	}
+
+	// Invalid indicators
+	for _, h := range []*PESOptionalHeader{
+		{DTS: dtsClockReference, PTSDTSIndicator: PTSDTSIndicatorIsForbidden},
+		{PTSDTSIndicator: PTSDTSIndicatorOnlyPTS},
+		{PTS: ptsClockReference, PTSDTSIndicator: PTSDTSIndicatorBothPresent},
+	} {
+		_, err := writePESOptionalHeader(astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &bytes.Buffer{}}), h)
+		assert.Equal(t, ErrPTSDTSIndicatorInvalid, err)
+	}
+}
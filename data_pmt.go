@@ -32,6 +32,7 @@ const (
 	StreamTypeAC3Audio                   StreamType = 0x81
 	StreamTypeDTSAudio                   StreamType = 0x82
 	StreamTypeTRUEHDAudio                StreamType = 0x83
+	StreamTypeSCTE35                     StreamType = 0x86 // SCTE-35 splice info sections
 	StreamTypeEAC3Audio                  StreamType = 0x87
 )
 
@@ -42,6 +43,7 @@ type PMTData struct {
 	PCRPID             uint16        // The packet identifier that contains the program clock reference used to improve the random access accuracy of the stream's timing that is derived from the program timestamp. If this is unused. then it is set to 0x1FFF (all bits on).
 	ProgramDescriptors []*Descriptor // Program descriptors
 	ProgramNumber      uint16
+	ReservedBits       *ReservedBits // Only used when writing, spec values are used when nil
 }
 
 // PMTElementaryStream represents a PMT elementary stream
@@ -51,6 +53,36 @@ type PMTElementaryStream struct {
 	StreamType                  StreamType    // This defines the structure of the data contained within the elementary packet identifier.
 }
 
+// clone returns a deep copy of the PMT data
+func (d *PMTData) clone() PMTData {
+	c := *d
+	c.ProgramDescriptors = cloneDescriptors(d.ProgramDescriptors)
+	if d.ReservedBits != nil {
+		rb := *d.ReservedBits
+		c.ReservedBits = &rb
+	}
+	c.ElementaryStreams = make([]*PMTElementaryStream, 0, len(d.ElementaryStreams))
+	for _, es := range d.ElementaryStreams {
+		ces := *es
+		ces.ElementaryStreamDescriptors = cloneDescriptors(es.ElementaryStreamDescriptors)
+		c.ElementaryStreams = append(c.ElementaryStreams, &ces)
+	}
+	return c
+}
+
+// cloneDescriptors copies descriptors so that modifying their top level fields doesn't alter the originals
+func cloneDescriptors(ds []*Descriptor) []*Descriptor {
+	if ds == nil {
+		return nil
+	}
+	c := make([]*Descriptor, 0, len(ds))
+	for _, d := range ds {
+		cd := *d
+		c = append(c, &cd)
+	}
+	return c
+}
+
 // parsePMTSection parses a PMT section
 func parsePMTSection(i *astikit.BytesIterator, offsetSectionsEnd int, tableIDExtension uint16) (d *PMTData, err error) {
 	// Create data
@@ -133,15 +165,16 @@ func calcPMTSectionLength(d *PMTData) uint16 {
 }
 
 func writePMTSection(w *astikit.BitsWriter, d *PMTData) (int, error) {
+	rb := d.ReservedBits.orDefault()
 	b := astikit.NewBitsWriterBatch(w)
 
 	// TODO split into sections
 
-	b.WriteN(uint8(0xff), 3)
+	b.WriteN(rb.Reserved, 3)
 	b.WriteN(d.PCRPID, 13)
 	bytesWritten := 2
 
-	n, err := writeDescriptorsWithLength(w, d.ProgramDescriptors)
+	n, err := writeDescriptorsWithReservedAndLength(w, d.ProgramDescriptors, rb.Reserved)
 	if err != nil {
 		return 0, err
 	}
@@ -149,11 +182,11 @@ func writePMTSection(w *astikit.BitsWriter, d *PMTData) (int, error) {
 
 	for _, es := range d.ElementaryStreams {
 		b.Write(uint8(es.StreamType))
-		b.WriteN(uint8(0xff), 3)
+		b.WriteN(rb.Reserved, 3)
 		b.WriteN(es.ElementaryPID, 13)
 		bytesWritten += 3
 
-		n, err = writeDescriptorsWithLength(w, es.ElementaryStreamDescriptors)
+		n, err = writeDescriptorsWithReservedAndLength(w, es.ElementaryStreamDescriptors, rb.Reserved)
 		if err != nil {
 			return 0, err
 		}
@@ -233,6 +266,8 @@ func (t StreamType) String() string {
 		return "TRUEHD Audio"
 	case StreamTypeEAC3Audio:
 		return "EAC3 Audio"
+	case StreamTypeSCTE35:
+		return "SCTE 35"
 	}
 	return "Unknown"
 }
@@ -248,9 +283,9 @@ func (t StreamType) ToPESStreamID() uint8 {
 		return 0xc0
 	case StreamTypeAC3Audio, StreamTypeEAC3Audio: // m2ts_mode???
 		return 0xfd
-	case StreamTypePrivateSection, StreamTypePrivateData, StreamTypeMetadata:
+	case StreamTypePrivateSection, StreamTypeMetadata:
 		return 0xfc
-	default:
+	default: // private data such as DVB teletext and subtitles is carried in private_stream_1
 		return 0xbd
 	}
 }
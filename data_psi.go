@@ -11,16 +11,21 @@ const (
 	PSITableTypeBAT     = "BAT"
 	PSITableTypeDIT     = "DIT"
 	PSITableTypeEIT     = "EIT"
+	PSITableTypeMGT     = "MGT"
 	PSITableTypeNIT     = "NIT"
 	PSITableTypeNull    = "Null"
 	PSITableTypePAT     = "PAT"
 	PSITableTypePMT     = "PMT"
 	PSITableTypeRST     = "RST"
+	PSITableTypeSCTE35  = "SCTE35"
 	PSITableTypeSDT     = "SDT"
 	PSITableTypeSIT     = "SIT"
 	PSITableTypeST      = "ST"
+	PSITableTypeSTT     = "STT"
 	PSITableTypeTDT     = "TDT"
 	PSITableTypeTOT     = "TOT"
+	PSITableTypeTSDT    = "TSDT"
+	PSITableTypeTVCT    = "TVCT"
 	PSITableTypeUnknown = "Unknown"
 )
 
@@ -29,6 +34,7 @@ type PSITableID uint16
 const (
 	PSITableIDPAT  PSITableID = 0x00
 	PSITableIDPMT  PSITableID = 0x02
+	PSITableIDTSDT PSITableID = 0x03
 	PSITableIDBAT  PSITableID = 0x4a
 	PSITableIDDIT  PSITableID = 0x7e
 	PSITableIDRST  PSITableID = 0x71
@@ -46,6 +52,9 @@ const (
 	PSITableIDNITVariant2 PSITableID = 0x41
 )
 
+// Sections can't be longer than 1024 bytes, section_length excluded
+const psiSectionMaxLength = 1021
+
 // PSIData represents a PSI data
 // https://en.wikipedia.org/wiki/Program-specific_information
 type PSIData struct {
@@ -55,9 +64,10 @@ type PSIData struct {
 
 // PSISection represents a PSI section
 type PSISection struct {
-	CRC32  uint32 // A checksum of the entire table excluding the pointer field, pointer filler bytes and the trailing CRC32.
-	Header *PSISectionHeader
-	Syntax *PSISectionSyntax
+	CRC32    uint32 // A checksum of the entire table excluding the pointer field, pointer filler bytes and the trailing CRC32.
+	CRCValid bool   // Only set when parsing. Whether the CRC32 matches the computed one, sections without CRC32 are always valid. Can only be false when the CRC32 check is skipped.
+	Header   *PSISectionHeader
+	Syntax   *PSISectionSyntax
 }
 
 // PSISectionHeader represents a PSI section header
@@ -86,16 +96,24 @@ type PSISectionSyntaxHeader struct {
 
 // PSISectionSyntaxData represents a PSI section syntax data
 type PSISectionSyntaxData struct {
-	EIT *EITData
-	NIT *NITData
-	PAT *PATData
-	PMT *PMTData
-	SDT *SDTData
-	TOT *TOTData
+	ATSCMGT  *ATSCMGTData
+	ATSCSTT  *ATSCSTTData
+	ATSCTVCT *ATSCTVCTData
+	EIT      *EITData
+	NIT      *NITData
+	PAT      *PATData
+	PMT      *PMTData
+	Raw      []byte // Only used when writing tables that can't be generated, such as private tables. Written as is.
+	SCTE35   *SCTE35Data
+	SDT      *SDTData
+	TDT      *TDTData
+	TOT      *TOTData
+	TSDT     *TSDTData
 }
 
 // parsePSIData parses a PSI data
-func parsePSIData(i *astikit.BytesIterator) (d *PSIData, err error) {
+// If skipCRCCheck is true, sections with an invalid CRC32 are returned instead of failing
+func parsePSIData(i *astikit.BytesIterator, skipCRCCheck bool) (d *PSIData, err error) {
 	// Init data
 	d = &PSIData{}
 
@@ -116,7 +134,7 @@ func parsePSIData(i *astikit.BytesIterator) (d *PSIData, err error) {
 	var s *PSISection
 	var stop bool
 	for i.HasBytesLeft() && !stop {
-		if s, stop, err = parsePSISection(i); err != nil {
+		if s, stop, err = parsePSISection(i, skipCRCCheck); err != nil {
 			err = fmt.Errorf("astits: parsing PSI table failed: %w", err)
 			return
 		}
@@ -126,9 +144,9 @@ func parsePSIData(i *astikit.BytesIterator) (d *PSIData, err error) {
 }
 
 // parsePSISection parses a PSI section
-func parsePSISection(i *astikit.BytesIterator) (s *PSISection, stop bool, err error) {
+func parsePSISection(i *astikit.BytesIterator, skipCRCCheck bool) (s *PSISection, stop bool, err error) {
 	// Init section
-	s = &PSISection{}
+	s = &PSISection{CRCValid: true}
 
 	// Parse header
 	var offsetStart, offsetSectionsEnd, offsetEnd int
@@ -175,8 +193,11 @@ func parsePSISection(i *astikit.BytesIterator) (s *PSISection, stop bool, err er
 
 			// Check CRC32
 			if crc32 != s.CRC32 {
-				err = fmt.Errorf("astits: Table CRC32 %x != computed CRC32 %x", s.CRC32, crc32)
-				return
+				s.CRCValid = false
+				if !skipCRCCheck {
+					err = fmt.Errorf("astits: Table CRC32 %x != computed CRC32 %x", s.CRC32, crc32)
+					return
+				}
 			}
 		}
 	}
@@ -264,6 +285,8 @@ func (t PSITableID) Type() string {
 		return PSITableTypeEIT
 	case t == PSITableIDDIT:
 		return PSITableTypeDIT
+	case t == PSITableIDMGT:
+		return PSITableTypeMGT
 	case t == PSITableIDNITVariant1, t == PSITableIDNITVariant2:
 		return PSITableTypeNIT
 	case t == PSITableIDNull:
@@ -274,16 +297,24 @@ func (t PSITableID) Type() string {
 		return PSITableTypePMT
 	case t == PSITableIDRST:
 		return PSITableTypeRST
+	case t == PSITableIDSCTE35:
+		return PSITableTypeSCTE35
 	case t == PSITableIDSDTVariant1, t == PSITableIDSDTVariant2:
 		return PSITableTypeSDT
 	case t == PSITableIDSIT:
 		return PSITableTypeSIT
 	case t == PSITableIDST:
 		return PSITableTypeST
+	case t == PSITableIDSTT:
+		return PSITableTypeSTT
 	case t == PSITableIDTDT:
 		return PSITableTypeTDT
 	case t == PSITableIDTOT:
 		return PSITableTypeTOT
+	case t == PSITableIDTSDT:
+		return PSITableTypeTSDT
+	case t == PSITableIDTVCT:
+		return PSITableTypeTVCT
 	default:
 		return PSITableTypeUnknown
 	}
@@ -293,6 +324,8 @@ func (t PSITableID) Type() string {
 func (t PSITableID) hasPSISyntaxHeader() bool {
 	return t == PSITableIDPAT ||
 		t == PSITableIDPMT ||
+		t == PSITableIDTSDT ||
+		t == PSITableIDMGT || t == PSITableIDSTT || t == PSITableIDTVCT ||
 		t == PSITableIDNITVariant1 || t == PSITableIDNITVariant2 ||
 		t == PSITableIDSDTVariant1 || t == PSITableIDSDTVariant2 ||
 		(t >= PSITableIDEITStart && t <= PSITableIDEITEnd)
@@ -302,7 +335,10 @@ func (t PSITableID) hasPSISyntaxHeader() bool {
 func (t PSITableID) hasCRC32() bool {
 	return t == PSITableIDPAT ||
 		t == PSITableIDPMT ||
+		t == PSITableIDTSDT ||
+		t == PSITableIDMGT || t == PSITableIDSTT || t == PSITableIDTVCT ||
 		t == PSITableIDTOT ||
+		t == PSITableIDSCTE35 ||
 		t == PSITableIDNITVariant1 || t == PSITableIDNITVariant2 ||
 		t == PSITableIDSDTVariant1 || t == PSITableIDSDTVariant2 ||
 		(t >= PSITableIDEITStart && t <= PSITableIDEITEnd)
@@ -312,16 +348,21 @@ func (t PSITableID) isUnknown() bool {
 	switch t {
 	case PSITableIDBAT,
 		PSITableIDDIT,
+		PSITableIDMGT,
 		PSITableIDNITVariant1, PSITableIDNITVariant2,
 		PSITableIDNull,
 		PSITableIDPAT,
 		PSITableIDPMT,
 		PSITableIDRST,
+		PSITableIDSCTE35,
 		PSITableIDSDTVariant1, PSITableIDSDTVariant2,
 		PSITableIDSIT,
 		PSITableIDST,
+		PSITableIDSTT,
 		PSITableIDTDT,
-		PSITableIDTOT:
+		PSITableIDTOT,
+		PSITableIDTSDT,
+		PSITableIDTVCT:
 		return false
 	}
 	if t >= PSITableIDEITStart && t <= PSITableIDEITEnd {
@@ -410,6 +451,11 @@ func parsePSISectionSyntaxData(i *astikit.BytesIterator, h *PSISectionHeader, sh
 		// TODO Parse BAT
 	case PSITableIDDIT:
 		// TODO Parse DIT
+	case PSITableIDMGT:
+		if d.ATSCMGT, err = parseATSCMGTSection(i, sh.VersionNumber); err != nil {
+			err = fmt.Errorf("astits: parsing ATSC MGT section failed: %w", err)
+			return
+		}
 	case PSITableIDNITVariant1, PSITableIDNITVariant2:
 		if d.NIT, err = parseNITSection(i, sh.TableIDExtension); err != nil {
 			err = fmt.Errorf("astits: parsing NIT section failed: %w", err)
@@ -427,6 +473,11 @@ func parsePSISectionSyntaxData(i *astikit.BytesIterator, h *PSISectionHeader, sh
 		}
 	case PSITableIDRST:
 		// TODO Parse RST
+	case PSITableIDSCTE35:
+		if d.SCTE35, err = parseSCTE35Section(i, offsetSectionsEnd); err != nil {
+			err = fmt.Errorf("astits: parsing SCTE-35 section failed: %w", err)
+			return
+		}
 	case PSITableIDSDTVariant1, PSITableIDSDTVariant2:
 		if d.SDT, err = parseSDTSection(i, offsetSectionsEnd, sh.TableIDExtension); err != nil {
 			err = fmt.Errorf("astits: parsing PMT section failed: %w", err)
@@ -436,13 +487,31 @@ func parsePSISectionSyntaxData(i *astikit.BytesIterator, h *PSISectionHeader, sh
 		// TODO Parse SIT
 	case PSITableIDST:
 		// TODO Parse ST
+	case PSITableIDSTT:
+		if d.ATSCSTT, err = parseATSCSTTSection(i); err != nil {
+			err = fmt.Errorf("astits: parsing ATSC STT section failed: %w", err)
+			return
+		}
 	case PSITableIDTOT:
 		if d.TOT, err = parseTOTSection(i); err != nil {
 			err = fmt.Errorf("astits: parsing TOT section failed: %w", err)
 			return
 		}
 	case PSITableIDTDT:
-		// TODO Parse TDT
+		if d.TDT, err = parseTDTSection(i); err != nil {
+			err = fmt.Errorf("astits: parsing TDT section failed: %w", err)
+			return
+		}
+	case PSITableIDTSDT:
+		if d.TSDT, err = parseTSDTSection(i, offsetSectionsEnd); err != nil {
+			err = fmt.Errorf("astits: parsing TSDT section failed: %w", err)
+			return
+		}
+	case PSITableIDTVCT:
+		if d.ATSCTVCT, err = parseATSCTVCTSection(i, sh.TableIDExtension, sh.VersionNumber); err != nil {
+			err = fmt.Errorf("astits: parsing ATSC TVCT section failed: %w", err)
+			return
+		}
 	}
 
 	if h.TableID >= PSITableIDEITStart && h.TableID <= PSITableIDEITEnd {
@@ -461,19 +530,31 @@ func (d *PSIData) toData(firstPacket *Packet, pid uint16) (ds []*DemuxerData) {
 	for _, s := range d.Sections {
 		// Switch on table type
 		switch s.Header.TableID {
+		case PSITableIDMGT:
+			ds = append(ds, &DemuxerData{ATSCMGT: s.Syntax.Data.ATSCMGT, CRCValid: s.CRCValid, FirstPacket: firstPacket, PID: pid})
 		case PSITableIDNITVariant1, PSITableIDNITVariant2:
-			ds = append(ds, &DemuxerData{FirstPacket: firstPacket, NIT: s.Syntax.Data.NIT, PID: pid})
+			ds = append(ds, &DemuxerData{CRCValid: s.CRCValid, FirstPacket: firstPacket, NIT: s.Syntax.Data.NIT, PID: pid})
 		case PSITableIDPAT:
-			ds = append(ds, &DemuxerData{FirstPacket: firstPacket, PAT: s.Syntax.Data.PAT, PID: pid})
+			ds = append(ds, &DemuxerData{CRCValid: s.CRCValid, FirstPacket: firstPacket, PAT: s.Syntax.Data.PAT, PID: pid})
 		case PSITableIDPMT:
-			ds = append(ds, &DemuxerData{FirstPacket: firstPacket, PID: pid, PMT: s.Syntax.Data.PMT})
+			ds = append(ds, &DemuxerData{CRCValid: s.CRCValid, FirstPacket: firstPacket, PID: pid, PMT: s.Syntax.Data.PMT})
+		case PSITableIDSCTE35:
+			ds = append(ds, &DemuxerData{CRCValid: s.CRCValid, FirstPacket: firstPacket, PID: pid, SCTE35: s.Syntax.Data.SCTE35})
 		case PSITableIDSDTVariant1, PSITableIDSDTVariant2:
-			ds = append(ds, &DemuxerData{FirstPacket: firstPacket, PID: pid, SDT: s.Syntax.Data.SDT})
+			ds = append(ds, &DemuxerData{CRCValid: s.CRCValid, FirstPacket: firstPacket, PID: pid, SDT: s.Syntax.Data.SDT})
+		case PSITableIDSTT:
+			ds = append(ds, &DemuxerData{ATSCSTT: s.Syntax.Data.ATSCSTT, CRCValid: s.CRCValid, FirstPacket: firstPacket, PID: pid})
+		case PSITableIDTDT:
+			ds = append(ds, &DemuxerData{CRCValid: s.CRCValid, FirstPacket: firstPacket, PID: pid, TDT: s.Syntax.Data.TDT})
 		case PSITableIDTOT:
-			ds = append(ds, &DemuxerData{FirstPacket: firstPacket, PID: pid, TOT: s.Syntax.Data.TOT})
+			ds = append(ds, &DemuxerData{CRCValid: s.CRCValid, FirstPacket: firstPacket, PID: pid, TOT: s.Syntax.Data.TOT})
+		case PSITableIDTSDT:
+			ds = append(ds, &DemuxerData{CRCValid: s.CRCValid, FirstPacket: firstPacket, PID: pid, TSDT: s.Syntax.Data.TSDT})
+		case PSITableIDTVCT:
+			ds = append(ds, &DemuxerData{ATSCTVCT: s.Syntax.Data.ATSCTVCT, CRCValid: s.CRCValid, FirstPacket: firstPacket, PID: pid})
 		}
 		if s.Header.TableID >= PSITableIDEITStart && s.Header.TableID <= PSITableIDEITEnd {
-			ds = append(ds, &DemuxerData{EIT: s.Syntax.Data.EIT, FirstPacket: firstPacket, PID: pid})
+			ds = append(ds, &DemuxerData{EIT: s.Syntax.Data.EIT, CRCValid: s.CRCValid, FirstPacket: firstPacket, PID: pid})
 		}
 	}
 	return
@@ -503,20 +584,66 @@ func writePSIData(w *astikit.BitsWriter, d *PSIData) (int, error) {
 	return bytesWritten, nil
 }
 
+// isRaw checks whether the section data is written as is
+func (s *PSISection) isRaw() bool {
+	return s.Syntax != nil && s.Syntax.Data != nil && s.Syntax.Data.Raw != nil
+}
+
+// isWritable checks whether the section can be written
+func (s *PSISection) isWritable() bool {
+	switch s.Header.TableID {
+	case PSITableIDPAT, PSITableIDPMT, PSITableIDSDTVariant1, PSITableIDNITVariant1, PSITableIDTDT, PSITableIDTOT, PSITableIDTSDT,
+		PSITableIDSCTE35:
+		return true
+	}
+	return s.isRaw()
+}
+
+// hasSyntaxHeader checks whether a syntax header is written
+// Raw sections follow their section syntax indicator so that private tables can be written either way
+func (s *PSISection) hasSyntaxHeader() bool {
+	if s.isRaw() {
+		return s.Header.SectionSyntaxIndicator
+	}
+	return s.Header.TableID.hasPSISyntaxHeader()
+}
+
+// hasCRC32 checks whether a CRC32 is written
+func (s *PSISection) hasCRC32() bool {
+	if s.isRaw() {
+		return s.Header.SectionSyntaxIndicator
+	}
+	return s.Header.TableID.hasCRC32()
+}
+
 func calcPSISectionLength(s *PSISection) uint16 {
 	ret := uint16(0)
-	if s.Header.TableID.hasPSISyntaxHeader() {
+	if s.hasSyntaxHeader() {
 		ret += 5 // PSI syntax header length
 	}
 
-	switch s.Header.TableID {
-	case PSITableIDPAT:
+	switch {
+	case s.isRaw():
+		ret += uint16(len(s.Syntax.Data.Raw))
+	case s.Header.TableID == PSITableIDPAT:
 		ret += calcPATSectionLength(s.Syntax.Data.PAT)
-	case PSITableIDPMT:
+	case s.Header.TableID == PSITableIDPMT:
 		ret += calcPMTSectionLength(s.Syntax.Data.PMT)
-	}
-
-	if s.Header.TableID.hasCRC32() {
+	case s.Header.TableID == PSITableIDSDTVariant1:
+		ret += calcSDTSectionLength(s.Syntax.Data.SDT)
+	case s.Header.TableID == PSITableIDNITVariant1:
+		ret += calcNITSectionLength(s.Syntax.Data.NIT)
+	case s.Header.TableID == PSITableIDSCTE35:
+		ret += calcSCTE35SectionLength(s.Syntax.Data.SCTE35)
+	case s.Header.TableID == PSITableIDTDT:
+		ret += calcTDTSectionLength(s.Syntax.Data.TDT)
+	case s.Header.TableID == PSITableIDTOT:
+		ret += calcTOTSectionLength(s.Syntax.Data.TOT)
+	case s.Header.TableID == PSITableIDTSDT:
+		ret += calcTSDTSectionLength(s.Syntax.Data.TSDT)
+	}
+
+	if s.hasCRC32() {
 		ret += 4
 	}
 
@@ -524,7 +651,7 @@ func calcPSISectionLength(s *PSISection) uint16 {
 }
 
 func writePSISection(w *astikit.BitsWriter, s *PSISection) (int, error) {
-	if s.Header.TableID != PSITableIDPAT && s.Header.TableID != PSITableIDPMT {
+	if !s.isWritable() {
 		return 0, fmt.Errorf("writePSISection: table %s is not implemented", s.Header.TableID.Type())
 	}
 
@@ -533,7 +660,7 @@ func writePSISection(w *astikit.BitsWriter, s *PSISection) (int, error) {
 	sectionLength := calcPSISectionLength(s)
 	sectionCRC32 := crc32Polynomial
 
-	if s.Header.TableID.hasCRC32() {
+	if s.hasCRC32() {
 		w.SetWriteCallback(func(bs []byte) {
 			sectionCRC32 = updateCRC32(sectionCRC32, bs)
 		})
@@ -554,7 +681,7 @@ func writePSISection(w *astikit.BitsWriter, s *PSISection) (int, error) {
 		}
 		bytesWritten += n
 
-		if s.Header.TableID.hasCRC32() {
+		if s.hasCRC32() {
 			b.Write(sectionCRC32)
 			bytesWritten += 4
 		}
@@ -565,7 +692,7 @@ func writePSISection(w *astikit.BitsWriter, s *PSISection) (int, error) {
 
 func writePSISectionSyntax(w *astikit.BitsWriter, s *PSISection) (int, error) {
 	bytesWritten := 0
-	if s.Header.TableID.hasPSISyntaxHeader() {
+	if s.hasSyntaxHeader() {
 		n, err := writePSISectionSyntaxHeader(w, s.Syntax.Header)
 		if err != nil {
 			return 0, err
@@ -596,12 +723,30 @@ func writePSISectionSyntaxHeader(w *astikit.BitsWriter, h *PSISectionSyntaxHeade
 }
 
 func writePSISectionSyntaxData(w *astikit.BitsWriter, d *PSISectionSyntaxData, tableID PSITableID) (int, error) {
+	if d.Raw != nil {
+		b := astikit.NewBitsWriterBatch(w)
+		b.Write(d.Raw)
+		return len(d.Raw), b.Err()
+	}
+
 	switch tableID {
 	// TODO write other table types
 	case PSITableIDPAT:
 		return writePATSection(w, d.PAT)
 	case PSITableIDPMT:
 		return writePMTSection(w, d.PMT)
+	case PSITableIDSDTVariant1:
+		return writeSDTSection(w, d.SDT)
+	case PSITableIDNITVariant1:
+		return writeNITSection(w, d.NIT)
+	case PSITableIDSCTE35:
+		return writeSCTE35Section(w, d.SCTE35)
+	case PSITableIDTDT:
+		return writeTDTSection(w, d.TDT)
+	case PSITableIDTOT:
+		return writeTOTSection(w, d.TOT)
+	case PSITableIDTSDT:
+		return writeTSDTSection(w, d.TSDT)
 	}
 
 	return 0, nil
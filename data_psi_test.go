@@ -12,7 +12,8 @@ var psi = &PSIData{
 	PointerField: 4,
 	Sections: []*PSISection{
 		{
-			CRC32: uint32(0x7ffc6102),
+			CRC32:    uint32(0x7ffc6102),
+			CRCValid: true,
 			Header: &PSISectionHeader{
 				PrivateBit:             true,
 				SectionLength:          30,
@@ -26,7 +27,8 @@ var psi = &PSIData{
 			},
 		},
 		{
-			CRC32: uint32(0xfebaa941),
+			CRC32:    uint32(0xfebaa941),
+			CRCValid: true,
 			Header: &PSISectionHeader{
 				PrivateBit:             true,
 				SectionLength:          25,
@@ -40,7 +42,8 @@ var psi = &PSIData{
 			},
 		},
 		{
-			CRC32: uint32(0x60739f61),
+			CRC32:    uint32(0x60739f61),
+			CRCValid: true,
 			Header: &PSISectionHeader{
 				PrivateBit:             true,
 				SectionLength:          17,
@@ -54,7 +57,8 @@ var psi = &PSIData{
 			},
 		},
 		{
-			CRC32: uint32(0xc68442e8),
+			CRC32:    uint32(0xc68442e8),
+			CRCValid: true,
 			Header: &PSISectionHeader{
 				PrivateBit:             true,
 				SectionLength:          24,
@@ -68,7 +72,8 @@ var psi = &PSIData{
 			},
 		},
 		{
-			CRC32: uint32(0xef3751d6),
+			CRC32:    uint32(0xef3751d6),
+			CRCValid: true,
 			Header: &PSISectionHeader{
 				PrivateBit:             true,
 				SectionLength:          20,
@@ -82,7 +87,8 @@ var psi = &PSIData{
 			},
 		},
 		{
-			CRC32: uint32(0x6969b13),
+			CRC32:    uint32(0x6969b13),
+			CRCValid: true,
 			Header: &PSISectionHeader{
 				PrivateBit:             true,
 				SectionLength:          14,
@@ -94,7 +100,7 @@ var psi = &PSIData{
 				Data: &PSISectionSyntaxData{TOT: tot},
 			},
 		},
-		{Header: &PSISectionHeader{TableID: 254, TableType: PSITableTypeUnknown}},
+		{CRCValid: true, Header: &PSISectionHeader{TableID: 254, TableType: PSITableTypeUnknown}},
 	},
 }
 
@@ -167,11 +173,20 @@ func TestParsePSIData(t *testing.T) {
 	w.Write("000000001110") // TOT section length
 	w.Write(totBytes())     // TOT data
 	w.Write(uint32(32))     // TOT CRC32
-	_, err := parsePSIData(astikit.NewBytesIterator(buf.Bytes()))
+	_, err := parsePSIData(astikit.NewBytesIterator(buf.Bytes()), false)
 	assert.EqualError(t, err, "astits: parsing PSI table failed: astits: Table CRC32 20 != computed CRC32 6969b13")
 
+	// Invalid CRC32 with CRC32 check skipped
+	d, err := parsePSIData(astikit.NewBytesIterator(buf.Bytes()), true)
+	assert.NoError(t, err)
+	if assert.Len(t, d.Sections, 1) {
+		assert.False(t, d.Sections[0].CRCValid)
+		assert.Equal(t, uint32(32), d.Sections[0].CRC32)
+		assert.Equal(t, tot, d.Sections[0].Syntax.Data.TOT)
+	}
+
 	// Valid
-	d, err := parsePSIData(astikit.NewBytesIterator(psiBytes()))
+	d, err = parsePSIData(astikit.NewBytesIterator(psiBytes()), false)
 	assert.NoError(t, err)
 	assert.Equal(t, d, psi)
 }
@@ -270,12 +285,12 @@ func TestParsePSISectionSyntaxHeader(t *testing.T) {
 func TestPSIToData(t *testing.T) {
 	p := &Packet{}
 	assert.Equal(t, []*DemuxerData{
-		{EIT: eit, FirstPacket: p, PID: 2},
-		{FirstPacket: p, NIT: nit, PID: 2},
-		{FirstPacket: p, PAT: pat, PID: 2},
-		{FirstPacket: p, PMT: pmt, PID: 2},
-		{FirstPacket: p, SDT: sdt, PID: 2},
-		{FirstPacket: p, TOT: tot, PID: 2},
+		{CRCValid: true, EIT: eit, FirstPacket: p, PID: 2},
+		{CRCValid: true, FirstPacket: p, NIT: nit, PID: 2},
+		{CRCValid: true, FirstPacket: p, PAT: pat, PID: 2},
+		{CRCValid: true, FirstPacket: p, PMT: pmt, PID: 2},
+		{CRCValid: true, FirstPacket: p, SDT: sdt, PID: 2},
+		{CRCValid: true, FirstPacket: p, TOT: tot, PID: 2},
 	}, psi.toData(p, uint16(2)))
 }
 
@@ -375,9 +390,41 @@ func TestWritePSIData(t *testing.T) {
 	}
 }
 
+func TestWritePSISectionRaw(t *testing.T) {
+	// Private section without syntax header nor CRC32
+	buf := bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &buf})
+	s := &PSISection{
+		Header: &PSISectionHeader{
+			PrivateBit:    true,
+			SectionLength: 3,
+			TableID:       0xc7,
+		},
+		Syntax: &PSISectionSyntax{Data: &PSISectionSyntaxData{Raw: []byte{1, 2, 3}}},
+	}
+	n, err := writePSISection(w, s)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, []byte{0xc7, 0x70, 0x03, 1, 2, 3}, buf.Bytes())
+
+	// Private section with syntax header and CRC32
+	buf.Reset()
+	s.Header.SectionSyntaxIndicator = true
+	s.Header.PrivateBit = false
+	s.Syntax.Header = &PSISectionSyntaxHeader{
+		CurrentNextIndicator: true,
+		TableIDExtension:     1,
+		VersionNumber:        2,
+	}
+	n, err = writePSISection(w, s)
+	assert.NoError(t, err)
+	assert.Equal(t, 15, n)
+	assert.Equal(t, []byte{0xc7, 0xb0, 0x0c, 0x00, 0x01, 0xc5, 0x00, 0x00, 1, 2, 3}, buf.Bytes()[:11])
+}
+
 func BenchmarkParsePSIData(b *testing.B) {
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		parsePSIData(astikit.NewBytesIterator(psiBytes()))
+		parsePSIData(astikit.NewBytesIterator(psiBytes()), false)
 	}
 }
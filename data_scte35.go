@@ -0,0 +1,522 @@
+package astits
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/asticode/go-astikit"
+)
+
+// PSITableIDSCTE35 is the table id of SCTE-35 splice info sections
+const PSITableIDSCTE35 PSITableID = 0xfc
+
+// SCTE-35 splice command types
+// Chapter: 9.6 | Link: https://account.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+const (
+	SCTE35SpliceCommandTypeBandwidthReservation = 0x07
+	SCTE35SpliceCommandTypePrivateCommand       = 0xff
+	SCTE35SpliceCommandTypeSpliceInsert         = 0x05
+	SCTE35SpliceCommandTypeSpliceNull           = 0x00
+	SCTE35SpliceCommandTypeSpliceSchedule       = 0x04
+	SCTE35SpliceCommandTypeTimeSignal           = 0x06
+)
+
+// scte35SpliceCommandLengthUnknown is the splice command length of legacy sections, where the command must be parsed
+// to know its length
+const scte35SpliceCommandLengthUnknown = 0xfff
+
+var (
+	// ErrSCTE35SpliceCommandLengthUnknown is returned when the length of a splice command that can't be parsed is
+	// unknown
+	ErrSCTE35SpliceCommandLengthUnknown = errors.New("astits: SCTE-35 splice command length unknown")
+	// ErrSCTE35EncryptedPacket is returned when writing an encrypted SCTE-35 section, which isn't supported
+	ErrSCTE35EncryptedPacket = errors.New("astits: SCTE-35 encrypted packet")
+	// ErrSCTE35SpliceCommandInvalid is returned when writing a SCTE-35 section whose splice command type doesn't match
+	// its splice command
+	ErrSCTE35SpliceCommandInvalid = errors.New("astits: SCTE-35 splice command invalid")
+)
+
+// SCTE35Data represents a SCTE-35 splice info section, carried on a PID whose stream type is StreamTypeSCTE35
+// Chapter: 9.6 | Link: https://account.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+type SCTE35Data struct {
+	CWIndex             uint8
+	EncryptedPacket     bool // When set, the splice command and descriptors are encrypted and therefore not parsed
+	EncryptionAlgorithm uint8
+	ProtocolVersion     uint8
+	PTSAdjustment       *ClockReference
+	SpliceCommand       []byte // Raw splice command, set for commands other than splice_insert and time_signal
+	SpliceCommandType   uint8
+	SpliceDescriptors   []*SCTE35SpliceDescriptor
+	SpliceInsert        *SCTE35SpliceInsert
+	Tier                uint16
+	TimeSignal          *SCTE35TimeSignal
+}
+
+// SCTE35SpliceInsert represents a SCTE-35 splice_insert command
+// Chapter: 9.7.3 | Link: https://account.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+type SCTE35SpliceInsert struct {
+	AvailNum                   uint8
+	AvailsExpected             uint8
+	BreakDuration              *SCTE35BreakDuration // Set when the duration flag is set
+	Components                 []*SCTE35SpliceInsertComponent
+	OutOfNetworkIndicator      bool
+	ProgramSpliceFlag          bool
+	SpliceEventCancelIndicator bool // When set, only SpliceEventID is set
+	SpliceEventID              uint32
+	SpliceImmediateFlag        bool
+	SpliceTime                 *ClockReference // Set for program splices that are not immediate and whose time is specified
+	UniqueProgramID            uint16
+}
+
+// SCTE35SpliceInsertComponent represents a SCTE-35 splice_insert command component
+type SCTE35SpliceInsertComponent struct {
+	ComponentTag uint8
+	SpliceTime   *ClockReference // Set for splices that are not immediate and whose time is specified
+}
+
+// SCTE35BreakDuration represents a SCTE-35 break duration
+// Chapter: 10.3.2 | Link: https://account.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+type SCTE35BreakDuration struct {
+	AutoReturn bool
+	Duration   *ClockReference
+}
+
+// SCTE35TimeSignal represents a SCTE-35 time_signal command
+// Chapter: 9.7.4 | Link: https://account.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+type SCTE35TimeSignal struct {
+	SpliceTime *ClockReference // Not set when the time is not specified
+}
+
+// SCTE35SpliceDescriptor represents a SCTE-35 splice descriptor
+// Chapter: 10.2 | Link: https://account.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+type SCTE35SpliceDescriptor struct {
+	Data       []byte
+	Identifier uint32 // 0x43554549 ("CUEI") for descriptors defined by SCTE-35
+	Tag        uint8
+}
+
+// NewSCTE35SpliceInsert creates a SCTE-35 section holding a program splice_insert command, whose splice point is
+// the presentation time pts, to be written with Muxer.WriteSCTE35
+// An out of network splice signals the start of a break, and its return to the network the end.
+func NewSCTE35SpliceInsert(spliceEventID uint32, pts *ClockReference, outOfNetwork bool) *SCTE35Data {
+	return &SCTE35Data{
+		PTSAdjustment:     &ClockReference{},
+		SpliceCommandType: SCTE35SpliceCommandTypeSpliceInsert,
+		SpliceInsert: &SCTE35SpliceInsert{
+			OutOfNetworkIndicator: outOfNetwork,
+			ProgramSpliceFlag:     true,
+			SpliceEventID:         spliceEventID,
+			SpliceTime:            pts,
+		},
+		Tier: 0xfff, // all tiers
+	}
+}
+
+// parseSCTE35Section parses a SCTE-35 splice info section
+func parseSCTE35Section(i *astikit.BytesIterator, offsetSectionsEnd int) (d *SCTE35Data, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(11); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create data
+	d = &SCTE35Data{
+		CWIndex:             bs[6],
+		EncryptedPacket:     bs[1]&0x80 > 0,
+		EncryptionAlgorithm: bs[1] >> 1 & 0x3f,
+		ProtocolVersion:     bs[0],
+		PTSAdjustment:       newClockReference(int64(bs[1]&0x1)<<32|int64(bs[2])<<24|int64(bs[3])<<16|int64(bs[4])<<8|int64(bs[5]), 0),
+		SpliceCommandType:   bs[10],
+		Tier:                uint16(bs[7])<<4 | uint16(bs[8]>>4),
+	}
+	spliceCommandLength := int(bs[8]&0xf)<<8 | int(bs[9])
+
+	// Encrypted commands and descriptors can't be parsed
+	if d.EncryptedPacket {
+		i.Seek(offsetSectionsEnd)
+		return
+	}
+
+	// Splice command
+	offsetCommandStart := i.Offset()
+	switch d.SpliceCommandType {
+	case SCTE35SpliceCommandTypeSpliceInsert:
+		if d.SpliceInsert, err = parseSCTE35SpliceInsert(i); err != nil {
+			err = fmt.Errorf("astits: parsing splice insert failed: %w", err)
+			return
+		}
+	case SCTE35SpliceCommandTypeTimeSignal:
+		d.TimeSignal = &SCTE35TimeSignal{}
+		if d.TimeSignal.SpliceTime, err = parseSCTE35SpliceTime(i); err != nil {
+			err = fmt.Errorf("astits: parsing splice time failed: %w", err)
+			return
+		}
+	case SCTE35SpliceCommandTypeSpliceNull, SCTE35SpliceCommandTypeBandwidthReservation:
+	default:
+		if spliceCommandLength == scte35SpliceCommandLengthUnknown {
+			err = fmt.Errorf("astits: splice command type is %#x: %w", d.SpliceCommandType, ErrSCTE35SpliceCommandLengthUnknown)
+			return
+		}
+		if d.SpliceCommand, err = i.NextBytes(spliceCommandLength); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+	}
+	if spliceCommandLength != scte35SpliceCommandLengthUnknown {
+		i.Seek(offsetCommandStart + spliceCommandLength)
+	}
+
+	// Get next bytes
+	if bs, err = i.NextBytesNoCopy(2); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Loop through splice descriptors
+	offsetDescriptorsEnd := i.Offset() + int(uint16(bs[0])<<8|uint16(bs[1]))
+	for i.Offset() < offsetDescriptorsEnd {
+		// Get next bytes
+		if bs, err = i.NextBytesNoCopy(2); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+		sd := &SCTE35SpliceDescriptor{Tag: bs[0]}
+		length := int(bs[1])
+
+		// Get next bytes
+		if bs, err = i.NextBytes(length); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+		if len(bs) >= 4 {
+			sd.Identifier = uint32(bs[0])<<24 | uint32(bs[1])<<16 | uint32(bs[2])<<8 | uint32(bs[3])
+			sd.Data = bs[4:]
+		}
+		d.SpliceDescriptors = append(d.SpliceDescriptors, sd)
+	}
+
+	// Skip alignment stuffing
+	i.Seek(offsetSectionsEnd)
+	return
+}
+
+// parseSCTE35SpliceInsert parses a SCTE-35 splice_insert command
+func parseSCTE35SpliceInsert(i *astikit.BytesIterator) (s *SCTE35SpliceInsert, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(5); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create splice insert
+	s = &SCTE35SpliceInsert{
+		SpliceEventCancelIndicator: bs[4]&0x80 > 0,
+		SpliceEventID:              uint32(bs[0])<<24 | uint32(bs[1])<<16 | uint32(bs[2])<<8 | uint32(bs[3]),
+	}
+	if s.SpliceEventCancelIndicator {
+		return
+	}
+
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Flags
+	s.OutOfNetworkIndicator = b&0x80 > 0
+	s.ProgramSpliceFlag = b&0x40 > 0
+	durationFlag := b&0x20 > 0
+	s.SpliceImmediateFlag = b&0x10 > 0
+
+	// Splice time
+	if s.ProgramSpliceFlag && !s.SpliceImmediateFlag {
+		if s.SpliceTime, err = parseSCTE35SpliceTime(i); err != nil {
+			err = fmt.Errorf("astits: parsing splice time failed: %w", err)
+			return
+		}
+	}
+
+	// Components
+	if !s.ProgramSpliceFlag {
+		// Get next byte
+		if b, err = i.NextByte(); err != nil {
+			err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+			return
+		}
+
+		// Loop through components
+		for idx := 0; idx < int(b); idx++ {
+			// Get next byte
+			var tag byte
+			if tag, err = i.NextByte(); err != nil {
+				err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+				return
+			}
+
+			// Create component
+			c := &SCTE35SpliceInsertComponent{ComponentTag: tag}
+			if !s.SpliceImmediateFlag {
+				if c.SpliceTime, err = parseSCTE35SpliceTime(i); err != nil {
+					err = fmt.Errorf("astits: parsing splice time failed: %w", err)
+					return
+				}
+			}
+			s.Components = append(s.Components, c)
+		}
+	}
+
+	// Break duration
+	if durationFlag {
+		if bs, err = i.NextBytesNoCopy(5); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+		s.BreakDuration = &SCTE35BreakDuration{
+			AutoReturn: bs[0]&0x80 > 0,
+			Duration:   newClockReference(parseSCTE35Timestamp(bs), 0),
+		}
+	}
+
+	// Get next bytes
+	if bs, err = i.NextBytesNoCopy(4); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+	s.UniqueProgramID = uint16(bs[0])<<8 | uint16(bs[1])
+	s.AvailNum = bs[2]
+	s.AvailsExpected = bs[3]
+	return
+}
+
+// parseSCTE35SpliceTime parses a SCTE-35 splice_time, returning nil if the time is not specified
+func parseSCTE35SpliceTime(i *astikit.BytesIterator) (cr *ClockReference, err error) {
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Time is not specified
+	if b&0x80 == 0 {
+		return
+	}
+
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(4); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+	cr = newClockReference(parseSCTE35Timestamp(append([]byte{b}, bs...)), 0)
+	return
+}
+
+// parseSCTE35Timestamp parses the 33 bits timestamp ending a 5 bytes field
+func parseSCTE35Timestamp(bs []byte) int64 {
+	return int64(bs[0]&0x1)<<32 | int64(bs[1])<<24 | int64(bs[2])<<16 | int64(bs[3])<<8 | int64(bs[4])
+}
+
+// checkSCTE35SpliceCommand makes sure the splice command matching the splice command type is set
+func checkSCTE35SpliceCommand(d *SCTE35Data) error {
+	switch d.SpliceCommandType {
+	case SCTE35SpliceCommandTypeSpliceInsert:
+		if d.SpliceInsert == nil {
+			return fmt.Errorf("astits: splice command type is splice_insert but SpliceInsert is nil: %w", ErrSCTE35SpliceCommandInvalid)
+		}
+	case SCTE35SpliceCommandTypeTimeSignal:
+		if d.TimeSignal == nil {
+			return fmt.Errorf("astits: splice command type is time_signal but TimeSignal is nil: %w", ErrSCTE35SpliceCommandInvalid)
+		}
+	}
+	return nil
+}
+
+func calcSCTE35SectionLength(d *SCTE35Data) uint16 {
+	ret := uint16(11) // up to splice_command_type
+	ret += calcSCTE35SpliceCommandLength(d)
+	ret += 2 // descriptor_loop_length
+	ret += calcSCTE35SpliceDescriptorsLength(d.SpliceDescriptors)
+	return ret
+}
+
+func calcSCTE35SpliceCommandLength(d *SCTE35Data) uint16 {
+	switch d.SpliceCommandType {
+	case SCTE35SpliceCommandTypeSpliceInsert:
+		return calcSCTE35SpliceInsertLength(d.SpliceInsert)
+	case SCTE35SpliceCommandTypeTimeSignal:
+		return calcSCTE35SpliceTimeLength(d.TimeSignal.SpliceTime)
+	case SCTE35SpliceCommandTypeSpliceNull, SCTE35SpliceCommandTypeBandwidthReservation:
+		return 0
+	}
+	return uint16(len(d.SpliceCommand))
+}
+
+func calcSCTE35SpliceInsertLength(s *SCTE35SpliceInsert) uint16 {
+	ret := uint16(5) // splice_event_id and splice_event_cancel_indicator
+	if s.SpliceEventCancelIndicator {
+		return ret
+	}
+	ret++ // flags
+	if s.ProgramSpliceFlag && !s.SpliceImmediateFlag {
+		ret += calcSCTE35SpliceTimeLength(s.SpliceTime)
+	}
+	if !s.ProgramSpliceFlag {
+		ret++ // component_count
+		for _, c := range s.Components {
+			ret++ // component_tag
+			if !s.SpliceImmediateFlag {
+				ret += calcSCTE35SpliceTimeLength(c.SpliceTime)
+			}
+		}
+	}
+	if s.BreakDuration != nil {
+		ret += 5
+	}
+	ret += 4 // unique_program_id, avail_num and avails_expected
+	return ret
+}
+
+func calcSCTE35SpliceTimeLength(cr *ClockReference) uint16 {
+	if cr == nil {
+		return 1
+	}
+	return 5
+}
+
+func calcSCTE35SpliceDescriptorsLength(ds []*SCTE35SpliceDescriptor) uint16 {
+	ret := uint16(0)
+	for _, sd := range ds {
+		ret += 2 + 4 + uint16(len(sd.Data)) // tag, length and identifier
+	}
+	return ret
+}
+
+func writeSCTE35Section(w *astikit.BitsWriter, d *SCTE35Data) (int, error) {
+	if d.EncryptedPacket {
+		return 0, ErrSCTE35EncryptedPacket
+	}
+	if err := checkSCTE35SpliceCommand(d); err != nil {
+		return 0, err
+	}
+
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(d.ProtocolVersion)
+	b.Write(false) // encrypted_packet
+	b.WriteN(d.EncryptionAlgorithm, 6)
+	b.WriteN(scte35Timestamp(d.PTSAdjustment), 33)
+	b.Write(d.CWIndex)
+	b.WriteN(d.Tier, 12)
+	spliceCommandLength := calcSCTE35SpliceCommandLength(d)
+	b.WriteN(spliceCommandLength, 12)
+	b.Write(d.SpliceCommandType)
+	if err := b.Err(); err != nil {
+		return 0, err
+	}
+
+	var err error
+	switch d.SpliceCommandType {
+	case SCTE35SpliceCommandTypeSpliceInsert:
+		err = writeSCTE35SpliceInsert(w, d.SpliceInsert)
+	case SCTE35SpliceCommandTypeTimeSignal:
+		err = writeSCTE35SpliceTime(w, d.TimeSignal.SpliceTime)
+	case SCTE35SpliceCommandTypeSpliceNull, SCTE35SpliceCommandTypeBandwidthReservation:
+	default:
+		err = w.Write(d.SpliceCommand)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	descriptorsLength := calcSCTE35SpliceDescriptorsLength(d.SpliceDescriptors)
+	b.Write(descriptorsLength)
+	for _, sd := range d.SpliceDescriptors {
+		b.Write(sd.Tag)
+		b.Write(uint8(4 + len(sd.Data)))
+		b.Write(sd.Identifier)
+		b.Write(sd.Data)
+	}
+
+	return 11 + int(spliceCommandLength) + 2 + int(descriptorsLength), b.Err()
+}
+
+func writeSCTE35SpliceInsert(w *astikit.BitsWriter, s *SCTE35SpliceInsert) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(s.SpliceEventID)
+	b.Write(s.SpliceEventCancelIndicator)
+	b.WriteN(uint8(0xff), 7)
+	if s.SpliceEventCancelIndicator {
+		return b.Err()
+	}
+
+	b.Write(s.OutOfNetworkIndicator)
+	b.Write(s.ProgramSpliceFlag)
+	b.Write(s.BreakDuration != nil)
+	b.Write(s.SpliceImmediateFlag)
+	b.WriteN(uint8(0xff), 4)
+	if err := b.Err(); err != nil {
+		return err
+	}
+
+	if s.ProgramSpliceFlag && !s.SpliceImmediateFlag {
+		if err := writeSCTE35SpliceTime(w, s.SpliceTime); err != nil {
+			return err
+		}
+	}
+	if !s.ProgramSpliceFlag {
+		if err := w.Write(uint8(len(s.Components))); err != nil {
+			return err
+		}
+		for _, c := range s.Components {
+			if err := w.Write(c.ComponentTag); err != nil {
+				return err
+			}
+			if !s.SpliceImmediateFlag {
+				if err := writeSCTE35SpliceTime(w, c.SpliceTime); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if s.BreakDuration != nil {
+		b.Write(s.BreakDuration.AutoReturn)
+		b.WriteN(uint8(0xff), 6)
+		b.WriteN(scte35Timestamp(s.BreakDuration.Duration), 33)
+	}
+
+	b.Write(s.UniqueProgramID)
+	b.Write(s.AvailNum)
+	b.Write(s.AvailsExpected)
+
+	return b.Err()
+}
+
+// writeSCTE35SpliceTime writes a SCTE-35 splice_time, whose time is not specified if cr is nil
+func writeSCTE35SpliceTime(w *astikit.BitsWriter, cr *ClockReference) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(cr != nil)
+	if cr == nil {
+		b.WriteN(uint8(0xff), 7)
+	} else {
+		b.WriteN(uint8(0xff), 6)
+		b.WriteN(scte35Timestamp(cr), 33)
+	}
+
+	return b.Err()
+}
+
+// scte35Timestamp returns the 33 bits timestamp of cr, 0 if cr is nil
+func scte35Timestamp(cr *ClockReference) uint64 {
+	if cr == nil {
+		return 0
+	}
+	return uint64(cr.Base)
+}
@@ -0,0 +1,282 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+// Samples from SCTE-35 chapter 14
+const (
+	scte35SpliceInsertSample = "fc302f000000000000fffff014054800008f7feffe7369c02efe0052ccf500000000000a0008435545490000013562dba30a"
+	scte35TimeSignalSample   = "fc3034000000000000fffff00506fe72bd0050001e021c435545494800008e7fcf0001a599b00808000000002ca0a18a3402009ac9d17e"
+)
+
+var scte35SpliceInsert = &SCTE35Data{
+	CWIndex:           0xff,
+	PTSAdjustment:     &ClockReference{},
+	SpliceCommandType: SCTE35SpliceCommandTypeSpliceInsert,
+	SpliceDescriptors: []*SCTE35SpliceDescriptor{{
+		Data:       []byte{0x00, 0x00, 0x01, 0x35},
+		Identifier: 0x43554549,
+	}},
+	SpliceInsert: &SCTE35SpliceInsert{
+		BreakDuration: &SCTE35BreakDuration{
+			AutoReturn: true,
+			Duration:   &ClockReference{Base: 5426421},
+		},
+		OutOfNetworkIndicator: true,
+		ProgramSpliceFlag:     true,
+		SpliceEventID:         0x4800008f,
+		SpliceTime:            &ClockReference{Base: 1936310318},
+	},
+	Tier: 0xfff,
+}
+
+func parseSCTE35Sample(t *testing.T, sample string) *SCTE35Data {
+	bs, err := hex.DecodeString(sample)
+	assert.NoError(t, err)
+	d, err := parsePSIData(astikit.NewBytesIterator(append([]byte{0x00}, bs...)), false)
+	assert.NoError(t, err)
+	if !assert.Len(t, d.Sections, 1) {
+		return nil
+	}
+	assert.Equal(t, PSITableTypeSCTE35, d.Sections[0].Header.TableType)
+	return d.Sections[0].Syntax.Data.SCTE35
+}
+
+func TestParseSCTE35Section(t *testing.T) {
+	assert.Equal(t, scte35SpliceInsert, parseSCTE35Sample(t, scte35SpliceInsertSample))
+
+	d := parseSCTE35Sample(t, scte35TimeSignalSample)
+	assert.Equal(t, uint8(SCTE35SpliceCommandTypeTimeSignal), d.SpliceCommandType)
+	assert.Equal(t, &SCTE35TimeSignal{SpliceTime: &ClockReference{Base: 1924989008}}, d.TimeSignal)
+	if assert.Len(t, d.SpliceDescriptors, 1) {
+		// Segmentation descriptor
+		assert.Equal(t, uint8(0x02), d.SpliceDescriptors[0].Tag)
+		assert.Equal(t, uint32(0x43554549), d.SpliceDescriptors[0].Identifier)
+		assert.Len(t, d.SpliceDescriptors[0].Data, 24)
+	}
+}
+
+func TestWriteSCTE35Section(t *testing.T) {
+	for _, sample := range []string{scte35SpliceInsertSample, scte35TimeSignalSample} {
+		buf := bytes.Buffer{}
+		w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &buf})
+		s := &PSISection{
+			Header: &PSISectionHeader{TableID: PSITableIDSCTE35},
+			Syntax: &PSISectionSyntax{Data: &PSISectionSyntaxData{SCTE35: parseSCTE35Sample(t, sample)}},
+		}
+		s.Header.SectionLength = calcPSISectionLength(s)
+		_, err := writePSIData(w, &PSIData{Sections: []*PSISection{s}})
+		assert.NoError(t, err)
+		assert.Equal(t, "00"+sample, hex.EncodeToString(buf.Bytes()))
+	}
+}
+
+func TestDemuxerSCTE35(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.SetPCRPID(0x0100))
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0101,
+		StreamType:    StreamTypeSCTE35,
+	})
+	assert.NoError(t, err)
+	// PMT is parsed once retransmitted
+	for i := 0; i < 2; i++ {
+		_, err = muxer.WriteTables()
+		assert.NoError(t, err)
+	}
+
+	// Cue message
+	section, err := hex.DecodeString(scte35SpliceInsertSample)
+	assert.NoError(t, err)
+	pkt := append([]byte{syncByte, 0x41, 0x01, 0x10, 0x00}, section...)
+	buf.Write(append(pkt, bytes.Repeat([]byte{0xff}, MpegTsPacketSize-len(pkt))...))
+
+	var scte35 *SCTE35Data
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.SCTE35 != nil {
+			assert.Equal(t, uint16(0x0101), d.PID)
+			assert.True(t, d.CRCValid)
+			scte35 = d.SCTE35
+		}
+	}
+	assert.Equal(t, scte35SpliceInsert, scte35)
+}
+
+func TestMuxer_WriteSCTE35(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.SetPCRPID(0x0100))
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	_, err = muxer.WriteSCTE35(0x0100, scte35SpliceInsert)
+	assert.Equal(t, ErrPIDAlreadyExists, err)
+	_, err = muxer.WriteSCTE35(pmtStartPID, scte35SpliceInsert)
+	assert.Equal(t, ErrPIDAlreadyExists, err)
+	_, err = muxer.WriteSCTE35(0x0101, &SCTE35Data{EncryptedPacket: true})
+	assert.Equal(t, ErrSCTE35EncryptedPacket, err)
+	_, err = muxer.WriteSCTE35(0x0101, &SCTE35Data{SpliceCommandType: SCTE35SpliceCommandTypeSpliceInsert})
+	assert.True(t, errors.Is(err, ErrSCTE35SpliceCommandInvalid))
+	_, err = muxer.WriteSCTE35(0x0101, &SCTE35Data{SpliceCommandType: SCTE35SpliceCommandTypeTimeSignal})
+	assert.True(t, errors.Is(err, ErrSCTE35SpliceCommandInvalid))
+
+	// PMT is written again before the section
+	buf.Reset()
+	n, err := muxer.WriteSCTE35(0x0101, scte35SpliceInsert)
+	assert.NoError(t, err)
+	assert.Equal(t, 3*MpegTsPacketSize, n)
+	assert.Equal(t, buf.Len(), n)
+	bs := buf.Bytes()[2*MpegTsPacketSize:]
+	assert.Equal(t, uint16(0x0101), rawPacketPID(bs))
+	section, err := hex.DecodeString(scte35SpliceInsertSample)
+	assert.NoError(t, err)
+	assert.Equal(t, section, bs[5:5+len(section)])
+
+	// PID is already registered
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+	n, err = muxer.WriteSCTE35(0x0101, scte35SpliceInsert)
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize, n)
+
+	var pmt *PMTData
+	var scte35s []*SCTE35Data
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PMT != nil {
+			pmt = d.PMT
+		}
+		if d.SCTE35 != nil {
+			assert.True(t, d.CRCValid)
+			scte35s = append(scte35s, d.SCTE35)
+		}
+	}
+	if assert.NotNil(t, pmt) && assert.Len(t, pmt.ElementaryStreams, 2) {
+		assert.Equal(t, StreamTypeSCTE35, pmt.ElementaryStreams[1].StreamType)
+	}
+	if assert.Len(t, scte35s, 2) {
+		assert.Equal(t, scte35SpliceInsert, scte35s[1])
+	}
+}
+
+func TestMuxerProgram_WriteSCTE35(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	p, err := muxer.AddProgram(2)
+	assert.NoError(t, err)
+	err = p.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, p.SetPCRPID(0x0100))
+
+	_, err = p.WriteSCTE35(0x0101, scte35SpliceInsert)
+	assert.NoError(t, err)
+	// PID is found in the program it belongs to
+	_, err = muxer.WriteSCTE35(0x0101, scte35SpliceInsert)
+	assert.NoError(t, err)
+	_, err = muxer.defaultProgram.WriteSCTE35(0x0101, scte35SpliceInsert)
+	assert.Equal(t, ErrPIDAlreadyExists, err)
+
+	// Data is still written after the cue
+	assert.NoError(t, muxer.Validate())
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Data: testPayload(),
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{
+					MarkerBits:      2,
+					PTS:             &ClockReference{Base: 900000},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	var pat *PATData
+	var pmt *PMTData
+	var scte35s int
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PAT != nil {
+			pat = d.PAT
+		}
+		if d.PMT != nil {
+			pmt = d.PMT
+		}
+		if d.SCTE35 != nil {
+			scte35s++
+		}
+	}
+	if assert.NotNil(t, pat) {
+		assert.Equal(t, []*PATProgram{{ProgramMapID: p.PMTPID(), ProgramNumber: 2}}, pat.Programs)
+	}
+	if assert.NotNil(t, pmt) && assert.Len(t, pmt.ElementaryStreams, 2) {
+		assert.Equal(t, uint16(2), pmt.ProgramNumber)
+		assert.Equal(t, StreamTypeSCTE35, pmt.ElementaryStreams[1].StreamType)
+	}
+	assert.Equal(t, 2, scte35s)
+}
+
+func TestNewSCTE35SpliceInsert(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// Break starts and ends
+	for _, d := range []*SCTE35Data{
+		NewSCTE35SpliceInsert(1, &ClockReference{Base: 900000}, true),
+		NewSCTE35SpliceInsert(2, &ClockReference{Base: 3600000}, false),
+	} {
+		_, err = muxer.WriteSCTE35(0x0101, d)
+		assert.NoError(t, err)
+		_, err = muxer.WriteTables()
+		assert.NoError(t, err)
+	}
+
+	var ss []*SCTE35SpliceInsert
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.SCTE35 != nil {
+			assert.Equal(t, uint16(0xfff), d.SCTE35.Tier)
+			ss = append(ss, d.SCTE35.SpliceInsert)
+		}
+	}
+	assert.Equal(t, []*SCTE35SpliceInsert{
+		{
+			OutOfNetworkIndicator: true,
+			ProgramSpliceFlag:     true,
+			SpliceEventID:         1,
+			SpliceTime:            &ClockReference{Base: 900000},
+		},
+		{
+			ProgramSpliceFlag: true,
+			SpliceEventID:     2,
+			SpliceTime:        &ClockReference{Base: 3600000},
+		},
+	}, ss)
+}
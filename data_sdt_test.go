@@ -42,3 +42,17 @@ func TestParseSDTSection(t *testing.T) {
 	assert.Equal(t, d, sdt)
 	assert.NoError(t, err)
 }
+
+func TestWriteSDTSection(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &buf})
+	n, err := writeSDTSection(w, sdt)
+	assert.NoError(t, err)
+	assert.Equal(t, n, buf.Len())
+	assert.Equal(t, int(calcSDTSectionLength(sdt)), n)
+	// Reserved bits are set when writing
+	bs := sdtBytes()
+	bs[2] = 0xff
+	bs[5] |= 0xfc
+	assert.Equal(t, bs, buf.Bytes())
+}
@@ -0,0 +1,26 @@
+package astits
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+var tdt = &TDTData{UTCTime: dvbTime}
+
+func TestParseTDTSection(t *testing.T) {
+	d, err := parseTDTSection(astikit.NewBytesIterator(dvbTimeBytes))
+	assert.Equal(t, d, tdt)
+	assert.NoError(t, err)
+}
+
+func TestWriteTDTSection(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &buf})
+	n, err := writeTDTSection(w, tdt)
+	assert.NoError(t, err)
+	assert.Equal(t, int(calcTDTSectionLength(tdt)), n)
+	assert.Equal(t, dvbTimeBytes, buf.Bytes())
+}
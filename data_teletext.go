@@ -0,0 +1,143 @@
+package astits
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/asticode/go-astikit"
+)
+
+// Teletext data identifiers
+// Chapter: 4.3 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300472/01.04.01_60/en_300472v010401p.pdf
+const (
+	TeletextDataIdentifierEBUStart = 0x10
+	TeletextDataIdentifierEBUEnd   = 0x1f
+)
+
+// Teletext data unit ids
+// Chapter: 4.4 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300472/01.04.01_60/en_300472v010401p.pdf
+const (
+	TeletextDataUnitIDEBUTeletextNonSubtitle = 0x02
+	TeletextDataUnitIDEBUTeletextSubtitle    = 0x03
+	TeletextDataUnitIDStuffing               = 0xff
+)
+
+// TeletextFramingCode is the framing code of EBU teletext data units
+const TeletextFramingCode = 0xe4
+
+const (
+	teletextDataFieldLength = 44
+	teletextDataBlockLength = 40
+)
+
+var (
+	ErrTeletextDataIdentifierInvalid = errors.New("astits: teletext data identifier invalid")
+	ErrTeletextDataBlockInvalid      = errors.New("astits: teletext data block invalid")
+)
+
+// TeletextData represents the PES data of an EBU teletext stream
+// Chapter: 4.3 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300472/01.04.01_60/en_300472v010401p.pdf
+type TeletextData struct {
+	DataIdentifier uint8 // Between TeletextDataIdentifierEBUStart and TeletextDataIdentifierEBUEnd
+	DataUnits      []*TeletextDataUnit
+}
+
+// TeletextDataUnit represents an EBU teletext data unit, carrying a single teletext packet
+// Chapter: 4.4 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300472/01.04.01_60/en_300472v010401p.pdf
+type TeletextDataUnit struct {
+	DataBlock                []byte // 40 bytes
+	DataUnitID               uint8
+	FieldParity              bool   // Set for the first field of a frame
+	FramingCode              uint8  // Should be TeletextFramingCode
+	LineOffset               uint8  // 5 bits
+	MagazineAndPacketAddress uint16 // Hamming 8/4 coded
+}
+
+// ParseTeletextData parses the PES data of an EBU teletext stream
+// Only EBU teletext data units are returned, stuffing and other data units being skipped
+func ParseTeletextData(bs []byte) (d *TeletextData, err error) {
+	// Create iterator
+	i := astikit.NewBytesIterator(bs)
+
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Check data identifier
+	if b < TeletextDataIdentifierEBUStart || b > TeletextDataIdentifierEBUEnd {
+		err = fmt.Errorf("astits: data identifier is %#x: %w", b, ErrTeletextDataIdentifierInvalid)
+		return
+	}
+
+	// Create data
+	d = &TeletextData{DataIdentifier: b}
+
+	// Loop through data units
+	for i.HasBytesLeft() {
+		// Get next bytes
+		var bs []byte
+		if bs, err = i.NextBytesNoCopy(2); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+		id, length := bs[0], int(bs[1])
+
+		// Get data field
+		if bs, err = i.NextBytes(length); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+
+		// Skip stuffing and unknown data units
+		if (id != TeletextDataUnitIDEBUTeletextNonSubtitle && id != TeletextDataUnitIDEBUTeletextSubtitle) || length != teletextDataFieldLength {
+			continue
+		}
+
+		// Append data unit
+		d.DataUnits = append(d.DataUnits, &TeletextDataUnit{
+			DataBlock:                bs[4:],
+			DataUnitID:               id,
+			FieldParity:              bs[0]&0x20 > 0,
+			FramingCode:              bs[1],
+			LineOffset:               bs[0] & 0x1f,
+			MagazineAndPacketAddress: uint16(bs[2])<<8 | uint16(bs[3]),
+		})
+	}
+	return
+}
+
+// BuildTeletextData builds the PES data of an EBU teletext stream, to be written with a private stream 1 PES
+func BuildTeletextData(d *TeletextData) ([]byte, error) {
+	if d.DataIdentifier < TeletextDataIdentifierEBUStart || d.DataIdentifier > TeletextDataIdentifierEBUEnd {
+		return nil, fmt.Errorf("astits: data identifier is %#x: %w", d.DataIdentifier, ErrTeletextDataIdentifierInvalid)
+	}
+
+	buf := &bytes.Buffer{}
+	b := astikit.NewBitsWriterBatch(astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf}))
+
+	b.Write(d.DataIdentifier)
+
+	for idx, u := range d.DataUnits {
+		if len(u.DataBlock) != teletextDataBlockLength {
+			return nil, fmt.Errorf("astits: data block #%d is %d bytes long instead of %d: %w", idx, len(u.DataBlock), teletextDataBlockLength, ErrTeletextDataBlockInvalid)
+		}
+
+		b.Write(u.DataUnitID)
+		b.Write(uint8(teletextDataFieldLength))
+		b.WriteN(uint8(0xff), 2)
+		b.Write(u.FieldParity)
+		b.WriteN(u.LineOffset, 5)
+		b.Write(u.FramingCode)
+		b.Write(u.MagazineAndPacketAddress)
+		b.Write(u.DataBlock)
+	}
+
+	if err := b.Err(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
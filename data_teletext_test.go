@@ -0,0 +1,116 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var teletextData = &TeletextData{
+	DataIdentifier: TeletextDataIdentifierEBUStart,
+	DataUnits: []*TeletextDataUnit{
+		{
+			DataBlock:                bytes.Repeat([]byte{0x1}, teletextDataBlockLength),
+			DataUnitID:               TeletextDataUnitIDEBUTeletextSubtitle,
+			FieldParity:              true,
+			FramingCode:              TeletextFramingCode,
+			LineOffset:               7,
+			MagazineAndPacketAddress: 0x1502,
+		},
+		{
+			DataBlock:                bytes.Repeat([]byte{0x2}, teletextDataBlockLength),
+			DataUnitID:               TeletextDataUnitIDEBUTeletextNonSubtitle,
+			FramingCode:              TeletextFramingCode,
+			LineOffset:               21,
+			MagazineAndPacketAddress: 0x02a8,
+		},
+	},
+}
+
+func TestBuildTeletextData(t *testing.T) {
+	bs, err := BuildTeletextData(teletextData)
+	assert.NoError(t, err)
+	assert.Len(t, bs, 1+2*(2+teletextDataFieldLength))
+	assert.Equal(t, []byte{0x10, 0x03, 0x2c, 0xe7, 0xe4, 0x15, 0x02}, bs[:7])
+
+	// Stuffing is skipped
+	bs = append(bs, TeletextDataUnitIDStuffing, 0x2)
+	bs = append(bs, 0xff, 0xff)
+	d, err := ParseTeletextData(bs)
+	assert.NoError(t, err)
+	assert.Equal(t, teletextData, d)
+
+	_, err = BuildTeletextData(&TeletextData{DataIdentifier: 0x20})
+	assert.True(t, errors.Is(err, ErrTeletextDataIdentifierInvalid))
+	_, err = BuildTeletextData(&TeletextData{
+		DataIdentifier: TeletextDataIdentifierEBUEnd,
+		DataUnits:      []*TeletextDataUnit{{DataBlock: []byte{0x1}}},
+	})
+	assert.True(t, errors.Is(err, ErrTeletextDataBlockInvalid))
+	_, err = ParseTeletextData([]byte{0x20})
+	assert.True(t, errors.Is(err, ErrTeletextDataIdentifierInvalid))
+}
+
+func TestMuxer_TeletextRoundTrip(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	descriptor := &Descriptor{
+		Tag:    DescriptorTagTeletext,
+		Length: 5,
+		Teletext: &DescriptorTeletext{Items: []*DescriptorTeletextItem{{
+			Language: []byte("fra"),
+			Magazine: 1,
+			Page:     0x50,
+			Type:     TeletextTypeTeletextSubtitlePage,
+		}}},
+	}
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID:               0x0100,
+		ElementaryStreamDescriptors: []*Descriptor{descriptor},
+		StreamType:                  StreamTypePrivateData,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.SetPCRPID(0x0100))
+
+	bs, err := BuildTeletextData(teletextData)
+	assert.NoError(t, err)
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Data: bs,
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{
+					DataAlignmentIndicator: true,
+					MarkerBits:             2,
+					PTS:                    &ClockReference{Base: 900000},
+					PTSDTSIndicator:        PTSDTSIndicatorOnlyPTS,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	var pes *PESData
+	var pmt *PMTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PMT != nil {
+			pmt = d.PMT
+		}
+		if d.PES != nil {
+			pes = d.PES
+		}
+	}
+	if assert.NotNil(t, pmt) && assert.Len(t, pmt.ElementaryStreams, 1) {
+		assert.Equal(t, []*Descriptor{descriptor}, pmt.ElementaryStreams[0].ElementaryStreamDescriptors)
+	}
+	if assert.NotNil(t, pes) {
+		assert.Equal(t, uint8(0xbd), pes.Header.StreamID)
+		d, err := ParseTeletextData(pes.Data)
+		assert.NoError(t, err)
+		assert.Equal(t, teletextData, d)
+	}
+}
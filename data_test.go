@@ -2,6 +2,7 @@ package astits
 
 import (
 	"bytes"
+	"context"
 	"testing"
 
 	"github.com/asticode/go-astikit"
@@ -20,13 +21,13 @@ func TestParseData(t *testing.T) {
 		skip = true
 		return
 	}
-	ds, err := parseData(ps, c, pm)
+	ds, err := parseData(ps, c, pm, false)
 	assert.NoError(t, err)
 	assert.Equal(t, cds, ds)
 
 	// Do nothing for CAT
 	ps = []*Packet{{Header: &PacketHeader{PID: PIDCAT}}}
-	ds, err = parseData(ps, nil, pm)
+	ds, err = parseData(ps, nil, pm, false)
 	assert.NoError(t, err)
 	assert.Empty(t, ds)
 
@@ -42,7 +43,7 @@ func TestParseData(t *testing.T) {
 			Payload: p[33:],
 		},
 	}
-	ds, err = parseData(ps, nil, pm)
+	ds, err = parseData(ps, nil, pm, false)
 	assert.NoError(t, err)
 	assert.Equal(t, []*DemuxerData{{FirstPacket: ps[0], PES: pesWithHeader(), PID: uint16(256)}}, ds)
 
@@ -59,11 +60,43 @@ func TestParseData(t *testing.T) {
 			Payload: p[33:],
 		},
 	}
-	ds, err = parseData(ps, nil, pm)
+	ds, err = parseData(ps, nil, pm, false)
 	assert.NoError(t, err)
 	assert.Equal(t, psi.toData(ps[0], uint16(256)), ds)
 }
 
+func TestParseDataDiscontinuityIndicator(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(context.Background(), buf)
+	err := mx.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video})
+	assert.NoError(t, err)
+	err = mx.SetPCRPID(0x100)
+	assert.NoError(t, err)
+	for _, discontinuity := range []bool{false, true, false} {
+		d := &MuxerData{
+			PES: &PESData{Data: []byte("test"), Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+				MarkerBits:      2,
+				PTS:             &ClockReference{Base: 900000},
+				PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+			}}},
+			PID: 0x100,
+		}
+		if discontinuity {
+			d.AdaptationField = &PacketAdaptationField{DiscontinuityIndicator: true}
+		}
+		_, err = mx.WriteData(d)
+		assert.NoError(t, err)
+	}
+
+	var ds []bool
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PES != nil {
+			ds = append(ds, d.DiscontinuityIndicator)
+		}
+	}
+	assert.Equal(t, []bool{false, true, false}, ds)
+}
+
 func TestIsPSIPayload(t *testing.T) {
 	pm := newProgramMap()
 	var pids []int
@@ -72,9 +105,11 @@ func TestIsPSIPayload(t *testing.T) {
 			pids = append(pids, i)
 		}
 	}
-	assert.Equal(t, []int{0, 16, 17, 18, 19, 20, 30, 31}, pids)
+	assert.Equal(t, []int{0, 2, 16, 17, 18, 19, 20, 30, 31}, pids)
 	pm.set(uint16(1), uint16(0))
 	assert.True(t, isPSIPayload(uint16(1), pm))
+	pm.setSections(uint16(256))
+	assert.True(t, isPSIPayload(uint16(256), pm))
 }
 
 func TestIsPESPayload(t *testing.T) {
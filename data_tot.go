@@ -33,3 +33,20 @@ func parseTOTSection(i *astikit.BytesIterator) (d *TOTData, err error) {
 	}
 	return
 }
+
+func calcTOTSectionLength(d *TOTData) uint16 {
+	return 5 + 2 + calcDescriptorsLength(d.Descriptors) // UTC time and descriptors loop length
+}
+
+func writeTOTSection(w *astikit.BitsWriter, d *TOTData) (int, error) {
+	bytesWritten, err := writeDVBTime(w, d.UTCTime)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := writeDescriptorsWithLength(w, d.Descriptors)
+	if err != nil {
+		return 0, err
+	}
+	return bytesWritten + n, nil
+}
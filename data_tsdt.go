@@ -0,0 +1,34 @@
+package astits
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astikit"
+)
+
+// TSDTData represents a TSDT data, carrying descriptors that apply to the whole transport stream
+// Chapter: 2.4.4.12 | Link: https://www.itu.int/rec/T-REC-H.222.0
+type TSDTData struct {
+	Descriptors []*Descriptor
+}
+
+// parseTSDTSection parses a TSDT section
+func parseTSDTSection(i *astikit.BytesIterator, offsetSectionsEnd int) (d *TSDTData, err error) {
+	// Create data
+	d = &TSDTData{}
+
+	// Descriptors fill the section
+	if d.Descriptors, err = parseDescriptorsUntil(i, offsetSectionsEnd); err != nil {
+		err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+		return
+	}
+	return
+}
+
+func calcTSDTSectionLength(d *TSDTData) uint16 {
+	return calcDescriptorsLength(d.Descriptors)
+}
+
+func writeTSDTSection(w *astikit.BitsWriter, d *TSDTData) (int, error) {
+	return writeDescriptors(w, d.Descriptors)
+}
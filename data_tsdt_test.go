@@ -0,0 +1,66 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+var tsdt = &TSDTData{Descriptors: []*Descriptor{{
+	Length:       4,
+	Registration: &DescriptorRegistration{FormatIdentifier: RegistrationFormatIdentifierHDMV},
+	Tag:          DescriptorTagRegistration,
+}}}
+
+func tsdtBytes() []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write(uint8(DescriptorTagRegistration)) // Tag
+	w.Write(uint8(4))                         // Length
+	w.Write(uint32(0x48444d56))               // Format identifier
+	return buf.Bytes()
+}
+
+func TestParseTSDTSection(t *testing.T) {
+	bs := tsdtBytes()
+	d, err := parseTSDTSection(astikit.NewBytesIterator(bs), len(bs))
+	assert.NoError(t, err)
+	assert.Equal(t, tsdt, d)
+}
+
+func TestWriteTSDTSection(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	n, err := writeTSDTSection(w, tsdt)
+	assert.NoError(t, err)
+	assert.Equal(t, n, buf.Len())
+	assert.Equal(t, int(calcTSDTSectionLength(tsdt)), n)
+	assert.Equal(t, tsdtBytes(), buf.Bytes())
+}
+
+func TestDemuxerTSDT(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(context.Background(), buf)
+	err := mx.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video})
+	assert.NoError(t, err)
+	assert.NoError(t, mx.SetPCRPID(0x100))
+	mx.SetTransportStreamDescriptors([]*Descriptor{{
+		Registration: &DescriptorRegistration{FormatIdentifier: RegistrationFormatIdentifierHDMV},
+		Tag:          DescriptorTagRegistration,
+	}})
+	_, err = mx.WriteTables()
+	assert.NoError(t, err)
+
+	var d *TSDTData
+	for _, dd := range demuxAllData(t, buf.Bytes()) {
+		if dd.TSDT != nil {
+			assert.Equal(t, PIDTSDT, dd.PID)
+			assert.True(t, dd.CRCValid)
+			d = dd.TSDT
+		}
+	}
+	assert.Equal(t, tsdt, d)
+}
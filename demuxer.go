@@ -1,6 +1,7 @@
 package astits
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -21,26 +22,62 @@ var (
 // http://seidl.cs.vsb.cz/download/dvb/DVB_Poster.pdf
 // http://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.13.01_40/en_300468v011301o.pdf
 type Demuxer struct {
-	ctx              context.Context
-	dataBuffer       []*DemuxerData
-	optPacketSize    int
-	optPacketsParser PacketsParser
-	packetBuffer     *packetBuffer
-	packetPool       *packetPool
-	programMap       programMap
-	r                io.Reader
+	ccs                  map[uint16]continuityState // Last continuity counter, indexed by PID
+	ctx                  context.Context
+	dataBuffer           []*DemuxerData
+	optDetectStreamTypes bool
+	optDumpFirstPacket   bool
+	optErrorRecovery     bool
+	optKeepRawPackets    bool
+	optOnDiscontinuity   DiscontinuityHandler
+	optPacketSize        int
+	optPacketsParser     PacketsParser
+	optSkipCRCCheck      bool
+	optTPDHandlers       map[uint16]TransportPrivateDataHandler
+	packetBuffer         *packetBuffer
+	packetPool           *packetPool
+	pids                 map[uint16]bool     // PIDs seen in the stream
+	pmts                 map[uint16]*PMTData // Last PMT parsed, indexed by PMT PID
+	programMap           programMap
+	programs             []ProgramInfo // Cached by Programs
+	r                    io.Reader
+	stats                DemuxerStats
+}
+
+// DemuxerStats represents cumulative counters of the corrupt data dropped by the Demuxer
+type DemuxerStats struct {
+	CorruptPackets int64 // Packets dropped because they couldn't be parsed or had their transport_error_indicator set
+	Resyncs        int64 // Times the reader has been resynchronized on sync bytes
+	SkippedBytes   int64 // Bytes skipped while resynchronizing
 }
 
 // PacketsParser represents an object capable of parsing a set of packets containing a unique payload spanning over those packets
 // Use the skip returned argument to indicate whether the default process should still be executed on the set of packets
 type PacketsParser func(ps []*Packet) (ds []*DemuxerData, skip bool, err error)
 
+// TransportPrivateDataHandler represents an object capable of handling the transport private data found in the
+// adaptation field of packets
+type TransportPrivateDataHandler func(pid uint16, data []byte) error
+
+// DiscontinuityHandler represents an object capable of handling continuity counter discontinuities, expected being
+// the continuity counter the packet should have had
+type DiscontinuityHandler func(pid uint16, expected, got uint8)
+
+// continuityState represents the continuity counter of the last packet of a PID
+type continuityState struct {
+	cc         uint8
+	hasPayload bool
+}
+
 // NewDemuxer creates a new transport stream based on a reader
 func NewDemuxer(ctx context.Context, r io.Reader, opts ...func(*Demuxer)) (d *Demuxer) {
 	// Init
 	d = &Demuxer{
+		ccs:        make(map[uint16]continuityState),
 		ctx:        ctx,
 		packetPool: newPacketPool(),
+		pids:       make(map[uint16]bool),
+		pmts:       make(map[uint16]*PMTData),
 		programMap: newProgramMap(),
 		r:          r,
 	}
@@ -53,6 +90,26 @@ func NewDemuxer(ctx context.Context, r io.Reader, opts ...func(*Demuxer)) (d *De
 	return
 }
 
+// NewDemuxerFromPackets creates a new demuxer reading pre-built packets instead of an io.Reader
+// Packet size is set to the size of the first packet, which can be overridden through options
+// It comes in handy when testing edge cases
+func NewDemuxerFromPackets(ctx context.Context, pkts [][]byte, opts ...func(*Demuxer)) *Demuxer {
+	if len(pkts) > 0 {
+		opts = append([]func(*Demuxer){DemuxerOptPacketSize(len(pkts[0]))}, opts...)
+	}
+	return NewDemuxer(ctx, bytes.NewReader(bytes.Join(pkts, nil)), opts...)
+}
+
+// DemuxerOptOnDiscontinuity returns the option to call h with continuity counter discontinuities, which comes in
+// handy to monitor live streams. Packets without payload, which don't increment the continuity counter, a single
+// duplicate of a packet and discontinuities signaled through the discontinuity_indicator are not reported, while
+// null packets and packets with their transport_error_indicator set are ignored.
+func DemuxerOptOnDiscontinuity(h DiscontinuityHandler) func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optOnDiscontinuity = h
+	}
+}
+
 // DemuxerOptPacketSize returns the option to set the packet size
 func DemuxerOptPacketSize(packetSize int) func(*Demuxer) {
 	return func(d *Demuxer) {
@@ -67,6 +124,44 @@ func DemuxerOptPacketsParser(p PacketsParser) func(*Demuxer) {
 	}
 }
 
+// DemuxerOptErrorRecovery returns the option to recover from corrupt packets, which comes in handy with lossy
+// captures: packets with their transport_error_indicator set or that can't be parsed are dropped and, when a packet
+// doesn't start with a sync byte, the reader is resynchronized on the next one instead of failing. What has been
+// dropped is counted in Stats.
+func DemuxerOptErrorRecovery() func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optErrorRecovery = true
+	}
+}
+
+// DemuxerOptKeepRawPackets returns the option to keep the bytes packets were parsed from in Packet.Raw
+// It comes in handy to forward packets as is, for instance when filtering PIDs without remuxing
+func DemuxerOptKeepRawPackets() func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optKeepRawPackets = true
+	}
+}
+
+// DemuxerOptSkipCRCCheck returns the option to parse PSI sections whose CRC32 is invalid instead of failing
+// Such sections are returned with CRCValid set to false, which comes in handy when recovering corrupted captures
+func DemuxerOptSkipCRCCheck() func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optSkipCRCCheck = true
+	}
+}
+
+// DemuxerOptTransportPrivateDataHandler returns the option to call h with the transport private data found
+// in the adaptation field of packets of the PID
+// It can be provided several times, once per PID
+func DemuxerOptTransportPrivateDataHandler(pid uint16, h TransportPrivateDataHandler) func(*Demuxer) {
+	return func(d *Demuxer) {
+		if d.optTPDHandlers == nil {
+			d.optTPDHandlers = make(map[uint16]TransportPrivateDataHandler)
+		}
+		d.optTPDHandlers[pid] = h
+	}
+}
+
 // NextPacket retrieves the next packet
 func (dmx *Demuxer) NextPacket() (p *Packet, err error) {
 	// Check ctx error
@@ -82,18 +177,73 @@ func (dmx *Demuxer) NextPacket() (p *Packet, err error) {
 			err = fmt.Errorf("astits: creating packet buffer failed: %w", err)
 			return
 		}
+		dmx.packetBuffer.keepRaw = dmx.optKeepRawPackets
+		dmx.packetBuffer.recover = dmx.optErrorRecovery
+		dmx.packetBuffer.stats = &dmx.stats
 	}
 
 	// Fetch next packet from buffer
-	if p, err = dmx.packetBuffer.next(); err != nil {
-		if err != ErrNoMorePackets {
-			err = fmt.Errorf("astits: fetching next packet from buffer failed: %w", err)
+	for {
+		if p, err = dmx.packetBuffer.next(); err != nil {
+			if err != ErrNoMorePackets {
+				err = fmt.Errorf("astits: fetching next packet from buffer failed: %w", err)
+			}
+			return
+		}
+		if !dmx.optErrorRecovery || !p.Header.TransportErrorIndicator {
+			break
+		}
+		dmx.stats.CorruptPackets++
+	}
+
+	// Keep track of PIDs
+	dmx.pids[p.Header.PID] = true
+
+	// Check continuity
+	if dmx.optOnDiscontinuity != nil {
+		dmx.checkContinuity(p)
+	}
+
+	// Handle transport private data
+	if h, ok := dmx.optTPDHandlers[p.Header.PID]; ok && p.AdaptationField != nil && p.AdaptationField.HasTransportPrivateData {
+		if err = h(p.Header.PID, p.AdaptationField.TransportPrivateData); err != nil {
+			err = fmt.Errorf("astits: handling transport private data of PID %d failed: %w", p.Header.PID, err)
+			return
 		}
-		return
 	}
 	return
 }
 
+// checkContinuity reports the packet to the discontinuity handler if its continuity counter doesn't follow the one
+// of the previous packet of its PID
+func (dmx *Demuxer) checkContinuity(p *Packet) {
+	if p.Header.PID == PIDNull || p.Header.TransportErrorIndicator {
+		return
+	}
+	last, ok := dmx.ccs[p.Header.PID]
+	dmx.ccs[p.Header.PID] = continuityState{cc: p.Header.ContinuityCounter, hasPayload: p.Header.HasPayload}
+	if !ok || (p.AdaptationField != nil && p.AdaptationField.DiscontinuityIndicator) {
+		return
+	}
+
+	// Continuity counter only increments with payload, and a packet carrying payload may be sent twice
+	expected := last.cc
+	if p.Header.HasPayload {
+		if last.hasPayload && p.Header.ContinuityCounter == last.cc {
+			return
+		}
+		expected = (last.cc + 1) % 16
+	}
+	if p.Header.ContinuityCounter != expected {
+		dmx.optOnDiscontinuity(p.Header.PID, expected, p.Header.ContinuityCounter)
+	}
+}
+
+// Stats returns cumulative counters of the corrupt data dropped with DemuxerOptErrorRecovery
+func (dmx *Demuxer) Stats() DemuxerStats {
+	return dmx.stats
+}
+
 // NextData retrieves the next data
 func (dmx *Demuxer) NextData() (d *DemuxerData, err error) {
 	// Check data buffer
@@ -119,7 +269,7 @@ func (dmx *Demuxer) NextData() (d *DemuxerData, err error) {
 					}
 
 					// Parse data
-					if ds, err = parseData(ps, dmx.optPacketsParser, dmx.programMap); err != nil {
+					if ds, err = parseData(ps, dmx.optPacketsParser, dmx.programMap, dmx.optSkipCRCCheck); err != nil {
 						// We need to silence this error as there may be some incomplete data here
 						// We still want to try to parse all packets, in case final data is complete
 						continue
@@ -142,7 +292,7 @@ func (dmx *Demuxer) NextData() (d *DemuxerData, err error) {
 		}
 
 		// Parse data
-		if ds, err = parseData(ps, dmx.optPacketsParser, dmx.programMap); err != nil {
+		if ds, err = parseData(ps, dmx.optPacketsParser, dmx.programMap, dmx.optSkipCRCCheck); err != nil {
 			err = fmt.Errorf("astits: building new data failed: %w", err)
 			return
 		}
@@ -171,6 +321,17 @@ func (dmx *Demuxer) updateData(ds []*DemuxerData) (d *DemuxerData) {
 					}
 				}
 			}
+			if v.PES != nil && dmx.optDetectStreamTypes {
+				dmx.detectStreamType(v)
+			}
+			if v.PMT != nil {
+				dmx.pmts[v.PID] = v.PMT
+				for _, es := range v.PMT.ElementaryStreams {
+					if es.StreamType == StreamTypeSCTE35 {
+						dmx.programMap.setSections(es.ElementaryPID)
+					}
+				}
+			}
 		}
 	}
 	return
@@ -178,6 +339,7 @@ func (dmx *Demuxer) updateData(ds []*DemuxerData) (d *DemuxerData) {
 
 // Rewind rewinds the demuxer reader
 func (dmx *Demuxer) Rewind() (n int64, err error) {
+	dmx.ccs = make(map[uint16]continuityState)
 	dmx.dataBuffer = []*DemuxerData{}
 	dmx.packetBuffer = nil
 	dmx.packetPool = newPacketPool()
@@ -187,3 +349,19 @@ func (dmx *Demuxer) Rewind() (n int64, err error) {
 	}
 	return
 }
+
+// Reset rebinds the demuxer to a new reader so that it can be reused for another stream. Options are kept while
+// buffered data, partial payloads, parsed PATs and PMTs and stats are cleared. Packet size is detected again unless
+// set through DemuxerOptPacketSize.
+func (dmx *Demuxer) Reset(r io.Reader) {
+	dmx.ccs = make(map[uint16]continuityState)
+	dmx.dataBuffer = nil
+	dmx.packetBuffer = nil
+	dmx.packetPool = newPacketPool()
+	dmx.pids = make(map[uint16]bool)
+	dmx.pmts = make(map[uint16]*PMTData)
+	dmx.programMap = newProgramMap()
+	dmx.programs = nil
+	dmx.r = r
+	dmx.stats = DemuxerStats{}
+}
@@ -0,0 +1,91 @@
+package astits
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// DemuxerOptDumpFirstPacket returns the option to include the first packet of each data in dumps
+func DemuxerOptDumpFirstPacket(dumpFirstPacket bool) func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optDumpFirstPacket = dumpFirstPacket
+	}
+}
+
+// DumpJSON reads all remaining data and writes each of them to w as a JSON object on its own line
+// Bytes are hex encoded and nil fields are omitted
+func (dmx *Demuxer) DumpJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	for {
+		d, err := dmx.NextData()
+		if err != nil {
+			if errors.Is(err, ErrNoMorePackets) {
+				return nil
+			}
+			return fmt.Errorf("astits: fetching next data failed: %w", err)
+		}
+
+		if !dmx.optDumpFirstPacket {
+			c := *d
+			c.FirstPacket = nil
+			d = &c
+		}
+
+		if err = e.Encode(jsonDumpValue(reflect.ValueOf(d))); err != nil {
+			return fmt.Errorf("astits: encoding data failed: %w", err)
+		}
+	}
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// jsonDumpValue converts a value to something encoding/json encodes with hex encoded bytes
+func jsonDumpValue(v reflect.Value) interface{} {
+	if v.Type().Implements(jsonMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return jsonDumpValue(v.Elem())
+	case reflect.Struct:
+		o := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			fv := v.Field(i)
+			switch fv.Kind() {
+			case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+				if fv.IsNil() {
+					continue
+				}
+			}
+			o[f.Name] = jsonDumpValue(fv)
+		}
+		return o
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			bs := make([]byte, v.Len())
+			for i := range bs {
+				bs[i] = byte(v.Index(i).Uint())
+			}
+			return hex.EncodeToString(bs)
+		}
+		o := make([]interface{}, v.Len())
+		for i := range o {
+			o[i] = jsonDumpValue(v.Index(i))
+		}
+		return o
+	default:
+		return v.Interface()
+	}
+}
@@ -0,0 +1,61 @@
+package astits
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDemuxer_DumpJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	m := NewMuxer(context.Background(), buf)
+	err := m.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeMPEG1Audio})
+	assert.NoError(t, err)
+	require.NoError(t, m.SetPCRPID(0x100))
+	_, err = m.WriteData(&MuxerData{PID: 0x100, PES: &PESData{
+		Data: []byte("test"),
+		Header: &PESHeader{
+			OptionalHeader: &PESOptionalHeader{
+				MarkerBits:      2,
+				PTS:             &ClockReference{Base: 5726623060},
+				PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+			},
+			StreamID: 0xc0,
+		},
+	}})
+	assert.NoError(t, err)
+
+	for _, withFirstPacket := range []bool{false, true} {
+		out := &bytes.Buffer{}
+		dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptDumpFirstPacket(withFirstPacket))
+		assert.NoError(t, dmx.DumpJSON(out))
+
+		var ls []map[string]interface{}
+		s := bufio.NewScanner(out)
+		for s.Scan() {
+			var l map[string]interface{}
+			assert.NoError(t, json.Unmarshal(s.Bytes(), &l))
+			ls = append(ls, l)
+		}
+		assert.NoError(t, s.Err())
+		assert.Len(t, ls, 3)
+
+		var pes map[string]interface{}
+		for _, l := range ls {
+			_, ok := l["FirstPacket"]
+			assert.Equal(t, withFirstPacket, ok)
+			if v, ok := l["PES"]; ok {
+				pes = v.(map[string]interface{})
+			}
+		}
+		if assert.NotNil(t, pes) {
+			assert.Equal(t, hex.EncodeToString([]byte("test")), pes["Data"])
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package astits
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// PCR wraps around after 2^33 PCR base ticks
+	pcrWrapAround = (pcrBaseMask + 1) * 300
+	// PCRs further apart than this are considered discontinuous. Spec requires them to be at most 100ms apart but 1s is
+	// tolerated on purpose, so that streams with sparse PCRs are not split into segments.
+	pcrMaxGap = clockFrequency
+)
+
+// ErrReaderNotSeekable is returned when an operation requires a seekable reader
+var ErrReaderNotSeekable = errors.New("astits: reader is not seekable")
+
+// Duration computes the duration of the stream based on the PCRs of the first PID carrying PCRs
+// The whole stream is read, after which the reader is put back to its current position, which requires
+// the reader to implement io.Seeker.
+// PCR wraparounds are handled and, when PCRs are discontinuous, the durations of the continuous segments are summed.
+func (dmx *Demuxer) Duration() (time.Duration, error) {
+	s, ok := dmx.r.(io.Seeker)
+	if !ok {
+		return 0, ErrReaderNotSeekable
+	}
+
+	// Get current position
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, fmt.Errorf("astits: getting current position failed: %w", err)
+	}
+
+	// Compute duration
+	d, err := dmx.duration(s)
+
+	// Put reader back to its position
+	if _, serr := s.Seek(pos, io.SeekStart); serr != nil && err == nil {
+		err = fmt.Errorf("astits: seeking to %d failed: %w", pos, serr)
+	}
+	return d, err
+}
+
+func (dmx *Demuxer) duration(s io.Seeker) (time.Duration, error) {
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("astits: seeking to 0 failed: %w", err)
+	}
+
+	// Packet size may have been auto detected already
+	packetSize := dmx.optPacketSize
+	if dmx.packetBuffer != nil {
+		packetSize = dmx.packetBuffer.packetSize
+	}
+	pb, err := newPacketBuffer(dmx.r, packetSize)
+	if err != nil {
+		return 0, fmt.Errorf("astits: creating packet buffer failed: %w", err)
+	}
+
+	var pid uint16
+	var first, last, total int64
+	var hasPCR bool
+	for {
+		p, err := pb.next()
+		if err != nil {
+			if err == ErrNoMorePackets {
+				break
+			}
+			return 0, fmt.Errorf("astits: fetching next packet from buffer failed: %w", err)
+		}
+
+		if !p.Header.HasAdaptationField || p.AdaptationField == nil || !p.AdaptationField.HasPCR {
+			continue
+		}
+		if hasPCR && p.Header.PID != pid {
+			continue
+		}
+
+		c := p.AdaptationField.PCR.Base*300 + p.AdaptationField.PCR.Extension
+		if !hasPCR {
+			pid = p.Header.PID
+			first, last, hasPCR = c, c, true
+			continue
+		}
+
+		// Handle wraparound
+		delta := (c - last + pcrWrapAround) % pcrWrapAround
+
+		// Start a new segment on discontinuities
+		if p.AdaptationField.DiscontinuityIndicator || delta > pcrMaxGap {
+			total += segmentDuration(first, last)
+			first = c
+		}
+		last = c
+	}
+
+	if hasPCR {
+		total += segmentDuration(first, last)
+	}
+	// Seconds are split off to avoid overflows
+	return time.Duration(total/clockFrequency)*time.Second + time.Duration(total%clockFrequency*int64(time.Second)/clockFrequency), nil
+}
+
+// segmentDuration returns the 27MHz duration between two PCRs, handling wraparound
+func segmentDuration(first, last int64) int64 {
+	return (last - first + pcrWrapAround) % pcrWrapAround
+}
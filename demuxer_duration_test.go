@@ -0,0 +1,60 @@
+package astits
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDemuxerDuration(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(context.Background(), buf)
+	err := mx.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video})
+	assert.NoError(t, err)
+	err = mx.SetPCRPID(0x100)
+	assert.NoError(t, err)
+	_, err = mx.WriteTables()
+	assert.NoError(t, err)
+
+	// 2s segment wrapping around, then a 1.5s segment after a discontinuity
+	for i := int64(0); i <= 50; i++ {
+		_, err = mx.WritePCR(uint64((pcrWrapAround - clockFrequency + i*clockFrequency/25) % pcrWrapAround))
+		assert.NoError(t, err)
+	}
+	_, err = mx.WriteData(&MuxerData{
+		AdaptationField: &PacketAdaptationField{
+			DiscontinuityIndicator: true,
+			HasPCR:                 true,
+			PCR:                    &ClockReference{Base: 900000},
+		},
+		PES: &PESData{Data: []byte("test"), Header: &PESHeader{}},
+		PID: 0x100,
+	})
+	assert.NoError(t, err)
+	for i := int64(1); i <= 15; i++ {
+		_, err = mx.WritePCR(uint64(900000*300 + i*clockFrequency/10))
+		assert.NoError(t, err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	dmx := NewDemuxer(context.Background(), r)
+	p, err := dmx.NextPacket()
+	assert.NoError(t, err)
+
+	d, err := dmx.Duration()
+	assert.NoError(t, err)
+	assert.Equal(t, 3500*time.Millisecond, d)
+
+	// Reader is put back to its position
+	p2, err := dmx.NextPacket()
+	assert.NoError(t, err)
+	assert.NotEqual(t, p.Header.PID, p2.Header.PID)
+
+	// Reader is not seekable
+	_, err = NewDemuxer(context.Background(), bufio.NewReader(r)).Duration()
+	assert.Equal(t, ErrReaderNotSeekable, err)
+}
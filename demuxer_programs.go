@@ -0,0 +1,91 @@
+package astits
+
+import (
+	"fmt"
+)
+
+// ProgramInfo represents a program found in the stream
+type ProgramInfo struct {
+	ElementaryStreams  []*PMTElementaryStream
+	PCRPID             uint16
+	PMTPID             uint16
+	ProgramDescriptors []*Descriptor
+	ProgramNumber      uint16
+}
+
+// Programs returns the programs of the stream, in the order of the PAT, once the PAT and the PMTs of all its
+// programs have been parsed. Data is read ahead and buffered so that following NextData calls return it as if
+// Programs had not been called. The result is cached, and programs whose PMT is not found before the end of the
+// stream have no elementary streams.
+func (dmx *Demuxer) Programs() (ps []ProgramInfo, err error) {
+	// Check cache
+	if dmx.programs != nil {
+		return dmx.programs, nil
+	}
+
+	// Data read ahead is put back in front of the data buffer
+	var ds []*DemuxerData
+	defer func() {
+		dmx.dataBuffer = append(ds, dmx.dataBuffer...)
+	}()
+
+	// Loop through data
+	var pat *PATData
+	pmts := make(map[uint16]*PMTData)
+	for pat == nil || !patPMTsParsed(pat, pmts) {
+		// Get next data
+		var d *DemuxerData
+		if d, err = dmx.NextData(); err != nil || d == nil {
+			if (err == nil || err == ErrNoMorePackets) && pat != nil {
+				err = nil
+				break
+			}
+			if err == nil || err == ErrNoMorePackets {
+				err = fmt.Errorf("astits: PAT not found: %w", ErrNoMorePackets)
+			} else {
+				err = fmt.Errorf("astits: fetching next data failed: %w", err)
+			}
+			return
+		}
+		ds = append(ds, d)
+
+		// Keep track of tables
+		if d.PAT != nil {
+			pat = d.PAT
+		}
+		if d.PMT != nil {
+			pmts[d.PID] = d.PMT
+		}
+	}
+
+	// Build programs
+	ps = []ProgramInfo{}
+	for _, pgm := range pat.Programs {
+		// Program number 0 is reserved to NIT
+		if pgm.ProgramNumber == 0 {
+			continue
+		}
+		p := ProgramInfo{
+			PMTPID:        pgm.ProgramMapID,
+			ProgramNumber: pgm.ProgramNumber,
+		}
+		if pmt, ok := pmts[pgm.ProgramMapID]; ok {
+			p.ElementaryStreams = pmt.ElementaryStreams
+			p.PCRPID = pmt.PCRPID
+			p.ProgramDescriptors = pmt.ProgramDescriptors
+		}
+		ps = append(ps, p)
+	}
+	dmx.programs = ps
+	return
+}
+
+// patPMTsParsed checks whether the PMTs of all the programs of the PAT have been parsed
+func patPMTsParsed(pat *PATData, pmts map[uint16]*PMTData) bool {
+	for _, pgm := range pat.Programs {
+		if _, ok := pmts[pgm.ProgramMapID]; !ok && pgm.ProgramNumber > 0 {
+			return false
+		}
+	}
+	return true
+}
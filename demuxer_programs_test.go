@@ -0,0 +1,75 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDemuxerPrograms(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(context.Background(), buf)
+	err := mx.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video})
+	assert.NoError(t, err)
+	assert.NoError(t, mx.SetPCRPID(0x100))
+	p, err := mx.AddProgram(2)
+	assert.NoError(t, err)
+	assert.NoError(t, p.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x200, StreamType: StreamTypeAACAudio}))
+	assert.NoError(t, p.SetPCRPID(0x200))
+	// PMTs are parsed once retransmitted
+	for i := 0; i < 2; i++ {
+		_, err = mx.WriteTables()
+		assert.NoError(t, err)
+	}
+	_, err = mx.WriteData(&MuxerData{
+		PES: &PESData{
+			Data:   []byte("test"),
+			Header: &PESHeader{OptionalHeader: &PESOptionalHeader{MarkerBits: 2, PTS: &ClockReference{}, PTSDTSIndicator: PTSDTSIndicatorOnlyPTS}},
+		},
+		PID: 0x100,
+	})
+	assert.NoError(t, err)
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	ps, err := dmx.Programs()
+	assert.NoError(t, err)
+	assert.Equal(t, []ProgramInfo{
+		{
+			ElementaryStreams: []*PMTElementaryStream{{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}},
+			PCRPID:            0x100,
+			PMTPID:            pmtStartPID,
+			ProgramNumber:     programNumberStart,
+		},
+		{
+			ElementaryStreams: []*PMTElementaryStream{{ElementaryPID: 0x200, StreamType: StreamTypeAACAudio}},
+			PCRPID:            0x200,
+			PMTPID:            pmtStartPID + 1,
+			ProgramNumber:     2,
+		},
+	}, ps)
+
+	// Result is cached
+	ps2, err := dmx.Programs()
+	assert.NoError(t, err)
+	assert.Equal(t, ps, ps2)
+
+	// Data read ahead is replayed
+	var ds []*DemuxerData
+	for {
+		d, err := dmx.NextData()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		ds = append(ds, d)
+	}
+	assert.Equal(t, demuxAllData(t, buf.Bytes()), ds)
+
+	// No PAT
+	dmx = NewDemuxerFromPackets(context.Background(), [][]byte{buf.Bytes()[buf.Len()-MpegTsPacketSize:]})
+	_, err = dmx.Programs()
+	assert.True(t, errors.Is(err, ErrNoMorePackets))
+}
@@ -0,0 +1,126 @@
+package astits
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrPCRNotFound is returned when no PCR matching a seek target has been found
+var ErrPCRNotFound = errors.New("astits: PCR not found")
+
+// SeekToPCR moves the demuxer to the first packet of pid carrying a PCR at or after target, which is a 27MHz value
+// equal to the PCR base multiplied by 300 plus its extension. Packets are binary searched, which requires the reader
+// to implement io.Seeker and PCRs to increase throughout the stream. A single wraparound is handled by measuring PCRs
+// from the first PCR of pid. Buffered data, partial payloads and continuity counters are reset so that NextData
+// returns clean data from there, while PMTs parsed so far are kept.
+func (dmx *Demuxer) SeekToPCR(pid uint16, target uint64) error {
+	return dmx.seekToPCR(pid, func(first int64) int64 { return int64(target % pcrWrapAround) })
+}
+
+// SeekToTime moves the demuxer to the first packet of pid carrying a PCR at least d after the first PCR of pid, see
+// SeekToPCR
+func (dmx *Demuxer) SeekToTime(pid uint16, d time.Duration) error {
+	return dmx.seekToPCR(pid, func(first int64) int64 { return (first + int64(DurationToPCR(d))) % pcrWrapAround })
+}
+
+func (dmx *Demuxer) seekToPCR(pid uint16, targetFunc func(first int64) int64) (err error) {
+	s, ok := dmx.r.(io.Seeker)
+	if !ok {
+		return ErrReaderNotSeekable
+	}
+
+	// Packet size may have been auto detected already
+	if dmx.packetBuffer == nil {
+		if _, err = s.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("astits: seeking to 0 failed: %w", err)
+		}
+		if dmx.packetBuffer, err = newPacketBuffer(dmx.r, dmx.optPacketSize); err != nil {
+			return fmt.Errorf("astits: creating packet buffer failed: %w", err)
+		}
+		dmx.packetBuffer.keepRaw = dmx.optKeepRawPackets
+		dmx.packetBuffer.recover = dmx.optErrorRecovery
+		dmx.packetBuffer.stats = &dmx.stats
+	}
+	packetSize := int64(dmx.packetBuffer.packetSize)
+
+	// Get number of packets
+	size, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("astits: seeking to end failed: %w", err)
+	}
+	n := size / packetSize
+
+	// Get first PCR
+	first, _, ok, err := dmx.nextPCR(s, pid, 0, n)
+	if err != nil {
+		return fmt.Errorf("astits: fetching first PCR failed: %w", err)
+	} else if !ok {
+		return fmt.Errorf("astits: PID %d carries no PCR: %w", pid, ErrPCRNotFound)
+	}
+
+	// PCRs are measured from the first PCR to handle wraparound, targets before it being moved to it
+	target := (targetFunc(first) - first + pcrWrapAround) % pcrWrapAround
+	if target > pcrWrapAround/2 {
+		target = 0
+	}
+
+	// Binary search the first packet whose next PCR is at or after the target
+	lo, hi := int64(0), n
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		c, idx, ok, err := dmx.nextPCR(s, pid, mid, hi)
+		if err != nil {
+			return fmt.Errorf("astits: fetching PCR after packet %d failed: %w", mid, err)
+		}
+		if !ok || (c-first+pcrWrapAround)%pcrWrapAround >= target {
+			hi = mid
+		} else {
+			lo = idx + 1
+		}
+	}
+
+	// Get packet
+	_, idx, ok, err := dmx.nextPCR(s, pid, lo, n)
+	if err != nil {
+		return fmt.Errorf("astits: fetching PCR after packet %d failed: %w", lo, err)
+	} else if !ok {
+		return fmt.Errorf("astits: no PCR of PID %d at or after target: %w", pid, ErrPCRNotFound)
+	}
+
+	// Seek
+	if _, err = s.Seek(idx*packetSize, io.SeekStart); err != nil {
+		return fmt.Errorf("astits: seeking to packet %d failed: %w", idx, err)
+	}
+
+	// Reset state
+	dmx.ccs = make(map[uint16]continuityState)
+	dmx.dataBuffer = nil
+	dmx.packetPool = newPacketPool()
+	return
+}
+
+// nextPCR returns the PCR of the first packet of pid carrying a PCR, looking from packet start up to packet end
+// excluded, as well as the index of that packet
+func (dmx *Demuxer) nextPCR(s io.Seeker, pid uint16, start, end int64) (pcr, idx int64, ok bool, err error) {
+	if _, err = s.Seek(start*int64(dmx.packetBuffer.packetSize), io.SeekStart); err != nil {
+		err = fmt.Errorf("astits: seeking to packet %d failed: %w", start, err)
+		return
+	}
+	for idx = start; idx < end; idx++ {
+		var p *Packet
+		if p, err = dmx.packetBuffer.next(); err != nil {
+			if err == ErrNoMorePackets {
+				err = nil
+				return
+			}
+			err = fmt.Errorf("astits: fetching next packet from buffer failed: %w", err)
+			return
+		}
+		if p.Header.PID == pid && p.Header.HasAdaptationField && p.AdaptationField != nil && p.AdaptationField.HasPCR {
+			return p.AdaptationField.PCR.Base*300 + p.AdaptationField.PCR.Extension, idx, true, nil
+		}
+	}
+	return
+}
@@ -0,0 +1,70 @@
+package astits
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDemuxerSeekToPCR(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(context.Background(), buf)
+	err := mx.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video})
+	assert.NoError(t, err)
+	err = mx.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x101, StreamType: StreamTypeAACAudio})
+	assert.NoError(t, err)
+	err = mx.SetPCRPID(0x100)
+	assert.NoError(t, err)
+
+	// A PCR every 100ms wrapping around after 2s, each followed by a PES whose PTS is its index
+	start := int64(pcrWrapAround - 2*clockFrequency)
+	for i := int64(0); i < 50; i++ {
+		_, err = mx.WritePCR(uint64((start + i*clockFrequency/10) % pcrWrapAround))
+		assert.NoError(t, err)
+		_, err = mx.WriteData(&MuxerData{
+			PES: &PESData{Data: []byte("test"), Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+				MarkerBits:      2,
+				PTS:             &ClockReference{Base: i},
+				PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+			}}},
+			PID: 0x101,
+		})
+		assert.NoError(t, err)
+	}
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	nextPTS := func() int64 {
+		for {
+			d, err := dmx.NextData()
+			if !assert.NoError(t, err) {
+				return -1
+			}
+			if d.PES != nil {
+				return d.PES.Header.OptionalHeader.PTS.Base
+			}
+		}
+	}
+
+	// After the wraparound
+	assert.NoError(t, dmx.SeekToTime(0x100, 2500*time.Millisecond))
+	assert.Equal(t, int64(25), nextPTS())
+	assert.Equal(t, int64(26), nextPTS())
+
+	// Before the wraparound, partial data being reset
+	assert.NoError(t, dmx.SeekToPCR(0x100, uint64(start+clockFrequency/2-1)))
+	assert.Equal(t, int64(5), nextPTS())
+
+	// Before the first PCR
+	assert.NoError(t, dmx.SeekToPCR(0x100, uint64(start-1)))
+	assert.Equal(t, int64(0), nextPTS())
+
+	// Errors
+	assert.True(t, errors.Is(dmx.SeekToTime(0x100, time.Minute), ErrPCRNotFound))
+	assert.True(t, errors.Is(dmx.SeekToTime(0x101, 0), ErrPCRNotFound))
+	assert.Equal(t, ErrReaderNotSeekable, NewDemuxer(context.Background(), bufio.NewReader(bytes.NewReader(buf.Bytes()))).SeekToPCR(0x100, 0))
+}
@@ -0,0 +1,128 @@
+package astits
+
+// StreamTypeMismatch represents a stream type declared in a PMT that doesn't match the one detected in the PES data
+type StreamTypeMismatch struct {
+	Declared StreamType
+	Detected StreamType
+}
+
+// DemuxerOptDetectStreamTypes returns the option to check the stream type declared in PMTs against the start codes
+// and sync words found at the start of PES data, which comes in handy with muxers writing wrong stream types.
+// PES data whose stream type doesn't match sets DemuxerData.StreamTypeMismatch and the stream type of the elementary
+// stream is corrected in place in the PMT, which Programs then reflects.
+func DemuxerOptDetectStreamTypes() func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optDetectStreamTypes = true
+	}
+}
+
+// detectStreamType checks the stream type declared for the PID of the PES data
+func (dmx *Demuxer) detectStreamType(d *DemuxerData) {
+	// Get elementary stream
+	var es *PMTElementaryStream
+	for _, pmt := range dmx.pmts {
+		for _, v := range pmt.ElementaryStreams {
+			if v.ElementaryPID == d.PID {
+				es = v
+			}
+		}
+	}
+	if es == nil {
+		return
+	}
+
+	// Detect stream type
+	t, ok := DetectStreamType(d.PES.Data)
+	if !ok || streamTypesMatch(es.StreamType, t) {
+		return
+	}
+	d.StreamTypeMismatch = &StreamTypeMismatch{
+		Declared: es.StreamType,
+		Detected: t,
+	}
+	es.StreamType = t
+}
+
+// streamTypesMatch checks whether a detected stream type matches the declared one
+// Private data may carry anything, such as AC-3 in DVB, and MPEG-1 and MPEG-2 streams can't be told apart
+func streamTypesMatch(declared, detected StreamType) bool {
+	switch declared {
+	case StreamTypePrivateData:
+		return true
+	case StreamTypeMPEG1Video:
+		return detected == StreamTypeMPEG2Video
+	case StreamTypeMPEG1Audio:
+		return detected == StreamTypeMPEG1Audio || detected == StreamTypeMPEG2Audio
+	case StreamTypeMPEG2Audio:
+		return detected == StreamTypeMPEG1Audio || detected == StreamTypeMPEG2Audio
+	}
+	return declared == detected
+}
+
+// DetectStreamType detects the stream type of PES data based on its first bytes, which must be the start code of a
+// NAL unit for H.264 and H.265, of a sequence header, a GOP or a picture for MPEG-2 video or the sync word of a frame
+// for ADTS AAC, MPEG audio, AC-3 and E-AC-3. MPEG-1 video is detected as MPEG-2 video.
+func DetectStreamType(data []byte) (t StreamType, ok bool) {
+	// Video
+	if i := startCodeLength(data); i > 0 {
+		if i+1 >= len(data) {
+			return
+		}
+		b, b2 := data[i], data[i+1]
+		switch {
+		case b == 0x00 || b == 0xb3 || b == 0xb8:
+			return StreamTypeMPEG2Video, true
+		// H.265 VPS, SPS, PPS, access unit delimiter and SEI, whose 2 bytes header has a 0 layer ID and a non 0
+		// temporal ID
+		case b&0x81 == 0 && (b>>1 >= 32 && b>>1 <= 35 || b>>1 == 39) && b2&0xf8 == 0 && b2&0x7 != 0:
+			return StreamTypeH265Video, true
+		// H.264 slices, SEI, SPS, PPS and access unit delimiter, SEI and access unit delimiter having a 0 nal_ref_idc
+		case b&0x80 == 0:
+			switch b & 0x1f {
+			case 1, 5, 7, 8:
+				return StreamTypeH264Video, true
+			case 6, 9:
+				if b&0x60 == 0 {
+					return StreamTypeH264Video, true
+				}
+			}
+		}
+		return
+	}
+
+	// Audio
+	if len(data) < 6 {
+		return
+	}
+	switch {
+	// ADTS has a 0 layer
+	case data[0] == 0xff && data[1]&0xf6 == 0xf0:
+		return StreamTypeAACAudio, true
+	case data[0] == 0xff && data[1]&0xe0 == 0xe0 && data[1]&0x6 != 0:
+		// Version is 3 for MPEG-1
+		if data[1]>>3&0x3 == 3 {
+			return StreamTypeMPEG1Audio, true
+		}
+		return StreamTypeMPEG2Audio, true
+	case data[0] == 0x0b && data[1] == 0x77:
+		// bsid tells AC-3 and E-AC-3 apart
+		switch bsid := data[5] >> 3; {
+		case bsid <= 8:
+			return StreamTypeAC3Audio, true
+		case bsid > 10 && bsid <= 16:
+			return StreamTypeEAC3Audio, true
+		}
+	}
+	return
+}
+
+// startCodeLength returns the length of the 3 or 4 bytes start code the data starts with, 0 if there is none
+func startCodeLength(data []byte) int {
+	switch {
+	case len(data) >= 3 && data[0] == 0 && data[1] == 0 && data[2] == 1:
+		return 3
+	case len(data) >= 4 && data[0] == 0 && data[1] == 0 && data[2] == 0 && data[3] == 1:
+		return 4
+	}
+	return 0
+}
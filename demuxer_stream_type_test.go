@@ -0,0 +1,93 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectStreamType(t *testing.T) {
+	for _, v := range []struct {
+		data []byte
+		ok   bool
+		t    StreamType
+	}{
+		{data: []byte{0x0, 0x0, 0x0, 0x1, 0x9, 0xf0}, ok: true, t: StreamTypeH264Video},
+		{data: []byte{0x0, 0x0, 0x1, 0x67, 0x64}, ok: true, t: StreamTypeH264Video},
+		{data: []byte{0x0, 0x0, 0x0, 0x1, 0x46, 0x1, 0x50}, ok: true, t: StreamTypeH265Video},
+		{data: []byte{0x0, 0x0, 0x1, 0x40, 0x1, 0xc}, ok: true, t: StreamTypeH265Video},
+		{data: []byte{0x0, 0x0, 0x1, 0xb3, 0x14}, ok: true, t: StreamTypeMPEG2Video},
+		{data: []byte{0xff, 0xf1, 0x4c, 0x80, 0x2, 0x9f, 0xfc}, ok: true, t: StreamTypeAACAudio},
+		{data: []byte{0xff, 0xfb, 0x90, 0x64, 0x0, 0x0}, ok: true, t: StreamTypeMPEG1Audio},
+		{data: []byte{0xff, 0xf3, 0x90, 0x64, 0x0, 0x0}, ok: true, t: StreamTypeMPEG2Audio},
+		{data: []byte{0x0b, 0x77, 0x0, 0x0, 0x40, 0x40}, ok: true, t: StreamTypeAC3Audio},
+		{data: []byte{0x0b, 0x77, 0x0, 0x9, 0x30, 0x80}, ok: true, t: StreamTypeEAC3Audio},
+		{data: []byte{0x0, 0x0, 0x1, 0x66, 0x0}},
+		{data: []byte{0x1, 0x2, 0x3, 0x4, 0x5, 0x6}},
+		{data: []byte{0xff}},
+	} {
+		st, ok := DetectStreamType(v.data)
+		assert.Equal(t, v.ok, ok, "%#x", v.data)
+		assert.Equal(t, v.t, st, "%#x", v.data)
+	}
+}
+
+func TestDemuxerDetectStreamTypes(t *testing.T) {
+	// PMT declares AAC while PES data is H.264
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(context.Background(), buf)
+	err := mx.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeAACAudio})
+	assert.NoError(t, err)
+	err = mx.SetPCRPID(0x100)
+	assert.NoError(t, err)
+
+	// Tables are repeated since PSI is only returned once the next section starts
+	pes := &MuxerData{
+		PID: 0x100,
+		PES: &PESData{
+			Data: []byte{0x0, 0x0, 0x0, 0x1, 0x9, 0xf0},
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{
+					MarkerBits:      2,
+					PTS:             &ClockReference{Base: 1},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				},
+				StreamID: 0xe0,
+			},
+		},
+	}
+	_, err = mx.WriteData(pes)
+	assert.NoError(t, err)
+	_, err = mx.WriteTables()
+	assert.NoError(t, err)
+	_, err = mx.WriteData(pes)
+	assert.NoError(t, err)
+
+	// Without the option, nothing is detected
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		assert.Nil(t, d.StreamTypeMismatch)
+	}
+
+	// With the option, the mismatch is reported and the stream type is corrected
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptDetectStreamTypes())
+	ps, err := dmx.Programs()
+	assert.NoError(t, err)
+	assert.Equal(t, StreamTypeAACAudio, ps[0].ElementaryStreams[0].StreamType)
+	var m *StreamTypeMismatch
+	for {
+		d, err := dmx.NextData()
+		if err == ErrNoMorePackets {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		if m == nil {
+			m = d.StreamTypeMismatch
+		}
+	}
+	assert.Equal(t, &StreamTypeMismatch{Declared: StreamTypeAACAudio, Detected: StreamTypeH264Video}, m)
+	assert.Equal(t, StreamTypeH264Video, ps[0].ElementaryStreams[0].StreamType)
+}
@@ -3,6 +3,7 @@ package astits
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"testing"
@@ -84,6 +85,184 @@ func TestDemuxerNextData(t *testing.T) {
 	assert.EqualError(t, err, ErrNoMorePackets.Error())
 }
 
+func TestDemuxerFromPackets(t *testing.T) {
+	// PAT then 2 PES, the last one being dropped because of a discontinuity
+	pes := func(pusi bool, cc uint8, payload []byte) []byte {
+		buf := &bytes.Buffer{}
+		w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+		_, err := writePacket(w, &Packet{
+			Header: &PacketHeader{
+				ContinuityCounter:         cc,
+				HasPayload:                true,
+				PayloadUnitStartIndicator: pusi,
+				PID:                       0x100,
+			},
+			Payload: payload,
+		}, MpegTsPacketSize)
+		assert.NoError(t, err)
+		return buf.Bytes()
+	}
+	dmx := NewDemuxerFromPackets(context.Background(), [][]byte{
+		patExpectedBytes(0),
+		pes(true, 0, []byte{0x0, 0x0, 0x1, 0xbf, 0x0, 0x4, 0x74, 0x65, 0x73, 0x74}),
+		pes(true, 1, []byte{0x0, 0x0, 0x1, 0xbf, 0x0, 0x8, 0x74, 0x65, 0x73, 0x74}),
+		pes(false, 3, []byte{0x74, 0x65, 0x73, 0x74}),
+	})
+
+	// PES is complete as soon as the next one starts
+	d, err := dmx.NextData()
+	assert.NoError(t, err)
+	assert.NotNil(t, d.PES)
+	assert.Equal(t, []byte("test"), d.PES.Data)
+
+	// PAT is only complete once the end of the stream is reached
+	d, err = dmx.NextData()
+	assert.NoError(t, err)
+	assert.NotNil(t, d.PAT)
+	assert.Equal(t, []*PATProgram{{ProgramMapID: pmtStartPID, ProgramNumber: 1}}, d.PAT.Programs)
+
+	_, err = dmx.NextData()
+	assert.Equal(t, ErrNoMorePackets, err)
+}
+
+func TestDemuxerKeepRawPackets(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	b1, _ := packet(*packetHeader, *packetAdaptationField, []byte("1"), true)
+	w.Write(b1)
+	b2, _ := packet(*packetHeader, *packetAdaptationField, []byte("2"), true)
+	w.Write(b2)
+
+	// Raw bytes are not kept by default
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	p, err := dmx.NextPacket()
+	assert.NoError(t, err)
+	assert.Nil(t, p.Raw)
+
+	// Raw bytes are copied since the read buffer is reused
+	dmx = NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptKeepRawPackets())
+	p1, err := dmx.NextPacket()
+	assert.NoError(t, err)
+	p2, err := dmx.NextPacket()
+	assert.NoError(t, err)
+	assert.Equal(t, b1, p1.Raw)
+	assert.Equal(t, b2, p2.Raw)
+}
+
+func TestDemuxerErrorRecovery(t *testing.T) {
+	var bs [][]byte
+	for i, h := range []PacketHeader{
+		{HasPayload: true, PID: 0x100},
+		{HasPayload: true, PID: 0x101},
+		{HasPayload: true, PID: 0x102, TransportErrorIndicator: true},
+		{HasPayload: true, PID: 0x103},
+	} {
+		b, _ := packetShort(h, []byte("test"))
+		bs = append(bs, b[:MpegTsPacketSize])
+		// Garbage following the first packet
+		if i == 0 {
+			bs = append(bs, bytes.Repeat([]byte{0x0}, 10))
+		}
+	}
+	b := bytes.Join(bs, nil)
+
+	// Corrupt packets abort parsing by default
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(b), DemuxerOptPacketSize(MpegTsPacketSize))
+	_, err := dmx.NextPacket()
+	assert.NoError(t, err)
+	_, err = dmx.NextPacket()
+	assert.True(t, errors.Is(err, ErrPacketMustStartWithASyncByte))
+
+	// Corrupt packets are dropped and the reader is resynchronized
+	dmx = NewDemuxer(context.Background(), bytes.NewReader(b), DemuxerOptPacketSize(MpegTsPacketSize), DemuxerOptErrorRecovery())
+	var pids []uint16
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if err != nil {
+			break
+		}
+		pids = append(pids, p.Header.PID)
+	}
+	assert.Equal(t, []uint16{0x100, 0x101, 0x103}, pids)
+	assert.Equal(t, DemuxerStats{CorruptPackets: 1, Resyncs: 1, SkippedBytes: 10}, dmx.Stats())
+}
+
+func TestDemuxerSkipCRCCheck(t *testing.T) {
+	// Corrupt the PAT CRC32
+	b := patExpectedBytes(0)
+	b[20] ^= 0xff
+
+	// Parsing fails by default
+	dmx := NewDemuxerFromPackets(context.Background(), [][]byte{b})
+	_, err := dmx.NextData()
+	assert.Error(t, err)
+
+	// Section is returned when CRC32 check is skipped
+	dmx = NewDemuxerFromPackets(context.Background(), [][]byte{b}, DemuxerOptSkipCRCCheck())
+	d, err := dmx.NextData()
+	assert.NoError(t, err)
+	if assert.NotNil(t, d.PAT) {
+		assert.False(t, d.CRCValid)
+		assert.Equal(t, []*PATProgram{{ProgramMapID: pmtStartPID, ProgramNumber: 1}}, d.PAT.Programs)
+	}
+
+	// Valid sections are flagged as such
+	dmx = NewDemuxerFromPackets(context.Background(), [][]byte{patExpectedBytes(0)}, DemuxerOptSkipCRCCheck())
+	d, err = dmx.NextData()
+	assert.NoError(t, err)
+	assert.True(t, d.CRCValid)
+}
+
+func TestDemuxerTransportPrivateDataHandler(t *testing.T) {
+	pkt := func(pid uint16, tpd []byte) []byte {
+		buf := &bytes.Buffer{}
+		w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+		_, err := writePacket(w, &Packet{
+			AdaptationField: &PacketAdaptationField{
+				HasTransportPrivateData:    true,
+				TransportPrivateData:       tpd,
+				TransportPrivateDataLength: len(tpd),
+			},
+			Header: &PacketHeader{
+				HasAdaptationField: true,
+				HasPayload:         true,
+				PID:                pid,
+			},
+			Payload: []byte("test"),
+		}, MpegTsPacketSize)
+		assert.NoError(t, err)
+		return buf.Bytes()
+	}
+
+	var got [][]byte
+	dmx := NewDemuxerFromPackets(context.Background(), [][]byte{
+		pkt(0x100, []byte("private")),
+		pkt(0x101, []byte("ignored")),
+	}, DemuxerOptTransportPrivateDataHandler(0x100, func(pid uint16, data []byte) error {
+		assert.Equal(t, uint16(0x100), pid)
+		got = append(got, data)
+		return nil
+	}))
+	for {
+		if _, err := dmx.NextPacket(); err != nil {
+			assert.Equal(t, ErrNoMorePackets, err)
+			break
+		}
+	}
+	assert.Equal(t, [][]byte{[]byte("private")}, got)
+
+	// Handler errors are returned
+	dmx = NewDemuxerFromPackets(context.Background(), [][]byte{pkt(0x100, []byte("private"))}, DemuxerOptTransportPrivateDataHandler(0x100, func(pid uint16, data []byte) error {
+		return errors.New("test")
+	}))
+	_, err := dmx.NextPacket()
+	assert.EqualError(t, err, "astits: handling transport private data of PID 256 failed: test")
+}
+
 func TestDemuxerRewind(t *testing.T) {
 	r := bytes.NewReader([]byte("content"))
 	dmx := NewDemuxer(context.Background(), r)
@@ -101,6 +280,99 @@ func TestDemuxerRewind(t *testing.T) {
 	assert.Nil(t, dmx.packetBuffer)
 }
 
+func TestDemuxerOnDiscontinuity(t *testing.T) {
+	pkt := func(pid uint16, cc uint8, hasPayload, discontinuity bool) []byte {
+		buf := &bytes.Buffer{}
+		w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+		p := &Packet{Header: &PacketHeader{ContinuityCounter: cc, HasPayload: hasPayload, PID: pid}}
+		if hasPayload {
+			p.Payload = []byte("test")
+		}
+		if !hasPayload || discontinuity {
+			p.Header.HasAdaptationField = true
+			p.AdaptationField = &PacketAdaptationField{DiscontinuityIndicator: discontinuity}
+		}
+		_, err := writePacket(w, p, MpegTsPacketSize)
+		assert.NoError(t, err)
+		return buf.Bytes()
+	}
+
+	type discontinuity struct {
+		pid           uint16
+		expected, got uint8
+	}
+	var ds []discontinuity
+	dmx := NewDemuxerFromPackets(context.Background(), [][]byte{
+		pkt(0x100, 15, true, false),
+		pkt(0x100, 0, true, false),  // Wraps around
+		pkt(0x100, 0, true, false),  // Duplicate
+		pkt(0x100, 0, false, false), // No payload
+		pkt(0x100, 1, true, false),
+		pkt(0x101, 5, true, false),
+		pkt(0x100, 3, true, false),  // Jump
+		pkt(0x100, 4, false, false), // No payload incrementing
+		pkt(0x100, 9, true, true),   // Signaled
+		pkt(0x100, 10, true, false),
+		pkt(PIDNull, 0, true, false),
+		pkt(PIDNull, 7, true, false),
+	}, DemuxerOptOnDiscontinuity(func(pid uint16, expected, got uint8) {
+		ds = append(ds, discontinuity{pid: pid, expected: expected, got: got})
+	}))
+	for {
+		_, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []discontinuity{
+		{pid: 0x100, expected: 2, got: 3},
+		{pid: 0x100, expected: 3, got: 4},
+	}, ds)
+}
+
+func TestDemuxerReset(t *testing.T) {
+	stream := func(pid uint16) []byte {
+		buf := &bytes.Buffer{}
+		mx := NewMuxer(context.Background(), buf)
+		err := mx.AddElementaryStream(PMTElementaryStream{ElementaryPID: pid, StreamType: StreamTypeH264Video})
+		assert.NoError(t, err)
+		err = mx.SetPCRPID(pid)
+		assert.NoError(t, err)
+		_, err = mx.WriteTables()
+		assert.NoError(t, err)
+		return buf.Bytes()
+	}
+	pmtPID := func(dmx *Demuxer) uint16 {
+		for {
+			d, err := dmx.NextData()
+			if !assert.NoError(t, err) {
+				return 0
+			}
+			if d.PMT != nil {
+				return d.PMT.ElementaryStreams[0].ElementaryPID
+			}
+		}
+	}
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(stream(0x100)), DemuxerOptErrorRecovery())
+	ps, err := dmx.Programs()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x100), ps[0].ElementaryStreams[0].ElementaryPID)
+	assert.Equal(t, uint16(0x100), pmtPID(dmx))
+	dmx.stats.Resyncs = 1
+
+	// State of the previous stream is cleared
+	dmx.Reset(bytes.NewReader(stream(0x200)))
+	assert.Equal(t, DemuxerStats{}, dmx.Stats())
+	assert.Equal(t, 0, len(dmx.pmts))
+	assert.True(t, dmx.optErrorRecovery)
+	ps, err = dmx.Programs()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x200), ps[0].ElementaryStreams[0].ElementaryPID)
+	assert.Equal(t, uint16(0x200), pmtPID(dmx))
+}
+
 func BenchmarkDemuxer_NextData(b *testing.B) {
 	b.ReportAllocs()
 
@@ -36,15 +36,21 @@ const (
 	DescriptorTagEnhancedAC3                = 0x7a
 	DescriptorTagExtendedEvent              = 0x4e
 	DescriptorTagExtension                  = 0x7f
+	DescriptorTagFTAContentManagement       = 0x7e
 	DescriptorTagISO639LanguageAndAudioType = 0xa
 	DescriptorTagLocalTimeOffset            = 0x58
 	DescriptorTagMaximumBitrate             = 0xe
+	DescriptorTagMosaic                     = 0x51
+	DescriptorTagMVCExtension               = 0x31
+	DescriptorTagMVCOperationPoint          = 0x33
 	DescriptorTagNetworkName                = 0x40
 	DescriptorTagParentalRating             = 0x55
 	DescriptorTagPrivateDataIndicator       = 0xf
 	DescriptorTagPrivateDataSpecifier       = 0x5f
 	DescriptorTagRegistration               = 0x5
 	DescriptorTagService                    = 0x48
+	DescriptorTagServiceAvailability        = 0x72
+	DescriptorTagServiceMove                = 0x60
 	DescriptorTagShortEvent                 = 0x4d
 	DescriptorTagStreamIdentifier           = 0x52
 	DescriptorTagSubtitling                 = 0x59
@@ -56,9 +62,44 @@ const (
 // Descriptor extension tags
 // Chapter: 6.3 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
 const (
+	DescriptorTagExtensionOpus               = 0x80 // User defined
 	DescriptorTagExtensionSupplementaryAudio = 0x6
 )
 
+// Mosaic cell linkage infos
+// Chapter: 6.2.21 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
+const (
+	MosaicCellLinkageInfoBouquet     = 0x1
+	MosaicCellLinkageInfoEvent       = 0x4
+	MosaicCellLinkageInfoOtherMosaic = 0x3
+	MosaicCellLinkageInfoService     = 0x2
+	MosaicCellLinkageInfoUndefined   = 0x0
+)
+
+// Mosaic logical cell presentation infos
+// Chapter: 6.2.21 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
+const (
+	MosaicLogicalCellPresentationInfoGraphicsText = 0x3
+	MosaicLogicalCellPresentationInfoStillPicture = 0x2
+	MosaicLogicalCellPresentationInfoUndefined    = 0x0
+	MosaicLogicalCellPresentationInfoVideo        = 0x1
+)
+
+// Registration format identifiers, which are the ASCII codes of 4 characters registered with the SMPTE RA
+// Link: https://smpte-ra.org/registered-mpeg-ts-ids
+const (
+	RegistrationFormatIdentifierAC3  = 0x41432d33 // AC-3
+	RegistrationFormatIdentifierBSSD = 0x42535344 // SMPTE 302M audio
+	RegistrationFormatIdentifierCUEI = 0x43554549 // SCTE 35
+	RegistrationFormatIdentifierEAC3 = 0x45414333 // E-AC-3
+	RegistrationFormatIdentifierHDMV = 0x48444d56 // Blu-ray
+	RegistrationFormatIdentifierHEVC = 0x48455643
+	RegistrationFormatIdentifierID3  = 0x49443320 // ID3 timed metadata
+	RegistrationFormatIdentifierKLVA = 0x4b4c5641 // KLV metadata
+	RegistrationFormatIdentifierOpus = 0x4f707573
+	RegistrationFormatIdentifierVC1  = 0x56432d31 // VC-1
+)
+
 // Service types
 // Chapter: 6.2.33 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
 const (
@@ -97,16 +138,22 @@ type Descriptor struct {
 	EnhancedAC3                *DescriptorEnhancedAC3
 	ExtendedEvent              *DescriptorExtendedEvent
 	Extension                  *DescriptorExtension
+	FTAContentManagement       *DescriptorFTAContentManagement
 	ISO639LanguageAndAudioType *DescriptorISO639LanguageAndAudioType
 	Length                     uint8
 	LocalTimeOffset            *DescriptorLocalTimeOffset
 	MaximumBitrate             *DescriptorMaximumBitrate
+	Mosaic                     *DescriptorMosaic
+	MVCExtension               *DescriptorMVCExtension
+	MVCOperationPoint          *DescriptorMVCOperationPoint
 	NetworkName                *DescriptorNetworkName
 	ParentalRating             *DescriptorParentalRating
 	PrivateDataIndicator       *DescriptorPrivateDataIndicator
 	PrivateDataSpecifier       *DescriptorPrivateDataSpecifier
 	Registration               *DescriptorRegistration
 	Service                    *DescriptorService
+	ServiceAvailability        *DescriptorServiceAvailability
+	ServiceMove                *DescriptorServiceMove
 	ShortEvent                 *DescriptorShortEvent
 	StreamIdentifier           *DescriptorStreamIdentifier
 	Subtitling                 *DescriptorSubtitling
@@ -611,6 +658,7 @@ func newDescriptorExtendedEventItem(i *astikit.BytesIterator) (d *DescriptorExte
 // DescriptorExtension represents an extension descriptor
 // Chapter: 6.2.16 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
 type DescriptorExtension struct {
+	Opus               *DescriptorExtensionOpus
 	SupplementaryAudio *DescriptorExtensionSupplementaryAudio
 	Tag                uint8
 	Unknown            *[]byte
@@ -629,6 +677,11 @@ func newDescriptorExtension(i *astikit.BytesIterator, offsetEnd int) (d *Descrip
 
 	// Switch on tag
 	switch d.Tag {
+	case DescriptorTagExtensionOpus:
+		if d.Opus, err = newDescriptorExtensionOpus(i); err != nil {
+			err = fmt.Errorf("astits: parsing extension Opus descriptor failed: %w", err)
+			return
+		}
 	case DescriptorTagExtensionSupplementaryAudio:
 		if d.SupplementaryAudio, err = newDescriptorExtensionSupplementaryAudio(i, offsetEnd); err != nil {
 			err = fmt.Errorf("astits: parsing extension supplementary audio descriptor failed: %w", err)
@@ -648,6 +701,25 @@ func newDescriptorExtension(i *astikit.BytesIterator, offsetEnd int) (d *Descrip
 	return
 }
 
+// DescriptorExtensionOpus represents an Opus extension descriptor
+// Chapter: 6.2 | Link: https://opus-codec.org/docs/ETSI_TS_opus-v0.1.3-draft.pdf
+type DescriptorExtensionOpus struct {
+	ChannelConfigCode uint8 // 0x0 for dual mono, 0x1 to 0x8 for that number of channels in the Vorbis order
+}
+
+func newDescriptorExtensionOpus(i *astikit.BytesIterator) (d *DescriptorExtensionOpus, err error) {
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &DescriptorExtensionOpus{ChannelConfigCode: uint8(b)}
+	return
+}
+
 // DescriptorExtensionSupplementaryAudio represents a supplementary audio extension descriptor
 // Chapter: 6.4.10 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
 type DescriptorExtensionSupplementaryAudio struct {
@@ -691,6 +763,30 @@ func newDescriptorExtensionSupplementaryAudio(i *astikit.BytesIterator, offsetEn
 	return
 }
 
+// DescriptorFTAContentManagement represents an FTA content management descriptor
+// Chapter: 6.2.18.1 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
+type DescriptorFTAContentManagement struct {
+	ControlRemoteAccessOverInternet uint8 // 2 bits
+	DoNotApplyRevocation            bool
+	DoNotScramble                   bool
+	UserDefined                     bool
+}
+
+func newDescriptorFTAContentManagement(i *astikit.BytesIterator) (d *DescriptorFTAContentManagement, err error) {
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+	d = &DescriptorFTAContentManagement{
+		ControlRemoteAccessOverInternet: uint8(b>>1) & 0x3,
+		DoNotApplyRevocation:            b&0x1 > 0,
+		DoNotScramble:                   b&0x8 > 0,
+		UserDefined:                     b&0x80 > 0,
+	}
+	return
+}
+
 // DescriptorISO639LanguageAndAudioType represents an ISO639 language descriptor
 // https://github.com/gfto/bitstream/blob/master/mpeg/psi/desc_0a.h
 // FIXME (barbashov) according to Chapter 2.6.18 ISO/IEC 13818-1:2015 there could be not one, but multiple such descriptors
@@ -803,6 +899,226 @@ func newDescriptorMaximumBitrate(i *astikit.BytesIterator) (d *DescriptorMaximum
 	return
 }
 
+// DescriptorMVCExtension represents an MVC extension descriptor, describing the views of a stereoscopic or
+// multiview video sub-bitstream
+// Chapter: 2.6.78 | Link: https://www.itu.int/rec/T-REC-H.222.0
+type DescriptorMVCExtension struct {
+	AverageBitrate            uint16 // In kbit/second, 0 means unknown
+	BaseViewIsLeftEyeview     bool
+	MaximumBitrate            uint16 // In kbit/second, 0 means unknown
+	NoPrefixNALUnitPresent    bool
+	NoSEINALUnitPresent       bool
+	TemporalIDEnd             uint8
+	TemporalIDStart           uint8
+	ViewAssociationNotPresent bool
+	ViewOrderIndexMax         uint16
+	ViewOrderIndexMin         uint16
+}
+
+func newDescriptorMVCExtension(i *astikit.BytesIterator) (d *DescriptorMVCExtension, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(8); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &DescriptorMVCExtension{
+		AverageBitrate:            uint16(bs[0])<<8 | uint16(bs[1]),
+		BaseViewIsLeftEyeview:     bs[4]&0x40 > 0,
+		MaximumBitrate:            uint16(bs[2])<<8 | uint16(bs[3]),
+		NoPrefixNALUnitPresent:    bs[7]&0x01 > 0,
+		NoSEINALUnitPresent:       bs[7]&0x02 > 0,
+		TemporalIDEnd:             bs[7] >> 2 & 0x7,
+		TemporalIDStart:           bs[7] >> 5,
+		ViewAssociationNotPresent: bs[4]&0x80 > 0,
+		ViewOrderIndexMax:         uint16(bs[5]&0x03)<<8 | uint16(bs[6]),
+		ViewOrderIndexMin:         uint16(bs[4]&0x0f)<<6 | uint16(bs[5]>>2),
+	}
+	return
+}
+
+// DescriptorMVCOperationPoint represents an MVC operation point descriptor, describing the operation points
+// of an MVC video stream for each level
+// Chapter: 2.6.82 | Link: https://www.itu.int/rec/T-REC-H.222.0
+type DescriptorMVCOperationPoint struct {
+	AVCCompatibleFlags uint8
+	ConstraintSetFlags uint8 // constraint_set0_flag to constraint_set5_flag, constraint_set0_flag being the highest bit
+	Levels             []*DescriptorMVCOperationPointLevel
+	ProfileIDC         uint8
+}
+
+// DescriptorMVCOperationPointLevel represents an MVC operation point descriptor level
+type DescriptorMVCOperationPointLevel struct {
+	LevelIDC        uint8
+	OperationPoints []*DescriptorMVCOperationPointItem
+}
+
+// DescriptorMVCOperationPointItem represents an MVC operation point
+type DescriptorMVCOperationPointItem struct {
+	ApplicableTemporalID uint8
+	ESReferences         []uint8 // Hierarchy layer indexes of the elementary streams making up the operation point
+	NumTargetOutputViews uint8
+}
+
+func newDescriptorMVCOperationPoint(i *astikit.BytesIterator) (d *DescriptorMVCOperationPoint, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(3); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &DescriptorMVCOperationPoint{
+		AVCCompatibleFlags: bs[1] & 0x3,
+		ConstraintSetFlags: bs[1] >> 2,
+		ProfileIDC:         bs[0],
+	}
+
+	// Loop through levels
+	levelCount := int(bs[2])
+	for idxLevel := 0; idxLevel < levelCount; idxLevel++ {
+		// Get next bytes
+		if bs, err = i.NextBytesNoCopy(2); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+
+		// Create level
+		l := &DescriptorMVCOperationPointLevel{LevelIDC: bs[0]}
+
+		// Loop through operation points
+		operationPointsCount := int(bs[1])
+		for idxOperationPoint := 0; idxOperationPoint < operationPointsCount; idxOperationPoint++ {
+			// Get next bytes
+			if bs, err = i.NextBytesNoCopy(3); err != nil {
+				err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+				return
+			}
+
+			// Create operation point
+			op := &DescriptorMVCOperationPointItem{
+				ApplicableTemporalID: bs[0] & 0x7,
+				NumTargetOutputViews: bs[1],
+			}
+
+			// Get ES references
+			esCount := int(bs[2])
+			if bs, err = i.NextBytesNoCopy(esCount); err != nil {
+				err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+				return
+			}
+			for _, b := range bs {
+				op.ESReferences = append(op.ESReferences, b&0x3f)
+			}
+			l.OperationPoints = append(l.OperationPoints, op)
+		}
+		d.Levels = append(d.Levels, l)
+	}
+	return
+}
+
+// DescriptorMosaic represents a mosaic descriptor, describing how the screen is split into elementary cells and
+// which service, event, etc. each logical cell, made of one or more elementary cells, links to
+// Chapter: 6.2.21 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
+type DescriptorMosaic struct {
+	EntryPoint                        bool
+	LogicalCells                      []*DescriptorMosaicLogicalCell
+	NumberOfHorizontalElementaryCells uint8 // Number of columns minus one
+	NumberOfVerticalElementaryCells   uint8 // Number of rows minus one
+}
+
+// DescriptorMosaicLogicalCell represents a mosaic descriptor logical cell
+// Chapter: 6.2.21 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
+type DescriptorMosaicLogicalCell struct {
+	BouquetID                   uint16 // Set when cell linkage info is MosaicCellLinkageInfoBouquet
+	CellLinkageInfo             uint8
+	ElementaryCellIDs           []uint8
+	EventID                     uint16 // Set when cell linkage info is MosaicCellLinkageInfoEvent
+	LogicalCellID               uint8
+	LogicalCellPresentationInfo uint8
+	OriginalNetworkID           uint16 // Set when cell linkage info is a service, another mosaic or an event
+	ServiceID                   uint16 // Set when cell linkage info is a service, another mosaic or an event
+	TransportStreamID           uint16 // Set when cell linkage info is a service, another mosaic or an event
+}
+
+func newDescriptorMosaic(i *astikit.BytesIterator, offsetEnd int) (d *DescriptorMosaic, err error) {
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &DescriptorMosaic{
+		EntryPoint:                        b&0x80 > 0,
+		NumberOfHorizontalElementaryCells: b >> 4 & 0x7,
+		NumberOfVerticalElementaryCells:   b & 0x7,
+	}
+
+	// Loop
+	for i.Offset() < offsetEnd {
+		// Get next bytes
+		var bs []byte
+		if bs, err = i.NextBytesNoCopy(3); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+
+		// Create logical cell
+		c := &DescriptorMosaicLogicalCell{
+			LogicalCellID:               bs[0] >> 2,
+			LogicalCellPresentationInfo: bs[1] & 0x7,
+		}
+
+		// Elementary cell ids
+		if bs, err = i.NextBytesNoCopy(int(bs[2])); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+		for _, b := range bs {
+			c.ElementaryCellIDs = append(c.ElementaryCellIDs, b&0x3f)
+		}
+
+		// Cell linkage info
+		if b, err = i.NextByte(); err != nil {
+			err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+			return
+		}
+		c.CellLinkageInfo = b
+
+		// Linked entity
+		switch c.CellLinkageInfo {
+		case MosaicCellLinkageInfoBouquet:
+			if bs, err = i.NextBytesNoCopy(2); err != nil {
+				err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+				return
+			}
+			c.BouquetID = uint16(bs[0])<<8 | uint16(bs[1])
+		case MosaicCellLinkageInfoService, MosaicCellLinkageInfoOtherMosaic, MosaicCellLinkageInfoEvent:
+			n := 6
+			if c.CellLinkageInfo == MosaicCellLinkageInfoEvent {
+				n = 8
+			}
+			if bs, err = i.NextBytesNoCopy(n); err != nil {
+				err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+				return
+			}
+			c.OriginalNetworkID = uint16(bs[0])<<8 | uint16(bs[1])
+			c.TransportStreamID = uint16(bs[2])<<8 | uint16(bs[3])
+			c.ServiceID = uint16(bs[4])<<8 | uint16(bs[5])
+			if c.CellLinkageInfo == MosaicCellLinkageInfoEvent {
+				c.EventID = uint16(bs[6])<<8 | uint16(bs[7])
+			}
+		}
+		d.LogicalCells = append(d.LogicalCells, c)
+	}
+	return
+}
+
 // DescriptorNetworkName represents a network name descriptor
 // Chapter: 6.2.27 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
 type DescriptorNetworkName struct {
@@ -980,6 +1296,64 @@ func newDescriptorService(i *astikit.BytesIterator) (d *DescriptorService, err e
 	return
 }
 
+// DescriptorServiceAvailability represents a service availability descriptor, listing the cells in which the service
+// is available or unavailable
+// Chapter: 6.2.34 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
+type DescriptorServiceAvailability struct {
+	AvailabilityFlag bool // Whether the service is available in the cells, or in all cells but them
+	CellIDs          []uint16
+}
+
+func newDescriptorServiceAvailability(i *astikit.BytesIterator, offsetEnd int) (d *DescriptorServiceAvailability, err error) {
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &DescriptorServiceAvailability{AvailabilityFlag: b&0x80 > 0}
+
+	// Loop
+	for i.Offset() < offsetEnd {
+		// Get next bytes
+		var bs []byte
+		if bs, err = i.NextBytesNoCopy(2); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+		d.CellIDs = append(d.CellIDs, uint16(bs[0])<<8|uint16(bs[1]))
+	}
+	return
+}
+
+// DescriptorServiceMove represents a service move descriptor, signalling that the service is moving to another
+// transport stream
+// Chapter: 6.2.35 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
+type DescriptorServiceMove struct {
+	NewOriginalNetworkID uint16
+	NewServiceID         uint16
+	NewTransportStreamID uint16
+}
+
+func newDescriptorServiceMove(i *astikit.BytesIterator) (d *DescriptorServiceMove, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(6); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &DescriptorServiceMove{
+		NewOriginalNetworkID: uint16(bs[0])<<8 | uint16(bs[1]),
+		NewServiceID:         uint16(bs[4])<<8 | uint16(bs[5]),
+		NewTransportStreamID: uint16(bs[2])<<8 | uint16(bs[3]),
+	}
+	return
+}
+
 // DescriptorShortEvent represents a short event descriptor
 // Chapter: 6.2.37 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
 type DescriptorShortEvent struct {
@@ -1127,6 +1501,16 @@ type DescriptorTeletextItem struct {
 	Type     uint8
 }
 
+// PageNumber returns the page number as displayed by teletext decoders, magazine 0 being magazine 8: magazine 1
+// and page 0 is page 100 and magazine 0 and page 88 is page 888
+func (itm *DescriptorTeletextItem) PageNumber() uint16 {
+	m := uint16(itm.Magazine)
+	if m == 0 {
+		m = 8
+	}
+	return m*100 + uint16(itm.Page)
+}
+
 func newDescriptorTeletext(i *astikit.BytesIterator, offsetEnd int) (d *DescriptorTeletext, err error) {
 	// Create descriptor
 	d = &DescriptorTeletext{}
@@ -1278,165 +1662,201 @@ func parseDescriptors(i *astikit.BytesIterator) (o []*Descriptor, err error) {
 
 	// Loop
 	if length > 0 {
-		offsetEnd := i.Offset() + length
-		for i.Offset() < offsetEnd {
-			// Get next 2 bytes
-			if bs, err = i.NextBytesNoCopy(2); err != nil {
-				err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
-				return
-			}
+		return parseDescriptorsUntil(i, i.Offset()+length)
+	}
+	return
+}
 
-			// Create descriptor
-			d := &Descriptor{
-				Length: uint8(bs[1]),
-				Tag:    uint8(bs[0]),
-			}
+// parseDescriptorsUntil parses descriptors until the offset end is reached
+func parseDescriptorsUntil(i *astikit.BytesIterator, offsetEnd int) (o []*Descriptor, err error) {
+	var bs []byte
+	for i.Offset() < offsetEnd {
+		// Get next 2 bytes
+		if bs, err = i.NextBytesNoCopy(2); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
 
-			// Parse data
-			if d.Length > 0 {
-				// Unfortunately there's no way to be sure the real descriptor length is the same as the one indicated
-				// previously therefore we must fetch bytes in descriptor functions and seek at the end
-				offsetDescriptorEnd := i.Offset() + int(d.Length)
-
-				// User defined
-				if d.Tag >= 0x80 && d.Tag <= 0xfe {
-					// Get next bytes
-					if d.UserDefined, err = i.NextBytes(int(d.Length)); err != nil {
-						err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		// Create descriptor
+		d := &Descriptor{
+			Length: uint8(bs[1]),
+			Tag:    uint8(bs[0]),
+		}
+
+		// Parse data
+		if d.Length > 0 {
+			// Unfortunately there's no way to be sure the real descriptor length is the same as the one indicated
+			// previously therefore we must fetch bytes in descriptor functions and seek at the end
+			offsetDescriptorEnd := i.Offset() + int(d.Length)
+
+			// User defined
+			if d.Tag >= 0x80 && d.Tag <= 0xfe {
+				// Get next bytes
+				if d.UserDefined, err = i.NextBytes(int(d.Length)); err != nil {
+					err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+					return
+				}
+			} else {
+				// Switch on tag
+				switch d.Tag {
+				case DescriptorTagAC3:
+					if d.AC3, err = newDescriptorAC3(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing AC3 descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagAVCVideo:
+					if d.AVCVideo, err = newDescriptorAVCVideo(i); err != nil {
+						err = fmt.Errorf("astits: parsing AVC Video descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagComponent:
+					if d.Component, err = newDescriptorComponent(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Component descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagContent:
+					if d.Content, err = newDescriptorContent(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Content descriptor failed: %w", err)
 						return
 					}
-				} else {
-					// Switch on tag
-					switch d.Tag {
-					case DescriptorTagAC3:
-						if d.AC3, err = newDescriptorAC3(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing AC3 descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagAVCVideo:
-						if d.AVCVideo, err = newDescriptorAVCVideo(i); err != nil {
-							err = fmt.Errorf("astits: parsing AVC Video descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagComponent:
-						if d.Component, err = newDescriptorComponent(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing Component descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagContent:
-						if d.Content, err = newDescriptorContent(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing Content descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagDataStreamAlignment:
-						if d.DataStreamAlignment, err = newDescriptorDataStreamAlignment(i); err != nil {
-							err = fmt.Errorf("astits: parsing Data Stream Alignment descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagEnhancedAC3:
-						if d.EnhancedAC3, err = newDescriptorEnhancedAC3(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing Enhanced AC3 descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagExtendedEvent:
-						if d.ExtendedEvent, err = newDescriptorExtendedEvent(i); err != nil {
-							err = fmt.Errorf("astits: parsing Extended event descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagExtension:
-						if d.Extension, err = newDescriptorExtension(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing Extension descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagISO639LanguageAndAudioType:
-						if d.ISO639LanguageAndAudioType, err = newDescriptorISO639LanguageAndAudioType(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing ISO639 Language and Audio Type descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagLocalTimeOffset:
-						if d.LocalTimeOffset, err = newDescriptorLocalTimeOffset(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing Local Time Offset descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagMaximumBitrate:
-						if d.MaximumBitrate, err = newDescriptorMaximumBitrate(i); err != nil {
-							err = fmt.Errorf("astits: parsing Maximum Bitrate descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagNetworkName:
-						if d.NetworkName, err = newDescriptorNetworkName(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing Network Name descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagParentalRating:
-						if d.ParentalRating, err = newDescriptorParentalRating(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing Parental Rating descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagPrivateDataIndicator:
-						if d.PrivateDataIndicator, err = newDescriptorPrivateDataIndicator(i); err != nil {
-							err = fmt.Errorf("astits: parsing Private Data Indicator descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagPrivateDataSpecifier:
-						if d.PrivateDataSpecifier, err = newDescriptorPrivateDataSpecifier(i); err != nil {
-							err = fmt.Errorf("astits: parsing Private Data Specifier descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagRegistration:
-						if d.Registration, err = newDescriptorRegistration(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing Registration descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagService:
-						if d.Service, err = newDescriptorService(i); err != nil {
-							err = fmt.Errorf("astits: parsing Service descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagShortEvent:
-						if d.ShortEvent, err = newDescriptorShortEvent(i); err != nil {
-							err = fmt.Errorf("astits: parsing Short Event descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagStreamIdentifier:
-						if d.StreamIdentifier, err = newDescriptorStreamIdentifier(i); err != nil {
-							err = fmt.Errorf("astits: parsing Stream Identifier descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagSubtitling:
-						if d.Subtitling, err = newDescriptorSubtitling(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing Subtitling descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagTeletext:
-						if d.Teletext, err = newDescriptorTeletext(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing Teletext descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagVBIData:
-						if d.VBIData, err = newDescriptorVBIData(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing VBI Date descriptor failed: %w", err)
-							return
-						}
-					case DescriptorTagVBITeletext:
-						if d.VBITeletext, err = newDescriptorTeletext(i, offsetDescriptorEnd); err != nil {
-							err = fmt.Errorf("astits: parsing VBI Teletext descriptor failed: %w", err)
-							return
-						}
-					default:
-						if d.Unknown, err = newDescriptorUnknown(i, d.Tag, d.Length); err != nil {
-							err = fmt.Errorf("astits: parsing unknown descriptor failed: %w", err)
-							return
-						}
+				case DescriptorTagDataStreamAlignment:
+					if d.DataStreamAlignment, err = newDescriptorDataStreamAlignment(i); err != nil {
+						err = fmt.Errorf("astits: parsing Data Stream Alignment descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagEnhancedAC3:
+					if d.EnhancedAC3, err = newDescriptorEnhancedAC3(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Enhanced AC3 descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagExtendedEvent:
+					if d.ExtendedEvent, err = newDescriptorExtendedEvent(i); err != nil {
+						err = fmt.Errorf("astits: parsing Extended event descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagExtension:
+					if d.Extension, err = newDescriptorExtension(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Extension descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagFTAContentManagement:
+					if d.FTAContentManagement, err = newDescriptorFTAContentManagement(i); err != nil {
+						err = fmt.Errorf("astits: parsing FTA content management descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagISO639LanguageAndAudioType:
+					if d.ISO639LanguageAndAudioType, err = newDescriptorISO639LanguageAndAudioType(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing ISO639 Language and Audio Type descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagLocalTimeOffset:
+					if d.LocalTimeOffset, err = newDescriptorLocalTimeOffset(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Local Time Offset descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagMaximumBitrate:
+					if d.MaximumBitrate, err = newDescriptorMaximumBitrate(i); err != nil {
+						err = fmt.Errorf("astits: parsing Maximum Bitrate descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagMVCExtension:
+					if d.MVCExtension, err = newDescriptorMVCExtension(i); err != nil {
+						err = fmt.Errorf("astits: parsing MVC Extension descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagMVCOperationPoint:
+					if d.MVCOperationPoint, err = newDescriptorMVCOperationPoint(i); err != nil {
+						err = fmt.Errorf("astits: parsing MVC Operation Point descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagMosaic:
+					if d.Mosaic, err = newDescriptorMosaic(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Mosaic descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagNetworkName:
+					if d.NetworkName, err = newDescriptorNetworkName(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Network Name descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagParentalRating:
+					if d.ParentalRating, err = newDescriptorParentalRating(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Parental Rating descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagPrivateDataIndicator:
+					if d.PrivateDataIndicator, err = newDescriptorPrivateDataIndicator(i); err != nil {
+						err = fmt.Errorf("astits: parsing Private Data Indicator descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagPrivateDataSpecifier:
+					if d.PrivateDataSpecifier, err = newDescriptorPrivateDataSpecifier(i); err != nil {
+						err = fmt.Errorf("astits: parsing Private Data Specifier descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagRegistration:
+					if d.Registration, err = newDescriptorRegistration(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Registration descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagService:
+					if d.Service, err = newDescriptorService(i); err != nil {
+						err = fmt.Errorf("astits: parsing Service descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagServiceAvailability:
+					if d.ServiceAvailability, err = newDescriptorServiceAvailability(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Service Availability descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagServiceMove:
+					if d.ServiceMove, err = newDescriptorServiceMove(i); err != nil {
+						err = fmt.Errorf("astits: parsing Service Move descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagShortEvent:
+					if d.ShortEvent, err = newDescriptorShortEvent(i); err != nil {
+						err = fmt.Errorf("astits: parsing Short Event descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagStreamIdentifier:
+					if d.StreamIdentifier, err = newDescriptorStreamIdentifier(i); err != nil {
+						err = fmt.Errorf("astits: parsing Stream Identifier descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagSubtitling:
+					if d.Subtitling, err = newDescriptorSubtitling(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Subtitling descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagTeletext:
+					if d.Teletext, err = newDescriptorTeletext(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing Teletext descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagVBIData:
+					if d.VBIData, err = newDescriptorVBIData(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing VBI Date descriptor failed: %w", err)
+						return
+					}
+				case DescriptorTagVBITeletext:
+					if d.VBITeletext, err = newDescriptorTeletext(i, offsetDescriptorEnd); err != nil {
+						err = fmt.Errorf("astits: parsing VBI Teletext descriptor failed: %w", err)
+						return
+					}
+				default:
+					if d.Unknown, err = newDescriptorUnknown(i, d.Tag, d.Length); err != nil {
+						err = fmt.Errorf("astits: parsing unknown descriptor failed: %w", err)
+						return
 					}
 				}
-
-				// Seek in iterator to make sure we move to the end of the descriptor since its content may be
-				// corrupted
-				i.Seek(offsetDescriptorEnd)
 			}
-			o = append(o, d)
+
+			// Seek in iterator to make sure we move to the end of the descriptor since its content may be
+			// corrupted
+			i.Seek(offsetDescriptorEnd)
 		}
+		o = append(o, d)
 	}
 	return
 }
@@ -1698,6 +2118,8 @@ func calcDescriptorExtensionLength(d *DescriptorExtension) uint8 {
 	ret := 1 // tag
 
 	switch d.Tag {
+	case DescriptorTagExtensionOpus:
+		ret++
 	case DescriptorTagExtensionSupplementaryAudio:
 		ret += calcDescriptorExtensionSupplementaryAudioLength(d.SupplementaryAudio)
 	default:
@@ -1732,6 +2154,8 @@ func writeDescriptorExtension(w *astikit.BitsWriter, d *DescriptorExtension) err
 	b.Write(d.Tag)
 
 	switch d.Tag {
+	case DescriptorTagExtensionOpus:
+		b.Write(d.Opus.ChannelConfigCode)
 	case DescriptorTagExtensionSupplementaryAudio:
 		err := writeDescriptorExtensionSupplementaryAudio(w, d.SupplementaryAudio)
 		if err != nil {
@@ -1746,6 +2170,22 @@ func writeDescriptorExtension(w *astikit.BitsWriter, d *DescriptorExtension) err
 	return b.Err()
 }
 
+func calcDescriptorFTAContentManagementLength(d *DescriptorFTAContentManagement) uint8 {
+	return 1
+}
+
+func writeDescriptorFTAContentManagement(w *astikit.BitsWriter, d *DescriptorFTAContentManagement) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(d.UserDefined)
+	b.WriteN(uint8(0xff), 3) // reserved
+	b.Write(d.DoNotScramble)
+	b.WriteN(d.ControlRemoteAccessOverInternet, 2)
+	b.Write(d.DoNotApplyRevocation)
+
+	return b.Err()
+}
+
 func calcDescriptorISO639LanguageAndAudioTypeLength(d *DescriptorISO639LanguageAndAudioType) uint8 {
 	return 3 + 1 // language code + type
 }
@@ -1800,6 +2240,118 @@ func writeDescriptorMaximumBitrate(w *astikit.BitsWriter, d *DescriptorMaximumBi
 	return b.Err()
 }
 
+func calcDescriptorMVCExtensionLength(d *DescriptorMVCExtension) uint8 {
+	return 8
+}
+
+func writeDescriptorMVCExtension(w *astikit.BitsWriter, d *DescriptorMVCExtension) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(d.AverageBitrate)
+	b.Write(d.MaximumBitrate)
+	b.Write(d.ViewAssociationNotPresent)
+	b.Write(d.BaseViewIsLeftEyeview)
+	b.WriteN(uint8(0xff), 2)
+	b.WriteN(d.ViewOrderIndexMin, 10)
+	b.WriteN(d.ViewOrderIndexMax, 10)
+	b.WriteN(d.TemporalIDStart, 3)
+	b.WriteN(d.TemporalIDEnd, 3)
+	b.Write(d.NoSEINALUnitPresent)
+	b.Write(d.NoPrefixNALUnitPresent)
+
+	return b.Err()
+}
+
+func calcDescriptorMVCOperationPointLength(d *DescriptorMVCOperationPoint) uint8 {
+	ret := 3 // profile idc, flags and level count
+	for _, l := range d.Levels {
+		ret += 2 // level idc and operation points count
+		for _, op := range l.OperationPoints {
+			ret += 3 + len(op.ESReferences)
+		}
+	}
+	return uint8(ret)
+}
+
+func writeDescriptorMVCOperationPoint(w *astikit.BitsWriter, d *DescriptorMVCOperationPoint) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(d.ProfileIDC)
+	b.WriteN(d.ConstraintSetFlags, 6)
+	b.WriteN(d.AVCCompatibleFlags, 2)
+	b.Write(uint8(len(d.Levels)))
+
+	for _, l := range d.Levels {
+		b.Write(l.LevelIDC)
+		b.Write(uint8(len(l.OperationPoints)))
+
+		for _, op := range l.OperationPoints {
+			b.WriteN(uint8(0xff), 5)
+			b.WriteN(op.ApplicableTemporalID, 3)
+			b.Write(op.NumTargetOutputViews)
+			b.Write(uint8(len(op.ESReferences)))
+
+			for _, ref := range op.ESReferences {
+				b.WriteN(uint8(0xff), 2)
+				b.WriteN(ref, 6)
+			}
+		}
+	}
+
+	return b.Err()
+}
+
+func calcDescriptorMosaicLength(d *DescriptorMosaic) uint8 {
+	ret := 1 // entry point and number of cells
+	for _, c := range d.LogicalCells {
+		ret += 4 + len(c.ElementaryCellIDs) // ids, presentation info, field length and linkage info
+		switch c.CellLinkageInfo {
+		case MosaicCellLinkageInfoBouquet:
+			ret += 2
+		case MosaicCellLinkageInfoService, MosaicCellLinkageInfoOtherMosaic:
+			ret += 6
+		case MosaicCellLinkageInfoEvent:
+			ret += 8
+		}
+	}
+	return uint8(ret)
+}
+
+func writeDescriptorMosaic(w *astikit.BitsWriter, d *DescriptorMosaic) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(d.EntryPoint)
+	b.WriteN(d.NumberOfHorizontalElementaryCells, 3)
+	b.Write(true) // reserved_future_use
+	b.WriteN(d.NumberOfVerticalElementaryCells, 3)
+
+	for _, c := range d.LogicalCells {
+		b.WriteN(c.LogicalCellID, 6)
+		b.WriteN(uint8(0xff), 7)
+		b.WriteN(c.LogicalCellPresentationInfo, 3)
+		b.Write(uint8(len(c.ElementaryCellIDs)))
+		for _, id := range c.ElementaryCellIDs {
+			b.WriteN(uint8(0xff), 2)
+			b.WriteN(id, 6)
+		}
+		b.Write(c.CellLinkageInfo)
+
+		switch c.CellLinkageInfo {
+		case MosaicCellLinkageInfoBouquet:
+			b.Write(c.BouquetID)
+		case MosaicCellLinkageInfoService, MosaicCellLinkageInfoOtherMosaic, MosaicCellLinkageInfoEvent:
+			b.Write(c.OriginalNetworkID)
+			b.Write(c.TransportStreamID)
+			b.Write(c.ServiceID)
+			if c.CellLinkageInfo == MosaicCellLinkageInfoEvent {
+				b.Write(c.EventID)
+			}
+		}
+	}
+
+	return b.Err()
+}
+
 func calcDescriptorNetworkNameLength(d *DescriptorNetworkName) uint8 {
 	return uint8(len(d.Name))
 }
@@ -1883,6 +2435,36 @@ func writeDescriptorService(w *astikit.BitsWriter, d *DescriptorService) error {
 	return b.Err()
 }
 
+func calcDescriptorServiceAvailabilityLength(d *DescriptorServiceAvailability) uint8 {
+	return uint8(1 + 2*len(d.CellIDs))
+}
+
+func writeDescriptorServiceAvailability(w *astikit.BitsWriter, d *DescriptorServiceAvailability) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(d.AvailabilityFlag)
+	b.WriteN(uint8(0xff), 7)
+	for _, id := range d.CellIDs {
+		b.Write(id)
+	}
+
+	return b.Err()
+}
+
+func calcDescriptorServiceMoveLength(d *DescriptorServiceMove) uint8 {
+	return 6
+}
+
+func writeDescriptorServiceMove(w *astikit.BitsWriter, d *DescriptorServiceMove) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(d.NewOriginalNetworkID)
+	b.Write(d.NewTransportStreamID)
+	b.Write(d.NewServiceID)
+
+	return b.Err()
+}
+
 func calcDescriptorShortEventLength(d *DescriptorShortEvent) uint8 {
 	ret := 3 + 1 + 1 // language code and lengths
 	ret += len(d.EventName)
@@ -2019,12 +2601,20 @@ func calcDescriptorLength(d *Descriptor) uint8 {
 		return ret
 	case DescriptorTagExtension:
 		return calcDescriptorExtensionLength(d.Extension)
+	case DescriptorTagFTAContentManagement:
+		return calcDescriptorFTAContentManagementLength(d.FTAContentManagement)
 	case DescriptorTagISO639LanguageAndAudioType:
 		return calcDescriptorISO639LanguageAndAudioTypeLength(d.ISO639LanguageAndAudioType)
 	case DescriptorTagLocalTimeOffset:
 		return calcDescriptorLocalTimeOffsetLength(d.LocalTimeOffset)
 	case DescriptorTagMaximumBitrate:
 		return calcDescriptorMaximumBitrateLength(d.MaximumBitrate)
+	case DescriptorTagMVCExtension:
+		return calcDescriptorMVCExtensionLength(d.MVCExtension)
+	case DescriptorTagMVCOperationPoint:
+		return calcDescriptorMVCOperationPointLength(d.MVCOperationPoint)
+	case DescriptorTagMosaic:
+		return calcDescriptorMosaicLength(d.Mosaic)
 	case DescriptorTagNetworkName:
 		return calcDescriptorNetworkNameLength(d.NetworkName)
 	case DescriptorTagParentalRating:
@@ -2037,6 +2627,10 @@ func calcDescriptorLength(d *Descriptor) uint8 {
 		return calcDescriptorRegistrationLength(d.Registration)
 	case DescriptorTagService:
 		return calcDescriptorServiceLength(d.Service)
+	case DescriptorTagServiceAvailability:
+		return calcDescriptorServiceAvailabilityLength(d.ServiceAvailability)
+	case DescriptorTagServiceMove:
+		return calcDescriptorServiceMoveLength(d.ServiceMove)
 	case DescriptorTagShortEvent:
 		return calcDescriptorShortEventLength(d.ShortEvent)
 	case DescriptorTagStreamIdentifier:
@@ -2088,12 +2682,20 @@ func writeDescriptor(w *astikit.BitsWriter, d *Descriptor) (int, error) {
 		return written, writeDescriptorExtendedEvent(w, d.ExtendedEvent)
 	case DescriptorTagExtension:
 		return written, writeDescriptorExtension(w, d.Extension)
+	case DescriptorTagFTAContentManagement:
+		return written, writeDescriptorFTAContentManagement(w, d.FTAContentManagement)
 	case DescriptorTagISO639LanguageAndAudioType:
 		return written, writeDescriptorISO639LanguageAndAudioType(w, d.ISO639LanguageAndAudioType)
 	case DescriptorTagLocalTimeOffset:
 		return written, writeDescriptorLocalTimeOffset(w, d.LocalTimeOffset)
 	case DescriptorTagMaximumBitrate:
 		return written, writeDescriptorMaximumBitrate(w, d.MaximumBitrate)
+	case DescriptorTagMVCExtension:
+		return written, writeDescriptorMVCExtension(w, d.MVCExtension)
+	case DescriptorTagMVCOperationPoint:
+		return written, writeDescriptorMVCOperationPoint(w, d.MVCOperationPoint)
+	case DescriptorTagMosaic:
+		return written, writeDescriptorMosaic(w, d.Mosaic)
 	case DescriptorTagNetworkName:
 		return written, writeDescriptorNetworkName(w, d.NetworkName)
 	case DescriptorTagParentalRating:
@@ -2106,6 +2708,10 @@ func writeDescriptor(w *astikit.BitsWriter, d *Descriptor) (int, error) {
 		return written, writeDescriptorRegistration(w, d.Registration)
 	case DescriptorTagService:
 		return written, writeDescriptorService(w, d.Service)
+	case DescriptorTagServiceAvailability:
+		return written, writeDescriptorServiceAvailability(w, d.ServiceAvailability)
+	case DescriptorTagServiceMove:
+		return written, writeDescriptorServiceMove(w, d.ServiceMove)
 	case DescriptorTagShortEvent:
 		return written, writeDescriptorShortEvent(w, d.ShortEvent)
 	case DescriptorTagStreamIdentifier:
@@ -2147,11 +2753,17 @@ func writeDescriptors(w *astikit.BitsWriter, ds []*Descriptor) (int, error) {
 }
 
 func writeDescriptorsWithLength(w *astikit.BitsWriter, ds []*Descriptor) (int, error) {
+	return writeDescriptorsWithReservedAndLength(w, ds, 0xff)
+}
+
+// writeDescriptorsWithReservedAndLength writes descriptors prefixed with their length, whose reserved bits are the
+// lowest bits of reserved
+func writeDescriptorsWithReservedAndLength(w *astikit.BitsWriter, ds []*Descriptor, reserved uint8) (int, error) {
 	length := calcDescriptorsLength(ds)
 	b := astikit.NewBitsWriterBatch(w)
 
-	b.WriteN(uint8(0xff), 4) // reserved
-	b.WriteN(length, 12)     // program_info_length
+	b.WriteN(reserved, 4)
+	b.WriteN(length, 12) // program_info_length
 
 	if err := b.Err(); err != nil {
 		return 0, err
@@ -316,6 +316,22 @@ var descriptorTestTable = []descriptorTest{
 				Unknown: nil,
 			}},
 	},
+	{
+		"ExtensionOpus",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(DescriptorTagExtension))     // Tag
+			w.Write(uint8(2))                          // Length
+			w.Write(uint8(DescriptorTagExtensionOpus)) // Extension tag
+			w.Write(uint8(2))                          // Channel config code
+		},
+		Descriptor{
+			Tag:    DescriptorTagExtension,
+			Length: 2,
+			Extension: &DescriptorExtension{
+				Opus: &DescriptorExtensionOpus{ChannelConfigCode: 2},
+				Tag:  DescriptorTagExtensionOpus,
+			}},
+	},
 	{
 		"Component",
 		func(w *astikit.BitsWriter) {
@@ -470,6 +486,190 @@ var descriptorTestTable = []descriptorTest{
 				ProfileIDC:           1,
 			}},
 	},
+	{
+		"MVCExtension",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(DescriptorTagMVCExtension)) // Tag
+			w.Write(uint8(8))                         // Length
+			w.Write(uint16(1000))                     // Average bitrate
+			w.Write(uint16(2000))                     // Maximum bitrate
+			w.Write("0")                              // View association not present
+			w.Write("1")                              // Base view is left eyeview
+			w.Write("11")                             // Reserved
+			w.Write("0000000001")                     // View order index min
+			w.Write("1000000010")                     // View order index max
+			w.Write("001")                            // Temporal id start
+			w.Write("110")                            // Temporal id end
+			w.Write("1")                              // No SEI NAL unit present
+			w.Write("0")                              // No prefix NAL unit present
+		},
+		Descriptor{
+			Tag:    DescriptorTagMVCExtension,
+			Length: 8,
+			MVCExtension: &DescriptorMVCExtension{
+				AverageBitrate:        1000,
+				BaseViewIsLeftEyeview: true,
+				MaximumBitrate:        2000,
+				NoSEINALUnitPresent:   true,
+				TemporalIDEnd:         6,
+				TemporalIDStart:       1,
+				ViewOrderIndexMax:     514,
+				ViewOrderIndexMin:     1,
+			}},
+	},
+	{
+		"MVCOperationPoint",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(DescriptorTagMVCOperationPoint)) // Tag
+			w.Write(uint8(13))                             // Length
+			w.Write(uint8(128))                            // Profile idc
+			w.Write("101010")                              // Constraint set flags
+			w.Write("01")                                  // AVC compatible flags
+			w.Write(uint8(1))                              // Level count
+			w.Write(uint8(40))                             // Level idc
+			w.Write(uint8(2))                              // Operation points count
+			w.Write("11111")                               // Reserved
+			w.Write("010")                                 // Applicable temporal id
+			w.Write(uint8(2))                              // Num target output views
+			w.Write(uint8(2))                              // ES count
+			w.Write("11")                                  // Reserved
+			w.Write("000000")                              // ES reference
+			w.Write("11")                                  // Reserved
+			w.Write("000001")                              // ES reference
+			w.Write("11111")                               // Reserved
+			w.Write("000")                                 // Applicable temporal id
+			w.Write(uint8(1))                              // Num target output views
+			w.Write(uint8(0))                              // ES count
+		},
+		Descriptor{
+			Tag:    DescriptorTagMVCOperationPoint,
+			Length: 13,
+			MVCOperationPoint: &DescriptorMVCOperationPoint{
+				AVCCompatibleFlags: 1,
+				ConstraintSetFlags: 42,
+				Levels: []*DescriptorMVCOperationPointLevel{{
+					LevelIDC: 40,
+					OperationPoints: []*DescriptorMVCOperationPointItem{
+						{ApplicableTemporalID: 2, ESReferences: []uint8{0, 1}, NumTargetOutputViews: 2},
+						{NumTargetOutputViews: 1},
+					},
+				}},
+				ProfileIDC: 128,
+			}},
+	},
+	{
+		"Mosaic",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(DescriptorTagMosaic)) // Tag
+			w.Write(uint8(33))                  // Length
+			w.Write("1")                        // Entry point
+			w.Write("001")                      // Number of horizontal elementary cells
+			w.Write("1")                        // Reserved
+			w.Write("001")                      // Number of vertical elementary cells
+			w.Write("000000")                   // Cell #1 logical cell id
+			w.Write("1111111")                  // Cell #1 reserved
+			w.Write("001")                      // Cell #1 logical cell presentation info
+			w.Write(uint8(2))                   // Cell #1 elementary cell field length
+			w.Write("11")                       // Cell #1 reserved
+			w.Write("000000")                   // Cell #1 elementary cell id #1
+			w.Write("11")                       // Cell #1 reserved
+			w.Write("000001")                   // Cell #1 elementary cell id #2
+			w.Write(uint8(2))                   // Cell #1 cell linkage info
+			w.Write(uint16(1))                  // Cell #1 original network id
+			w.Write(uint16(2))                  // Cell #1 transport stream id
+			w.Write(uint16(3))                  // Cell #1 service id
+			w.Write("000001")                   // Cell #2 logical cell id
+			w.Write("1111111")                  // Cell #2 reserved
+			w.Write("010")                      // Cell #2 logical cell presentation info
+			w.Write(uint8(1))                   // Cell #2 elementary cell field length
+			w.Write("11")                       // Cell #2 reserved
+			w.Write("000010")                   // Cell #2 elementary cell id
+			w.Write(uint8(4))                   // Cell #2 cell linkage info
+			w.Write(uint16(4))                  // Cell #2 original network id
+			w.Write(uint16(5))                  // Cell #2 transport stream id
+			w.Write(uint16(6))                  // Cell #2 service id
+			w.Write(uint16(7))                  // Cell #2 event id
+			w.Write("000010")                   // Cell #3 logical cell id
+			w.Write("1111111")                  // Cell #3 reserved
+			w.Write("011")                      // Cell #3 logical cell presentation info
+			w.Write(uint8(1))                   // Cell #3 elementary cell field length
+			w.Write("11")                       // Cell #3 reserved
+			w.Write("000011")                   // Cell #3 elementary cell id
+			w.Write(uint8(1))                   // Cell #3 cell linkage info
+			w.Write(uint16(8))                  // Cell #3 bouquet id
+		},
+		Descriptor{
+			Tag:    DescriptorTagMosaic,
+			Length: 33,
+			Mosaic: &DescriptorMosaic{
+				EntryPoint: true,
+				LogicalCells: []*DescriptorMosaicLogicalCell{
+					{
+						CellLinkageInfo:             MosaicCellLinkageInfoService,
+						ElementaryCellIDs:           []uint8{0, 1},
+						LogicalCellPresentationInfo: MosaicLogicalCellPresentationInfoVideo,
+						OriginalNetworkID:           1,
+						ServiceID:                   3,
+						TransportStreamID:           2,
+					},
+					{
+						CellLinkageInfo:             MosaicCellLinkageInfoEvent,
+						ElementaryCellIDs:           []uint8{2},
+						EventID:                     7,
+						LogicalCellID:               1,
+						LogicalCellPresentationInfo: MosaicLogicalCellPresentationInfoStillPicture,
+						OriginalNetworkID:           4,
+						ServiceID:                   6,
+						TransportStreamID:           5,
+					},
+					{
+						BouquetID:                   8,
+						CellLinkageInfo:             MosaicCellLinkageInfoBouquet,
+						ElementaryCellIDs:           []uint8{3},
+						LogicalCellID:               2,
+						LogicalCellPresentationInfo: MosaicLogicalCellPresentationInfoGraphicsText,
+					},
+				},
+				NumberOfHorizontalElementaryCells: 1,
+				NumberOfVerticalElementaryCells:   1,
+			}},
+	},
+	{
+		"ServiceAvailability",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(DescriptorTagServiceAvailability)) // Tag
+			w.Write(uint8(5))                                // Length
+			w.Write("1")                                     // Availability flag
+			w.Write("1111111")                               // Reserved
+			w.Write(uint16(1))                               // Cell id #1
+			w.Write(uint16(2))                               // Cell id #2
+		},
+		Descriptor{
+			Tag:    DescriptorTagServiceAvailability,
+			Length: 5,
+			ServiceAvailability: &DescriptorServiceAvailability{
+				AvailabilityFlag: true,
+				CellIDs:          []uint16{1, 2},
+			}},
+	},
+	{
+		"ServiceMove",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(DescriptorTagServiceMove)) // Tag
+			w.Write(uint8(6))                        // Length
+			w.Write(uint16(1))                       // New original network id
+			w.Write(uint16(2))                       // New transport stream id
+			w.Write(uint16(3))                       // New service id
+		},
+		Descriptor{
+			Tag:    DescriptorTagServiceMove,
+			Length: 6,
+			ServiceMove: &DescriptorServiceMove{
+				NewOriginalNetworkID: 1,
+				NewServiceID:         3,
+				NewTransportStreamID: 2,
+			}},
+	},
 	{
 		"PrivateDataSpecifier",
 		func(w *astikit.BitsWriter) {
@@ -498,6 +698,25 @@ var descriptorTestTable = []descriptorTest{
 				Type: 2,
 			}},
 	},
+	{
+		"FTAContentManagement",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(DescriptorTagFTAContentManagement)) // Tag
+			w.Write(uint8(1))                                 // Length
+			w.Write("0")                                      // User defined
+			w.Write("111")                                    // Reserved
+			w.Write("1")                                      // Do not scramble
+			w.Write("01")                                     // Control remote access over internet
+			w.Write("0")                                      // Do not apply revocation
+		},
+		Descriptor{
+			Tag:    DescriptorTagFTAContentManagement,
+			Length: 1,
+			FTAContentManagement: &DescriptorFTAContentManagement{
+				ControlRemoteAccessOverInternet: 1,
+				DoNotScramble:                   true,
+			}},
+	},
 	{
 		"PrivateDataIndicator",
 		func(w *astikit.BitsWriter) {
@@ -540,6 +759,19 @@ var descriptorTestTable = []descriptorTest{
 				FormatIdentifier:             uint32(1),
 			}},
 	},
+	{
+		"RegistrationOpus",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(DescriptorTagRegistration)) // Tag
+			w.Write(uint8(4))                         // Length
+			w.Write([]byte("Opus"))                   // Format identifier
+		},
+		Descriptor{
+			Tag:          DescriptorTagRegistration,
+			Length:       4,
+			Registration: &DescriptorRegistration{FormatIdentifier: RegistrationFormatIdentifierOpus},
+		},
+	},
 	{
 		"Unknown",
 		func(w *astikit.BitsWriter) {
@@ -618,6 +850,12 @@ func TestParseDescriptorAll(t *testing.T) {
 	}
 }
 
+func TestDescriptorTeletextItemPageNumber(t *testing.T) {
+	assert.Equal(t, uint16(100), (&DescriptorTeletextItem{Magazine: 1}).PageNumber())
+	assert.Equal(t, uint16(212), (&DescriptorTeletextItem{Magazine: 2, Page: 12}).PageNumber())
+	assert.Equal(t, uint16(888), (&DescriptorTeletextItem{Page: 88}).PageNumber())
+}
+
 func TestWriteDescriptorOneByOne(t *testing.T) {
 	for _, tc := range descriptorTestTable {
 		t.Run(tc.name, func(t *testing.T) {
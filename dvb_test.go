@@ -22,6 +22,15 @@ func TestParseDVBTime(t *testing.T) {
 	d, err := parseDVBTime(astikit.NewBytesIterator(dvbTimeBytes))
 	assert.Equal(t, dvbTime, d)
 	assert.NoError(t, err)
+
+	// Years after 1999 and single digit months and days
+	e := time.Date(2020, time.March, 1, 12, 45, 0, 0, time.UTC)
+	buf := &bytes.Buffer{}
+	_, err = writeDVBTime(astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf}), e)
+	assert.NoError(t, err)
+	d, err = parseDVBTime(astikit.NewBytesIterator(buf.Bytes()))
+	assert.Equal(t, e, d)
+	assert.NoError(t, err)
 }
 
 func TestParseDVBDurationMinutes(t *testing.T) {
@@ -0,0 +1,71 @@
+package astits
+
+import "sort"
+
+// Inconsistency types
+const (
+	InconsistencyTypeMissingElementaryStream = "MissingElementaryStream" // A PMT references an elementary PID that never appeared in the stream
+	InconsistencyTypeMissingPCR              = "MissingPCR"              // A PMT references a PCR PID that never appeared in the stream
+	InconsistencyTypeMissingPMT              = "MissingPMT"              // The PAT references a PMT PID for which no PMT has been parsed
+)
+
+// Inconsistency represents a dangling reference found in the PAT or a PMT
+type Inconsistency struct {
+	PID           uint16 // The PID being referenced
+	ProgramNumber uint16
+	Type          string
+}
+
+// Inconsistencies returns the dangling references found in the tables parsed so far
+// It's only relevant once enough of the stream has been parsed, since PIDs and tables may just not have
+// been reached yet
+func (dmx *Demuxer) Inconsistencies() (is []*Inconsistency) {
+	// Loop through PAT programs
+	dmx.programMap.m.Lock()
+	pmtPIDs := make([]uint16, 0, len(dmx.programMap.p))
+	programNumbers := make(map[uint16]uint16, len(dmx.programMap.p))
+	for pid, number := range dmx.programMap.p {
+		pmtPIDs = append(pmtPIDs, pid)
+		programNumbers[pid] = number
+	}
+	dmx.programMap.m.Unlock()
+	sort.Slice(pmtPIDs, func(i, j int) bool { return pmtPIDs[i] < pmtPIDs[j] })
+
+	for _, pid := range pmtPIDs {
+		// PMT is missing
+		pmt, ok := dmx.pmts[pid]
+		if !ok {
+			is = append(is, &Inconsistency{
+				PID:           pid,
+				ProgramNumber: programNumbers[pid],
+				Type:          InconsistencyTypeMissingPMT,
+			})
+			continue
+		}
+
+		// Loop through elementary streams
+		hasPCRPID := false
+		for _, es := range pmt.ElementaryStreams {
+			if es.ElementaryPID == pmt.PCRPID {
+				hasPCRPID = true
+			}
+			if !dmx.pids[es.ElementaryPID] {
+				is = append(is, &Inconsistency{
+					PID:           es.ElementaryPID,
+					ProgramNumber: pmt.ProgramNumber,
+					Type:          InconsistencyTypeMissingElementaryStream,
+				})
+			}
+		}
+
+		// PCR PID 0x1fff means the program has no PCR
+		if !hasPCRPID && pmt.PCRPID != PIDNull && !dmx.pids[pmt.PCRPID] {
+			is = append(is, &Inconsistency{
+				PID:           pmt.PCRPID,
+				ProgramNumber: pmt.ProgramNumber,
+				Type:          InconsistencyTypeMissingPCR,
+			})
+		}
+	}
+	return
+}
@@ -0,0 +1,60 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDemuxer_Inconsistencies(t *testing.T) {
+	buf := &bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0101,
+		StreamType:    StreamTypeAACAudio,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// Second program's PMT is never written
+	p, err := muxer.AddProgram(2)
+	assert.NoError(t, err)
+
+	err = muxer.generatePAT()
+	assert.NoError(t, err)
+	err = muxer.defaultProgram.generatePMT()
+	assert.NoError(t, err)
+	buf.Write(muxer.patBytes.Bytes())
+	buf.Write(muxer.defaultProgram.pmtBytes.Bytes())
+
+	// Only the video stream is written
+	_, err = muxer.WritePacket(&Packet{
+		Header: &PacketHeader{
+			HasPayload:                true,
+			PayloadUnitStartIndicator: true,
+			PID:                       0x0100,
+		},
+		Payload: []byte{0x0, 0x0, 0x1, 0xbf, 0x0, 0x4, 0x74, 0x65, 0x73, 0x74},
+	})
+	assert.NoError(t, err)
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		if _, err = dmx.NextData(); err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []*Inconsistency{
+		{PID: 0x0101, ProgramNumber: 1, Type: InconsistencyTypeMissingElementaryStream},
+		{PID: p.PMTPID(), ProgramNumber: 2, Type: InconsistencyTypeMissingPMT},
+	}, dmx.Inconsistencies())
+}
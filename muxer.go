@@ -4,419 +4,2416 @@ import (
 	"bytes"
 	"context"
 	"errors"
-	"github.com/asticode/go-astikit"
+	"fmt"
 	"io"
+	"time"
+
+	"github.com/asticode/go-astikit"
 )
 
 const (
-	startPID           uint16 = 0x0100
-	pmtStartPID        uint16 = 0x1000
-	programNumberStart uint16 = 1
+	// PIDs below are reserved to MPEG-TS and DVB tables, PIDs above to ATSC tables and null packets
+	reservedPIDsEnd   uint16 = 0x001f
+	reservedPIDsStart uint16 = 0x1ffb
+
+	m2tsHeaderSize                  = 4
+	m2tsArrivalTimestampMask        = 0x3fffffff // arrival_time_stamp is 30 bits
+	startPID                 uint16 = 0x0100
+	pmtStartPID              uint16 = 0x1000
+	programNumberStart       uint16 = 1
 )
 
 var (
-	ErrPIDNotFound      = errors.New("astits: PID not found")
-	ErrPIDAlreadyExists = errors.New("astits: PID already exists")
-	ErrPCRPIDInvalid    = errors.New("astits: PCR PID invalid")
+	ErrPIDNotFound                = errors.New("astits: PID not found")
+	ErrLabelAlreadyExists         = errors.New("astits: label already exists")
+	ErrLabelNotFound              = errors.New("astits: label not found")
+	ErrContinuityCounterInvalid   = errors.New("astits: continuity counter invalid")
+	ErrScramblingControlInvalid   = errors.New("astits: scrambling control invalid")
+	ErrSpliceCountdownInvalid     = errors.New("astits: splice countdown invalid")
+	ErrPIDAlreadyExists           = errors.New("astits: PID already exists")
+	ErrPCRPIDInvalid              = errors.New("astits: PCR PID invalid")
+	ErrProgramNumberAlreadyExists = errors.New("astits: program number already exists")
+	ErrProgramNumberInvalid       = errors.New("astits: program number invalid")
+	ErrProgramNumberNotFound      = errors.New("astits: program number not found")
+	ErrPSISectionTooLong          = errors.New("astits: PSI section too long")
+	ErrMuxerClosed                = errors.New("astits: muxer closed")
+	ErrPTSBehindPCR               = errors.New("astits: PTS behind PCR")
+	ErrPendingDataDropped         = errors.New("astits: pending data dropped")
+	ErrPacketSizeInvalid          = errors.New("astits: packet size invalid")
 )
 
+// packetStuffing holds the 0xff bytes packets are stuffed with
+var packetStuffing = bytes.Repeat([]byte{0xff}, MpegTsPacketSize)
+
 type Muxer struct {
 	ctx        context.Context
 	w          io.Writer
 	bitsWriter *astikit.BitsWriter
 
+	optErr                 error // error of an invalid option, reported by WriteData and Validate
 	packetSize             int
-	tablesRetransmitPeriod int // period in PES packets
-
-	pm         programMap // pid -> programNumber
-	pmt        PMTData
-	nextPID    uint16
-	patVersion wrappingCounter
-	pmtVersion wrappingCounter
+	tablesRetransmitPeriod int           // period in PES packets
+	arrivalTimeFunc        func() uint64 // 27MHz clock used for M2TS arrival timestamps
+	lastPCR                *ClockReference
+	lastArrivalTimestamp   uint32
+	hasArrivalTimestamp    bool
+
+	bitrate      int64 // bits per second, 0 means the stream is not CBR
+	pcrPeriod    int64 // 27MHz
+	pesPCRPeriod int64 // 27MHz
+	clockStart   int64 // 27MHz
+	clockStarted bool
+	lastPCRs     map[uint16]int64 // pcr pid -> 27MHz clock of the last PCR
+	clockFunc    func() uint64
+	nullPacket   []byte
+
+	pm              programMap // pid -> programNumber
+	programs        []*MuxerProgram
+	defaultProgram  *MuxerProgram
+	nextPID         uint16
+	nextPMTPID      uint16
+	pmtReservedBits *ReservedBits
+	patVersion      wrappingCounter
+	patPID          uint16 // PIDPAT unless overridden for testing purposes
 
 	patBytes bytes.Buffer
-	pmtBytes bytes.Buffer
+	patDirty bool // whether patBytes needs to be generated again
+
+	sdtBytes   bytes.Buffer
+	sdtDirty   bool // whether sdtBytes needs to be generated again
+	sdtVersion wrappingCounter
+
+	originalNetworkID    uint16
+	hasOriginalNetworkID bool // whether the original network id overrides the one of the NIT entry of the stream
+
+	nit        *NITData
+	nitBytes   bytes.Buffer
+	nitDirty   bool // whether nitBytes needs to be generated again
+	nitVersion wrappingCounter
+
+	tsdt        *TSDTData
+	tsdtBytes   bytes.Buffer
+	tsdtDirty   bool // whether tsdtBytes needs to be generated again
+	tsdtVersion wrappingCounter
 
 	buf       bytes.Buffer
 	bufWriter *astikit.BitsWriter
 
-	esContexts              map[uint16]*esContext
-	tablesRetransmitCounter int
+	pktBuf       bytes.Buffer
+	pktBufWriter *astikit.BitsWriter
+
+	esContexts         map[uint16]*esContext
+	labels             map[string]uint16 // label -> elementary stream pid
+	patPeriod          tablesPeriod      // PAT, SDT, NIT and TSDT
+	pmtPeriod          tablesPeriod
+	bytesWritten       int64
+	stats              MuxerStats
+	tablesOffset       int64 // bytesWritten when tables were last written by writeTables
+	tablesOnClose      bool
+	spreadTables       bool
+	rejectLatePTS      bool
+	pcrCheck           bool
+	pcrCheckFunc       MuxerPCRBitrateFunc
+	pcrCheckMaxBitrate int64                    // bits per second, 0 means no max
+	pcrCheckPoints     map[uint16]pcrCheckPoint // pcr pid -> last PCR written
+	pcrOnEveryPacket   bool
+	closed             bool
+	forceTablesFunc    MuxerForceTablesFunc
+	payloadEndFunc     MuxerPayloadEndFunc
+	pmtSizeFunc        MuxerPMTSizeFunc
+	pendingData        []*DemuxerData              // PES data waiting for its PID to be declared in a PMT
+	tableCCs           map[uint16]*wrappingCounter // pid -> continuity counter of tables
 }
 
-type esContext struct {
-	es *PMTElementaryStream
-	cc wrappingCounter
+// tablesPeriod decides when a set of tables is retransmitted
+type tablesPeriod struct {
+	packets  int   // period in PES packets, 0 means the muxer's tables retransmit period is used
+	duration int64 // period in 27MHz ticks of the last PCR written, takes precedence over packets when > 0
+	counter  int   // PES packets since the last retransmit
+	last     int64 // 27MHz clock of the last PCR written at the last retransmit
+	hasLast  bool  // whether a PCR had been written at the last retransmit
+	started  bool  // whether tables have been retransmitted at least once
 }
 
-func newEsContext(es *PMTElementaryStream) *esContext {
-	return &esContext{
-		es: es,
-		cc: newWrappingCounter(0b1111), // CC is 4 bits
+// due checks whether tables must be retransmitted. now is the 27MHz clock of the last PCR written, if any
+// Until a PCR is written, time based periods fall back to periods in PES packets
+func (p *tablesPeriod) due(defaultPackets int, now int64, hasNow bool) bool {
+	if !p.started {
+		return true
 	}
+	if p.duration > 0 && hasNow {
+		// Elapsed time is measured from the first PCR written after the last retransmit
+		if !p.hasLast {
+			p.last = now
+			p.hasLast = true
+			return false
+		}
+		// A PCR going backwards means elapsed time is unknown
+		return now < p.last || now-p.last >= p.duration
+	}
+	packets := p.packets
+	if packets <= 0 {
+		packets = defaultPackets
+	}
+	return p.counter >= packets
 }
 
-func MuxerOptTablesRetransmitPeriod(newPeriod int) func(*Muxer) {
-	return func(m *Muxer) {
-		m.tablesRetransmitPeriod = newPeriod
-	}
+func (p *tablesPeriod) reset(now int64, hasNow bool) {
+	p.counter = 0
+	p.last = now
+	p.hasLast = hasNow
+	p.started = true
 }
 
-// TODO MuxerOptAutodetectPCRPID selecting first video PID for each PMT, falling back to first audio, falling back to any other
+// MuxerForceTablesFunc decides whether tables must be written right before the given data, regardless of the
+// retransmit period. isPCRPID indicates whether data is written on the PCR PID of a program.
+type MuxerForceTablesFunc func(d *MuxerData, isPCRPID bool) bool
 
-func NewMuxer(ctx context.Context, w io.Writer, opts ...func(*Muxer)) *Muxer {
-	m := &Muxer{
-		ctx: ctx,
-		w:   w,
+// MuxerForceTablesOnRandomAccess forces tables before data written on a PCR PID with the random access indicator set,
+// whether it has a payload or not. This is the default behavior.
+func MuxerForceTablesOnRandomAccess(d *MuxerData, isPCRPID bool) bool {
+	return d.AdaptationField != nil && d.AdaptationField.RandomAccessIndicator && isPCRPID
+}
 
-		packetSize:             MpegTsPacketSize, // no 192-byte packet support yet
-		tablesRetransmitPeriod: 40,
+// MuxerPayloadEndFunc is called by WriteData once the last packet of a PES has been written to the writer, which
+// is a natural flush boundary such as the end of an access unit, for instance to set the RTP marker bit
+type MuxerPayloadEndFunc func(pid uint16)
 
-		pm: newProgramMap(),
-		pmt: PMTData{
-			ElementaryStreams: []*PMTElementaryStream{},
-			ProgramNumber:     programNumberStart,
-		},
+// MuxerPMTSizeFunc is called with the section_length of the PMT of a program when it no longer fits in a single
+// packet, which some receivers don't cope with. PMTs can't be split into several sections, section_number being
+// always 0, so they span several packets instead until the maximum section length is reached, after which
+// ErrPSISectionTooLong is returned.
+type MuxerPMTSizeFunc func(programNumber uint16, sectionLength int)
 
-		// table version is 5-bit field
-		patVersion: newWrappingCounter(0b11111),
-		pmtVersion: newWrappingCounter(0b11111),
+// isReservedPID checks whether the PID can't be used by an elementary stream
+func isReservedPID(pid uint16) bool {
+	return pid <= reservedPIDsEnd || pid >= reservedPIDsStart
+}
 
-		esContexts: map[uint16]*esContext{},
-	}
+// MuxerProgram represents a program written by the Muxer
+// Each program has its own PMT PID, program number and set of elementary streams
+type MuxerProgram struct {
+	m          *Muxer
+	pmt        PMTData
+	pmtBytes   bytes.Buffer
+	pmtDirty   bool // whether pmtBytes needs to be generated again
+	pmtPID     uint16
+	pmtPackets int // packets of the last PMT generated
+	pmtVersion wrappingCounter
+	service    *SDTDataService // described in the SDT when set
 
-	m.bufWriter = astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.buf})
-	m.bitsWriter = astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: m.w})
+	serviceDescriptors []*Descriptor // written in the SDT after the service descriptor
+}
 
-	// TODO multiple programs support
-	m.pm.set(pmtStartPID, programNumberStart)
+// MuxerPacketization represents how WriteData splits a PES into packets
+type MuxerPacketization uint8
 
-	for _, opt := range opts {
-		opt(m)
+// Packetizations
+const (
+	// PES header and data are packed in as few packets as possible, the last packet being stuffed. This is the
+	// default and suits audio best.
+	MuxerPacketizationMinimal MuxerPacketization = iota
+	// The first packet only carries the PES header, stuffed, so that PES data starts at the payload start of the next
+	// packet. This costs at most one packet per PES but aligns access units on packets, which suits video best and
+	// keeps PES headers in packets of their own when payloads are scrambled.
+	MuxerPacketizationAligned
+)
+
+type esContext struct {
+	es              *PMTElementaryStream
+	cc              wrappingCounter
+	discontinuity   bool // whether the next packet has its discontinuity indicator set
+	packetization   MuxerPacketization
+	tsc             uint8 // transport scrambling control of packets carrying payload
+	hasSplice       bool  // whether a splice has been scheduled with Muxer.ScheduleSplice
+	spliceCountdown int   // packets carrying payload until the splicing point
+}
+
+// spliceAdaptationField returns a copy of af, or a new adaptation field if af is nil, carrying the splice countdown
+func (ctx *esContext) spliceAdaptationField(af *PacketAdaptationField) *PacketAdaptationField {
+	c := &PacketAdaptationField{}
+	if af != nil {
+		*c = *af
 	}
+	c.HasSplicingCountdown = true
+	c.SpliceCountdown = ctx.spliceCountdown
+	return c
+}
 
-	// to output tables at the very start
-	m.tablesRetransmitCounter = m.tablesRetransmitPeriod
+func newEsContext(es *PMTElementaryStream) *esContext {
+	return &esContext{
+		es: es,
+		cc: newWrappingCounter(0b1111), // CC is 4 bits
+	}
+}
 
-	return m
+// MuxerOptTablesRetransmitPeriod sets the period, in PES packets, of tables without a period of their own
+func MuxerOptTablesRetransmitPeriod(newPeriod int) func(*Muxer) {
+	return func(m *Muxer) {
+		m.tablesRetransmitPeriod = newPeriod
+	}
 }
 
-// if es.ElementaryPID is zero, it will be generated automatically
-func (m *Muxer) AddElementaryStream(es PMTElementaryStream) error {
-	if es.ElementaryPID != 0 {
-		for _, oes := range m.pmt.ElementaryStreams {
-			if oes.ElementaryPID == es.ElementaryPID {
-				return ErrPIDAlreadyExists
-			}
-		}
-	} else {
-		es.ElementaryPID = m.nextPID
-		m.nextPID++
+// MuxerOptPATPeriod sets the period, in PES packets, at which the PAT and the SDT are retransmitted
+func MuxerOptPATPeriod(packets int) func(*Muxer) {
+	return func(m *Muxer) {
+		m.patPeriod.packets = packets
 	}
+}
 
-	m.pmt.ElementaryStreams = append(m.pmt.ElementaryStreams, &es)
+// MuxerOptPMTPeriod sets the period, in PES packets, at which PMTs are retransmitted
+func MuxerOptPMTPeriod(packets int) func(*Muxer) {
+	return func(m *Muxer) {
+		m.pmtPeriod.packets = packets
+	}
+}
 
-	m.esContexts[es.ElementaryPID] = newEsContext(&es)
-	// invalidate pmt cache
-	m.pmtBytes.Reset()
-	return nil
+// MuxerOptPATInterval sets the time, measured with the last PCR written, after which the PAT and the SDT are
+// retransmitted. It takes precedence over the period in PES packets
+func MuxerOptPATInterval(d time.Duration) func(*Muxer) {
+	return func(m *Muxer) {
+		m.patPeriod.duration = int64(d) * clockFrequency / int64(time.Second)
+	}
 }
 
-func (m *Muxer) RemoveElementaryStream(pid uint16) error {
-	foundIdx := -1
-	for i, oes := range m.pmt.ElementaryStreams {
-		if oes.ElementaryPID == pid {
-			foundIdx = i
-			break
-		}
+// MuxerOptPMTInterval sets the time, measured with the last PCR written, after which PMTs are retransmitted
+// It takes precedence over the period in PES packets
+func MuxerOptPMTInterval(d time.Duration) func(*Muxer) {
+	return func(m *Muxer) {
+		m.pmtPeriod.duration = int64(d) * clockFrequency / int64(time.Second)
 	}
+}
 
-	if foundIdx == -1 {
-		return ErrPIDNotFound
+// MuxerOptSpreadTables makes the muxer write the PAT and PMTs in consecutive writes when both are due, instead of
+// back to back, which reduces PSI bursts. New table versions and forced tables are still written all at once.
+func MuxerOptSpreadTables(spreadTables bool) func(*Muxer) {
+	return func(m *Muxer) {
+		m.spreadTables = spreadTables
 	}
+}
 
-	m.pmt.ElementaryStreams = append(m.pmt.ElementaryStreams[:foundIdx], m.pmt.ElementaryStreams[foundIdx+1:]...)
-	delete(m.esContexts, pid)
-	m.pmtBytes.Reset()
-	return nil
+// MuxerOptForceTablesFunc sets the function deciding whether tables must be written right before some data
+// A nil function disables forcing tables, in which case they're only written according to the retransmit period
+func MuxerOptForceTablesFunc(fn MuxerForceTablesFunc) func(*Muxer) {
+	return func(m *Muxer) {
+		m.forceTablesFunc = fn
+	}
 }
 
-// SetPCRPID marks pid as one to look PCRs in
-func (m *Muxer) SetPCRPID(pid uint16) {
-	m.pmt.PCRPID = pid
+// MuxerOptPayloadEndFunc sets the function called once the last packet of each PES written by WriteData has been
+// written to the writer
+func MuxerOptPayloadEndFunc(fn MuxerPayloadEndFunc) func(*Muxer) {
+	return func(m *Muxer) {
+		m.payloadEndFunc = fn
+	}
 }
 
-// WriteData writes MuxerData to TS stream
-// Currently only PES packets are supported
-// Be aware that after successful call WriteData will set d.AdaptationField.StuffingLength value to zero
-func (m *Muxer) WriteData(d *MuxerData) (int, error) {
-	ctx, ok := m.esContexts[d.PID]
-	if !ok {
-		return 0, ErrPIDNotFound
+// MuxerOptPMTSizeFunc sets the function called when the PMT of a program no longer fits in a single packet
+func MuxerOptPMTSizeFunc(fn MuxerPMTSizeFunc) func(*Muxer) {
+	return func(m *Muxer) {
+		m.pmtSizeFunc = fn
 	}
+}
 
-	bytesWritten := 0
+// MuxerOptStartPID sets the first PID allocated to elementary streams added without a PID
+func MuxerOptStartPID(pid uint16) func(*Muxer) {
+	return func(m *Muxer) {
+		m.nextPID = pid
+	}
+}
 
-	forceTables := d.AdaptationField != nil &&
-		d.AdaptationField.RandomAccessIndicator &&
-		d.PID == m.pmt.PCRPID
+// MuxerOptTablesOnClose makes the muxer write a final table set upon Close
+func MuxerOptTablesOnClose(tablesOnClose bool) func(*Muxer) {
+	return func(m *Muxer) {
+		m.tablesOnClose = tablesOnClose
+	}
+}
 
-	n, err := m.retransmitTables(forceTables)
-	if err != nil {
-		return n, err
+// MuxerOptRejectLatePTS makes WriteData return ErrPTSBehindPCR, without writing anything, when the PTS of a PES
+// is behind the last PCR written on the PCR PID of its program, since receivers would discard it anyway
+func MuxerOptRejectLatePTS(rejectLatePTS bool) func(*Muxer) {
+	return func(m *Muxer) {
+		m.rejectLatePTS = rejectLatePTS
 	}
+}
 
-	bytesWritten += n
+// MuxerOptServiceInfo describes the default program in the SDT, see MuxerProgram.SetServiceDescription
+func MuxerOptServiceInfo(providerName, serviceName string, serviceType uint8) func(*Muxer) {
+	return func(m *Muxer) {
+		m.defaultProgram.SetServiceDescription(providerName, serviceName, serviceType)
+	}
+}
 
-	payloadStart := true
-	writeAf := d.AdaptationField != nil
-	payloadBytesWritten := 0
-	for payloadBytesWritten < len(d.PES.Data) {
-		pktLen := 1 + mpegTsPacketHeaderSize // sync byte + header
-		pkt := Packet{
-			Header: &PacketHeader{
-				ContinuityCounter:         uint8(ctx.cc.get()),
-				HasAdaptationField:        writeAf,
-				HasPayload:                false,
-				PayloadUnitStartIndicator: false,
-				PID:                       d.PID,
-			},
+// MuxerOptPMTReservedBits overrides the reserved bits written in PMTs, which is only useful to interoperate with
+// receivers expecting non conformant values. Only the Reserved field of rb is used
+func MuxerOptPMTReservedBits(rb *ReservedBits) func(*Muxer) {
+	return func(m *Muxer) {
+		m.pmtReservedBits = rb
+		for _, p := range m.programs {
+			p.pmt.ReservedBits = rb
+			p.pmtDirty = true
 		}
+	}
+}
 
-		if writeAf {
-			pkt.AdaptationField = d.AdaptationField
-			// one byte for adaptation field length field
-			pktLen += 1 + int(calcPacketAdaptationFieldLength(d.AdaptationField))
-			writeAf = false
+// MuxerOptPacketSize sets the size of the packets written by the muxer
+// Only 188 (MpegTsPacketSize) and 192 (M2TS) bytes packets are supported, other sizes being ignored and reported as
+// ErrPacketSizeInvalid by WriteData and Validate
+// 192 bytes packets are prefixed with a 4 bytes TP_extra_header containing the arrival timestamp, and are detected
+// by the demuxer unless DemuxerOptPacketSize is used
+func MuxerOptPacketSize(packetSize int) func(*Muxer) {
+	return func(m *Muxer) {
+		if packetSize != MpegTsPacketSize && packetSize != MpegTsPacketSize+m2tsHeaderSize {
+			m.optErr = fmt.Errorf("astits: packet size %d is neither %d nor %d: %w", packetSize, MpegTsPacketSize, MpegTsPacketSize+m2tsHeaderSize, ErrPacketSizeInvalid)
+			return
 		}
+		m.packetSize = packetSize
+	}
+}
 
-		bytesAvailable := m.packetSize - pktLen
-		if payloadStart {
-			pesHeaderLengthCurrent := pesHeaderLength + int(calcPESOptionalHeaderLength(d.PES.Header.OptionalHeader))
-			// d.AdaptationField with pes header are too big, we don't have space to write pes header
-			if bytesAvailable < pesHeaderLengthCurrent {
-				pkt.Header.HasAdaptationField = true
-				if pkt.AdaptationField == nil {
-					pkt.AdaptationField = newStuffingAdaptationField(bytesAvailable)
-				} else {
-					pkt.AdaptationField.StuffingLength = bytesAvailable
-				}
-			} else {
-				pkt.Header.HasPayload = true
-				pkt.Header.PayloadUnitStartIndicator = true
-			}
-		} else {
-			pkt.Header.HasPayload = true
-		}
+// MuxerOptPATPID writes the PAT on pid instead of PIDPAT. This produces a non compliant stream on purpose, which is
+// only useful to test how receivers cope with it. pid must not be used by an elementary stream or a PMT.
+func MuxerOptPATPID(pid uint16) func(*Muxer) {
+	return func(m *Muxer) {
+		m.patPID = pid
+	}
+}
 
-		if pkt.Header.HasPayload {
-			m.buf.Reset()
-			if d.PES.Header.StreamID == 0 {
-				d.PES.Header.StreamID = ctx.es.StreamType.ToPESStreamID()
-			}
+// MuxerOptArrivalTimeFunc sets the 27MHz clock used to compute M2TS arrival timestamps
+// If not set, arrival timestamps are derived from the last PCR written
+func MuxerOptArrivalTimeFunc(fn func() uint64) func(*Muxer) {
+	return func(m *Muxer) {
+		m.arrivalTimeFunc = fn
+	}
+}
 
-			ntot, npayload, err := writePESData(
-				m.bufWriter,
-				d.PES.Header,
-				d.PES.Data[payloadBytesWritten:],
-				payloadStart,
-				bytesAvailable,
-			)
-			if err != nil {
-				return bytesWritten, err
-			}
+// TODO MuxerOptAutodetectPCRPID selecting first video PID for each PMT, falling back to first audio, falling back to any other
 
-			payloadBytesWritten += npayload
+func NewMuxer(ctx context.Context, w io.Writer, opts ...func(*Muxer)) *Muxer {
+	m := &Muxer{
+		ctx: ctx,
+		w:   w,
 
-			pkt.Payload = m.buf.Bytes()
+		packetSize:             MpegTsPacketSize,
+		tablesRetransmitPeriod: 40,
+		forceTablesFunc:        MuxerForceTablesOnRandomAccess,
 
-			bytesAvailable -= ntot
-			// if we still have some space in packet, we should stuff it with adaptation field stuffing
-			// we can't stuff packets with 0xff at the end of a packet since it's not uncommon for PES payloads to have length unspecified
-			if bytesAvailable > 0 {
-				pkt.Header.HasAdaptationField = true
-				if pkt.AdaptationField == nil {
-					pkt.AdaptationField = newStuffingAdaptationField(bytesAvailable)
-				} else {
-					pkt.AdaptationField.StuffingLength = bytesAvailable
-				}
-			}
+		pm:         newProgramMap(),
+		nextPID:    startPID,
+		nextPMTPID: pmtStartPID,
 
-			n, err = writePacket(m.bitsWriter, &pkt, m.packetSize)
-			if err != nil {
-				return bytesWritten, err
-			}
+		// table version is 5-bit field
+		patVersion:  newWrappingCounter(0b11111),
+		sdtVersion:  newWrappingCounter(0b11111),
+		nitVersion:  newWrappingCounter(0b11111),
+		tsdtVersion: newWrappingCounter(0b11111),
+
+		esContexts:     map[uint16]*esContext{},
+		labels:         map[string]uint16{},
+		lastPCRs:       map[uint16]int64{},
+		pcrCheckPoints: map[uint16]pcrCheckPoint{},
+		tableCCs:       map[uint16]*wrappingCounter{},
+		stats:          MuxerStats{PIDPackets: map[uint16]int64{}},
+	}
 
-			bytesWritten += n
+	m.bufWriter = astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.buf})
+	m.bitsWriter = astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: m.w})
+	m.pktBufWriter = astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.pktBuf})
 
-			payloadStart = false
-		}
-	}
+	// Existing single-program callers use the default program through Muxer methods
+	m.defaultProgram, _ = m.AddProgram(programNumberStart)
 
-	if d.AdaptationField != nil {
-		d.AdaptationField.StuffingLength = 0
+	for _, opt := range opts {
+		opt(m)
 	}
 
-	return bytesWritten, nil
+	return m
 }
 
-// Writes given packet to MPEG-TS stream
-// Stuffs with 0xffs if packet turns out to be shorter than target packet length
-func (m *Muxer) WritePacket(p *Packet) (int, error) {
-	return writePacket(m.bitsWriter, p, m.packetSize)
-}
+// AddProgram adds a new program to the stream and returns a handle to it
+// Its PMT PID is allocated automatically
+func (m *Muxer) AddProgram(programNumber uint16) (*MuxerProgram, error) {
+	// Program number 0 is reserved to NIT
+	if programNumber == 0 {
+		return nil, ErrProgramNumberInvalid
+	}
 
-func (m *Muxer) retransmitTables(force bool) (int, error) {
-	m.tablesRetransmitCounter++
-	if !force && m.tablesRetransmitCounter < m.tablesRetransmitPeriod {
-		return 0, nil
+	if m.program(programNumber) != nil {
+		return nil, ErrProgramNumberAlreadyExists
 	}
 
-	n, err := m.WriteTables()
-	if err != nil {
-		return n, err
+	for m.pm.exists(m.nextPMTPID) {
+		m.nextPMTPID++
 	}
 
-	m.tablesRetransmitCounter = 0
-	return n, nil
+	p := &MuxerProgram{
+		m: m,
+		pmt: PMTData{
+			ElementaryStreams: []*PMTElementaryStream{},
+			ProgramNumber:     programNumber,
+			ReservedBits:      m.pmtReservedBits,
+		},
+		pmtPID: m.nextPMTPID,
+		// table version is 5-bit field
+		pmtVersion: newWrappingCounter(0b11111),
+		pmtDirty:   true,
+	}
+	m.nextPMTPID++
+
+	m.programs = append(m.programs, p)
+	m.pm.set(p.pmtPID, programNumber)
+	// invalidate pat cache
+	m.patDirty = true
+	// an unused default program is left out of the sdt once another program exists
+	if m.defaultProgram != nil && m.defaultProgram.service != nil && m.defaultProgram.isUnused() {
+		m.sdtDirty = true
+	}
+	return p, nil
 }
 
-func (m *Muxer) WriteTables() (int, error) {
-	bytesWritten := 0
-
-	if m.patBytes.Len() != m.packetSize {
-		if err := m.generatePAT(); err != nil {
-			return bytesWritten, err
+// AddFillerProgram adds a placeholder program, such as a DVB filler or barker channel, and returns a handle to it
+// It's made of an MPEG-2 video stream carrying the PCR, meant for a still picture, and an MPEG-1 audio stream of
+// undetermined language, meant for silence, whose PIDs are allocated automatically. Streams are given component
+// tags 1 and 2 and the program is described in the SDT as a digital television service.
+// The default program is used as long as it has no elementary stream, so that the filler program can be the only one.
+func (m *Muxer) AddFillerProgram(programNumber uint16, providerName, serviceName string) (*MuxerProgram, error) {
+	// The default program is used if empty
+	p := m.claimDefaultProgram(programNumber)
+	if p == nil {
+		var err error
+		if p, err = m.AddProgram(programNumber); err != nil {
+			return nil, err
 		}
 	}
 
-	if m.pmtBytes.Len() != m.packetSize {
-		if err := m.generatePMT(); err != nil {
-			return bytesWritten, err
+	for i, es := range []PMTElementaryStream{
+		{
+			ElementaryStreamDescriptors: []*Descriptor{
+				{StreamIdentifier: &DescriptorStreamIdentifier{ComponentTag: 1}, Tag: DescriptorTagStreamIdentifier},
+			},
+			StreamType: StreamTypeMPEG2Video,
+		},
+		{
+			ElementaryStreamDescriptors: []*Descriptor{
+				{StreamIdentifier: &DescriptorStreamIdentifier{ComponentTag: 2}, Tag: DescriptorTagStreamIdentifier},
+				{
+					ISO639LanguageAndAudioType: &DescriptorISO639LanguageAndAudioType{Language: []byte("und")},
+					Tag:                        DescriptorTagISO639LanguageAndAudioType,
+				},
+			},
+			StreamType: StreamTypeMPEG1Audio,
+		},
+	} {
+		// Lengths are set so that the descriptors match the ones read back by the demuxer
+		for _, d := range es.ElementaryStreamDescriptors {
+			d.Length = calcDescriptorLength(d)
+		}
+		if err := p.AddElementaryStream(es); err != nil {
+			return nil, fmt.Errorf("astits: adding elementary stream #%d failed: %w", i, err)
 		}
 	}
 
-	n, err := m.w.Write(m.patBytes.Bytes())
-	if err != nil {
-		return bytesWritten, err
+	if err := p.SetPCRPID(p.pmt.ElementaryStreams[0].ElementaryPID); err != nil {
+		return nil, err
 	}
-	bytesWritten += n
+	p.SetServiceDescription(providerName, serviceName, ServiceTypeDigitalTelevisionService)
+	return p, nil
+}
 
-	n, err = m.w.Write(m.pmtBytes.Bytes())
-	if err != nil {
-		return bytesWritten, err
-	}
-	bytesWritten += n
+// if es.ElementaryPID is zero, it will be generated automatically
+// The elementary stream is added to the default program
+func (m *Muxer) AddElementaryStream(es PMTElementaryStream) error {
+	return m.defaultProgram.AddElementaryStream(es)
+}
 
-	return bytesWritten, nil
+// RemoveElementaryStream removes an elementary stream from the default program
+func (m *Muxer) RemoveElementaryStream(pid uint16) error {
+	return m.defaultProgram.RemoveElementaryStream(pid)
 }
 
-func (m *Muxer) generatePAT() error {
-	d := m.pm.toPATData()
-	syntax := &PSISectionSyntax{
-		Data: &PSISectionSyntaxData{PAT: d},
-		Header: &PSISectionSyntaxHeader{
-			CurrentNextIndicator: true,
-			// TODO support for PAT tables longer than 1 TS packet
-			//LastSectionNumber:    0,
-			//SectionNumber:        0,
-			TableIDExtension: d.TransportStreamID,
-			VersionNumber:    uint8(m.patVersion.get()),
-		},
-	}
-	section := PSISection{
-		Header: &PSISectionHeader{
-			SectionLength:          calcPATSectionLength(d),
-			SectionSyntaxIndicator: true,
-			TableID:                PSITableID(d.TransportStreamID),
-		},
-		Syntax: syntax,
-	}
-	psiData := PSIData{
-		Sections: []*PSISection{&section},
+// AddStream adds an elementary stream to the default program and returns its PID
+// The stream can then be written with WriteDataByLabel instead of its PID, which is allocated automatically
+// if es.ElementaryPID is zero. If isPCR is true, the stream becomes the PCR PID of the default program.
+func (m *Muxer) AddStream(label string, es PMTElementaryStream, isPCR bool) (uint16, error) {
+	if _, ok := m.labels[label]; ok {
+		return 0, ErrLabelAlreadyExists
 	}
 
-	m.buf.Reset()
-	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.buf})
-	if _, err := writePSIData(w, &psiData); err != nil {
-		return err
+	p := m.defaultProgram
+	if err := p.AddElementaryStream(es); err != nil {
+		return 0, err
 	}
+	pid := p.pmt.ElementaryStreams[len(p.pmt.ElementaryStreams)-1].ElementaryPID
+	m.labels[label] = pid
 
-	m.patBytes.Reset()
-	wPacket := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.patBytes})
+	if isPCR {
+		if err := p.SetPCRPID(pid); err != nil {
+			return 0, err
+		}
+	}
+	return pid, nil
+}
 
-	pkt := Packet{
-		Header: &PacketHeader{
-			HasPayload:                true,
-			PayloadUnitStartIndicator: true,
-			PID:                       PIDPAT,
-		},
-		Payload: m.buf.Bytes(),
+// SetContinuityCounter sets the continuity counter of the next packet carrying payload on the elementary stream pid
+// It comes in handy when appending to an existing stream, so that demuxers don't detect discontinuities
+func (m *Muxer) SetContinuityCounter(pid uint16, cc uint8) error {
+	ctx, ok := m.esContexts[pid]
+	if !ok {
+		return ErrPIDNotFound
 	}
-	if _, err := writePacket(wPacket, &pkt, m.packetSize); err != nil {
-		// FIXME save old PAT and rollback to it here maybe?
-		return err
+	if cc > 0b1111 {
+		return ErrContinuityCounterInvalid
 	}
+	ctx.cc.value = int(cc)
+	return nil
+}
 
+// ResetContinuityCounter numbers the next packet carrying payload on the elementary stream pid with a continuity
+// counter of 0, which comes in handy when splicing streams. When signalDiscontinuity is true, the next packet written
+// by WriteData on pid has the discontinuity indicator of its adaptation field set.
+func (m *Muxer) ResetContinuityCounter(pid uint16, signalDiscontinuity bool) error {
+	if err := m.SetContinuityCounter(pid, 0); err != nil {
+		return err
+	}
+	m.esContexts[pid].discontinuity = signalDiscontinuity
 	return nil
 }
 
-func (m *Muxer) generatePMT() error {
-	hasPCRPID := false
-	for _, es := range m.pmt.ElementaryStreams {
-		if es.ElementaryPID == m.pmt.PCRPID {
-			hasPCRPID = true
-			break
-		}
+// SetScramblingControl sets the transport scrambling control of packets carrying payload on the elementary stream pid,
+// for payloads provided already scrambled or scrambled downstream. Packets without payload are never marked as
+// scrambled.
+func (m *Muxer) SetScramblingControl(pid uint16, tsc uint8) error {
+	ctx, ok := m.esContexts[pid]
+	if !ok {
+		return ErrPIDNotFound
 	}
-	if !hasPCRPID {
-		return ErrPCRPIDInvalid
+	if tsc > 0b11 {
+		return ErrScramblingControlInvalid
 	}
+	ctx.tsc = tsc
+	return nil
+}
 
-	syntax := &PSISectionSyntax{
-		Data: &PSISectionSyntaxData{PMT: &m.pmt},
-		Header: &PSISectionSyntaxHeader{
-			CurrentNextIndicator: true,
-			// TODO support for PMT tables longer than 1 TS packet
-			//LastSectionNumber:    0,
-			//SectionNumber:        0,
-			TableIDExtension: m.pmt.ProgramNumber,
-			VersionNumber:    uint8(m.pmtVersion.get()),
-		},
+// SetPacketization sets how PES written by WriteData on the elementary stream pid are split into packets
+func (m *Muxer) SetPacketization(pid uint16, p MuxerPacketization) error {
+	ctx, ok := m.esContexts[pid]
+	if !ok {
+		return ErrPIDNotFound
+	}
+	ctx.packetization = p
+	return nil
+}
+
+// ScheduleSplice signals a splicing point on the elementary stream pid, packetsUntilSplice packets carrying payload
+// from now on. Subsequent packets carrying payload written by WriteData on pid have their splicing point flag set and
+// a splice countdown decreasing down to 0, which is reached by the packet right before the splicing point.
+// Packets without payload, such as PCR only packets, don't count and packets written with WritePacket are left
+// untouched. When a packet also carries a PCR, be it written by the muxer or provided in MuxerData.AdaptationField,
+// the splice countdown is written in the same adaptation field, which reduces the payload of the packet accordingly.
+// The scheduled splice countdown overrides the one of MuxerData.AdaptationField, which isn't modified.
+func (m *Muxer) ScheduleSplice(pid uint16, packetsUntilSplice int) error {
+	ctx, ok := m.esContexts[pid]
+	if !ok {
+		return ErrPIDNotFound
+	}
+	// splice_countdown is an 8 bits two's complement
+	if packetsUntilSplice < 0 || packetsUntilSplice > 127 {
+		return ErrSpliceCountdownInvalid
+	}
+	ctx.hasSplice = true
+	ctx.spliceCountdown = packetsUntilSplice
+	return nil
+}
+
+// SetPCRPID marks pid as one to look PCRs in for the default program
+func (m *Muxer) SetPCRPID(pid uint16) error {
+	return m.defaultProgram.SetPCRPID(pid)
+}
+
+// SetNetworkInformation describes the network in the NIT, which some receivers require for tuning
+// The NIT is written alongside the PAT and announced in it
+func (m *Muxer) SetNetworkInformation(networkID uint16, networkName string, transportStreams []*NITDataTransportStream) {
+	d := &Descriptor{
+		NetworkName: &DescriptorNetworkName{Name: []byte(networkName)},
+		Tag:         DescriptorTagNetworkName,
+	}
+	// Length is set so that the network matches the one read back by the demuxer
+	d.Length = calcDescriptorLength(d)
+
+	// PAT announces the NIT PID
+	if m.nit == nil {
+		m.patDirty = true
+	}
+
+	m.nit = &NITData{
+		NetworkDescriptors: []*Descriptor{d},
+		NetworkID:          networkID,
+		TransportStreams:   transportStreams,
+	}
+	// invalidate nit cache
+	m.nitDirty = true
+}
+
+// SetOriginalNetworkID sets the original_network_id of the transport stream, which is written in the SDT and
+// overrides the one of the NIT entry describing the transport stream so that tables are consistent.
+// It defaults to 0. Tables written with WritePSISection are left untouched.
+func (m *Muxer) SetOriginalNetworkID(id uint16) {
+	if m.hasOriginalNetworkID && m.originalNetworkID == id {
+		return
+	}
+	m.originalNetworkID = id
+	m.hasOriginalNetworkID = true
+	// invalidate sdt and nit caches
+	m.sdtDirty = true
+	m.nitDirty = true
+}
+
+// SetTransportStreamDescriptors sets the descriptors written in the TSDT, which apply to the whole transport stream
+// and whose length is computed from their content. The TSDT is written alongside the PAT, and isn't written anymore
+// when there are no descriptors.
+func (m *Muxer) SetTransportStreamDescriptors(descriptors []*Descriptor) {
+	if len(descriptors) == 0 {
+		if m.tsdt == nil {
+			return
+		}
+		m.tsdt = nil
+	} else {
+		for _, d := range descriptors {
+			d.Length = calcDescriptorLength(d)
+		}
+		m.tsdt = &TSDTData{Descriptors: descriptors}
+	}
+	// invalidate tsdt cache
+	m.tsdtDirty = true
+}
+
+// SetServiceDescription describes the program in the SDT, which makes players display its name
+// The SDT is written alongside the PAT and PMTs
+func (m *Muxer) SetServiceDescription(programNumber uint16, providerName, serviceName string, serviceType uint8) error {
+	p := m.program(programNumber)
+	if p == nil {
+		return ErrProgramNumberNotFound
+	}
+	p.SetServiceDescription(providerName, serviceName, serviceType)
+	return nil
+}
+
+// SetServiceDescription describes the program in the SDT, which makes players display its name
+func (p *MuxerProgram) SetServiceDescription(providerName, serviceName string, serviceType uint8) {
+	d := &Descriptor{
+		Service: &DescriptorService{
+			Name:     []byte(serviceName),
+			Provider: []byte(providerName),
+			Type:     serviceType,
+		},
+		Tag: DescriptorTagService,
+	}
+	// Length is set so that the service matches the one read back by the demuxer
+	d.Length = calcDescriptorLength(d)
+
+	// Running status is kept
+	runningStatus := uint8(RunningStatusRunning)
+	if p.service != nil {
+		runningStatus = p.service.RunningStatus
+	}
+
+	p.service = &SDTDataService{
+		Descriptors:   append([]*Descriptor{d}, p.serviceDescriptors...),
+		RunningStatus: runningStatus,
+		ServiceID:     p.pmt.ProgramNumber,
+	}
+	// invalidate sdt cache
+	p.m.sdtDirty = true
+}
+
+// SetServiceDescriptors sets the descriptors written in the SDT after the service descriptor of the program, such as
+// CA identifier, content or service move descriptors
+func (m *Muxer) SetServiceDescriptors(programNumber uint16, descriptors []*Descriptor) error {
+	p := m.program(programNumber)
+	if p == nil {
+		return ErrProgramNumberNotFound
+	}
+	p.SetServiceDescriptors(descriptors)
+	return nil
+}
+
+// SetServiceDescriptors sets the descriptors written in the SDT after the service descriptor of the program, their
+// length being computed from their content. They replace the ones previously set.
+// The program is added to the SDT as a running service if it has no service description
+func (p *MuxerProgram) SetServiceDescriptors(descriptors []*Descriptor) {
+	for _, d := range descriptors {
+		d.Length = calcDescriptorLength(d)
+	}
+	p.serviceDescriptors = descriptors
+
+	// Service descriptor is kept
+	var ds []*Descriptor
+	if p.service == nil {
+		p.service = &SDTDataService{
+			RunningStatus: RunningStatusRunning,
+			ServiceID:     p.pmt.ProgramNumber,
+		}
+	} else if len(p.service.Descriptors) > 0 && p.service.Descriptors[0].Tag == DescriptorTagService {
+		ds = append(ds, p.service.Descriptors[0])
+	}
+	p.service.Descriptors = append(ds, descriptors...)
+	// invalidate sdt cache
+	p.m.sdtDirty = true
+}
+
+// SetServiceRunningStatus sets the running status of the program in the SDT, which is written again with a new
+// version. See RunningStatus* constants.
+func (m *Muxer) SetServiceRunningStatus(programNumber uint16, status uint8) error {
+	p := m.program(programNumber)
+	if p == nil {
+		return ErrProgramNumberNotFound
+	}
+	p.SetServiceRunningStatus(status)
+	return nil
+}
+
+// SetServiceRunningStatus sets the running status of the program in the SDT
+// The program is added to the SDT without descriptors if it has no service description
+func (p *MuxerProgram) SetServiceRunningStatus(status uint8) {
+	if p.service == nil {
+		p.service = &SDTDataService{ServiceID: p.pmt.ProgramNumber}
+	} else if p.service.RunningStatus == status {
+		return
+	}
+	p.service.RunningStatus = status
+	// invalidate sdt cache
+	p.m.sdtDirty = true
+}
+
+// program returns the program with the given program number, or nil if there's none
+func (m *Muxer) program(programNumber uint16) *MuxerProgram {
+	for _, p := range m.programs {
+		if p.pmt.ProgramNumber == programNumber {
+			return p
+		}
+	}
+	return nil
+}
+
+// ProgramNumber returns the program number
+func (p *MuxerProgram) ProgramNumber() uint16 {
+	return p.pmt.ProgramNumber
+}
+
+// PMTPID returns the PID the program PMT is written to
+func (p *MuxerProgram) PMTPID() uint16 {
+	return p.pmtPID
+}
+
+// PMTData returns a deep copy of the PMT data the muxer writes for the default program, including automatically
+// allocated PIDs and the PCR PID
+func (m *Muxer) PMTData() PMTData {
+	return m.defaultProgram.PMTData()
+}
+
+// PMTData returns a deep copy of the PMT data the muxer writes for the program
+func (p *MuxerProgram) PMTData() PMTData {
+	return p.pmt.clone()
+}
+
+// allocatePID returns the next free PID, skipping reserved PIDs and PIDs already in use
+func (m *Muxer) allocatePID() uint16 {
+	for isReservedPID(m.nextPID) || m.pm.exists(m.nextPID) || m.esContexts[m.nextPID] != nil {
+		if m.nextPID >= reservedPIDsStart {
+			m.nextPID = reservedPIDsEnd
+		}
+		m.nextPID++
+	}
+	pid := m.nextPID
+	m.nextPID++
+	return pid
+}
+
+// if es.ElementaryPID is zero, it will be generated automatically
+// es.ElementaryStreamDescriptors are written in the PMT, their length being computed from their content
+func (p *MuxerProgram) AddElementaryStream(es PMTElementaryStream) error {
+	m := p.m
+	if es.ElementaryPID != 0 {
+		if _, ok := m.esContexts[es.ElementaryPID]; ok {
+			return ErrPIDAlreadyExists
+		}
+	} else {
+		es.ElementaryPID = m.allocatePID()
+	}
+
+	p.pmt.ElementaryStreams = append(p.pmt.ElementaryStreams, &es)
+
+	m.esContexts[es.ElementaryPID] = newEsContext(&es)
+	// invalidate pmt cache
+	p.pmtDirty = true
+	// default program is announced with its first elementary stream
+	if p == m.defaultProgram && len(p.pmt.ElementaryStreams) == 1 {
+		m.patDirty = true
+		m.sdtDirty = true
+	}
+	return nil
+}
+
+func (p *MuxerProgram) RemoveElementaryStream(pid uint16) error {
+	foundIdx := -1
+	for i, oes := range p.pmt.ElementaryStreams {
+		if oes.ElementaryPID == pid {
+			foundIdx = i
+			break
+		}
+	}
+
+	if foundIdx == -1 {
+		return ErrPIDNotFound
+	}
+
+	p.pmt.ElementaryStreams = append(p.pmt.ElementaryStreams[:foundIdx], p.pmt.ElementaryStreams[foundIdx+1:]...)
+	delete(p.m.esContexts, pid)
+	for label, lpid := range p.m.labels {
+		if lpid == pid {
+			delete(p.m.labels, label)
+		}
+	}
+	p.pmtDirty = true
+	// default program is no longer announced without elementary streams
+	if p.isUnused() {
+		p.m.patDirty = true
+		p.m.sdtDirty = true
+	}
+	return nil
+}
+
+// isUnused checks whether the program is the default program without elementary streams while other programs exist,
+// in which case it is left out of the PAT, the PMTs and the SDT so that callers adding their own programs don't get
+// an empty program 1
+func (p *MuxerProgram) isUnused() bool {
+	return p == p.m.defaultProgram && len(p.pmt.ElementaryStreams) == 0 && len(p.m.programs) > 1
+}
+
+// SetPCRPID marks pid as one to look PCRs in
+// pid must be one of the program elementary streams. It can be changed at any time, in which case the PMT
+// is written again with a new version and PCRs inserted by the muxer move to the new PID.
+func (p *MuxerProgram) SetPCRPID(pid uint16) error {
+	if !p.hasElementaryStream(pid) {
+		return fmt.Errorf("astits: PCR PID %d is not an elementary stream of program %d: %w", pid, p.pmt.ProgramNumber, ErrPCRPIDInvalid)
+	}
+	if p.pmt.PCRPID == pid {
+		return nil
+	}
+	p.pmt.PCRPID = pid
+	// invalidate pmt cache
+	p.pmtDirty = true
+	return nil
+}
+
+// hasElementaryStream checks whether pid is one of the program elementary streams
+func (p *MuxerProgram) hasElementaryStream(pid uint16) bool {
+	for _, es := range p.pmt.ElementaryStreams {
+		if es.ElementaryPID == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPCRPID checks whether the PCR PID of the program is one of its elementary streams
+func (p *MuxerProgram) hasPCRPID() bool {
+	return p.hasElementaryStream(p.pmt.PCRPID)
+}
+
+// Validate checks the muxer configuration, which is otherwise only checked once data is written
+// It returns an error naming the offending PID when the PCR PID of a program is not one of its elementary
+// streams, which happens when no PCR PID has been set or when its elementary stream has been removed.
+// Programs without elementary streams are not checked. Invalid options, such as an unsupported packet size, are
+// reported as well.
+func (m *Muxer) Validate() error {
+	if m.optErr != nil {
+		return m.optErr
+	}
+	return m.checkPCRPIDs()
+}
+
+// checkPCRPIDs makes sure each program with elementary streams has a valid PCR PID
+func (m *Muxer) checkPCRPIDs() error {
+	for _, p := range m.programs {
+		if len(p.pmt.ElementaryStreams) > 0 && !p.hasPCRPID() {
+			return fmt.Errorf("astits: program %d has no elementary stream with PCR PID %d: %w", p.pmt.ProgramNumber, p.pmt.PCRPID, ErrPCRPIDInvalid)
+		}
+	}
+	return nil
+}
+
+// WriteData writes MuxerData to TS stream
+// Currently only PES packets are supported
+// Be aware that after successful call WriteData will set d.AdaptationField.StuffingLength value to zero
+// Writing stops in between packets once the muxer context is cancelled, in which case the context error is returned
+// A PES without data but with an optional header is written as a single packet carrying only the PES header, which
+// comes in handy to signal a point on the timeline through its PTS
+// A PES header with a RawOptionalHeader is written with it verbatim, PES_packet_length being computed accordingly,
+// but has no timestamps as far as the muxer is concerned
+func (m *Muxer) WriteData(d *MuxerData) (int, error) {
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+	if m.optErr != nil {
+		return 0, m.optErr
+	}
+	if err := m.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	ctx, ok := m.esContexts[d.PID]
+	if !ok {
+		return 0, ErrPIDNotFound
+	}
+
+	// Tables can't be generated without a valid PCR PID, which is better reported before anything is written
+	if err := m.checkPCRPIDs(); err != nil {
+		return 0, err
+	}
+
+	// Raw optional header must fit in the first packet alongside the adaptation field
+	if d.PES.Header != nil && d.PES.Header.RawOptionalHeader != nil {
+		if max := m.maxRawOptionalHeaderLength(d, ctx); len(d.PES.Header.RawOptionalHeader) > max {
+			return 0, fmt.Errorf("astits: raw optional header is %d bytes long but only %d bytes fit in the first packet: %w", len(d.PES.Header.RawOptionalHeader), max, ErrRawOptionalHeaderInvalid)
+		}
+	}
+
+	if m.rejectLatePTS && m.isPTSLate(d) {
+		return 0, ErrPTSBehindPCR
+	}
+
+	bytesWritten := 0
+
+	if m.bitrate > 0 {
+		n, err := m.waitForData(d)
+		bytesWritten += n
+		if err != nil {
+			return bytesWritten, err
+		}
+	}
+
+	isPCRPID := m.isPCRPID(d.PID)
+	forceTables := m.forceTablesFunc != nil && m.forceTablesFunc(d, isPCRPID)
+
+	n, err := m.retransmitTables(forceTables)
+	bytesWritten += n
+	if err != nil {
+		return bytesWritten, err
+	}
+
+	payloadStart := true
+	writeAf := d.AdaptationField != nil
+	payloadBytesWritten := 0
+	headerOnly := len(d.PES.Data) == 0 && d.PES.Header != nil && (d.PES.Header.OptionalHeader != nil || d.PES.Header.RawOptionalHeader != nil)
+	for payloadBytesWritten < len(d.PES.Data) || (headerOnly && payloadStart) {
+		// Writing a large PES can be aborted in between packets
+		if err = m.ctx.Err(); err != nil {
+			return bytesWritten, err
+		}
+
+		pktLen := 1 + mpegTsPacketHeaderSize // sync byte + header
+		pkt := Packet{
+			Header: &PacketHeader{
+				HasAdaptationField:        writeAf,
+				HasPayload:                false,
+				PayloadUnitStartIndicator: false,
+				PID:                       d.PID,
+			},
+		}
+
+		if writeAf {
+			pkt.AdaptationField = d.AdaptationField
+			if isPCRPID && !d.AdaptationField.HasPCR && m.pcrOnEveryPacket && m.hasClock() {
+				af := *d.AdaptationField
+				af.HasPCR = true
+				af.PCR = m.clockReference()
+				pkt.AdaptationField = &af
+			}
+			// one byte for adaptation field length field
+			pktLen += 1 + int(calcPacketAdaptationFieldLength(pkt.AdaptationField))
+			writeAf = false
+		} else if isPCRPID && m.isPESPCRDue(d.PID) {
+			pkt.Header.HasAdaptationField = true
+			pkt.AdaptationField = &PacketAdaptationField{
+				HasPCR: true,
+				PCR:    m.clockReference(),
+			}
+			// one byte for adaptation field length field
+			pktLen += 1 + int(calcPacketAdaptationFieldLength(pkt.AdaptationField))
+		}
+
+		if ctx.discontinuity {
+			af := &PacketAdaptationField{}
+			if pkt.AdaptationField != nil {
+				pktLen -= 1 + int(calcPacketAdaptationFieldLength(pkt.AdaptationField))
+				*af = *pkt.AdaptationField
+			}
+			af.DiscontinuityIndicator = true
+			pkt.Header.HasAdaptationField = true
+			pkt.AdaptationField = af
+			// one byte for adaptation field length field
+			pktLen += 1 + int(calcPacketAdaptationFieldLength(pkt.AdaptationField))
+			ctx.discontinuity = false
+		}
+
+		if ctx.hasSplice {
+			if pkt.AdaptationField != nil {
+				pktLen -= 1 + int(calcPacketAdaptationFieldLength(pkt.AdaptationField))
+			}
+			pkt.Header.HasAdaptationField = true
+			pkt.AdaptationField = ctx.spliceAdaptationField(pkt.AdaptationField)
+			// one byte for adaptation field length field
+			pktLen += 1 + int(calcPacketAdaptationFieldLength(pkt.AdaptationField))
+		}
+
+		bytesAvailable := MpegTsPacketSize - pktLen
+		payloadBytesAvailable := bytesAvailable
+		if payloadStart {
+			pesHeaderLengthCurrent := pesHeaderLength + d.PES.Header.optionalHeaderLength()
+			// d.AdaptationField with pes header are too big, we don't have space to write pes header
+			// Adaptation field is therefore written in its own packet and pes starts in the next one
+			if bytesAvailable < pesHeaderLengthCurrent {
+				// Without adaptation field, the pes header doesn't fit in a packet at all
+				if pkt.AdaptationField == nil {
+					return bytesWritten, ErrRawOptionalHeaderInvalid
+				}
+				pkt.AdaptationField.StuffingLength = bytesAvailable
+				// CC doesn't advance since there's no payload
+				pkt.Header.ContinuityCounter = uint8(ctx.cc.last())
+
+				n, err = m.writePacket(&pkt)
+				bytesWritten += n
+				if err != nil {
+					return bytesWritten, err
+				}
+				continue
+			}
+			pkt.Header.PayloadUnitStartIndicator = true
+
+			// PES data starts in the next packet
+			if ctx.packetization == MuxerPacketizationAligned {
+				payloadBytesAvailable = pesHeaderLengthCurrent
+			}
+		}
+		pkt.Header.HasPayload = true
+		pkt.Header.ContinuityCounter = uint8(ctx.cc.get())
+		pkt.Header.TransportScramblingControl = ctx.tsc
+
+		if d.PES.Header.StreamID == 0 {
+			d.PES.Header.StreamID = ctx.es.StreamType.ToPESStreamID()
+		}
+
+		var npayload int
+		pkt.Payload, npayload, err = m.pesPacketPayload(
+			d.PES.Header,
+			d.PES.Data[payloadBytesWritten:],
+			payloadStart,
+			payloadBytesAvailable,
+		)
+		if err != nil {
+			return bytesWritten, err
+		}
+
+		payloadBytesWritten += npayload
+
+		bytesAvailable -= len(pkt.Payload)
+		// if we still have some space in packet, we should stuff it with adaptation field stuffing
+		// we can't stuff packets with 0xff at the end of a packet since it's not uncommon for PES payloads to have length unspecified
+		if bytesAvailable > 0 {
+			pkt.Header.HasAdaptationField = true
+			if pkt.AdaptationField == nil {
+				pkt.AdaptationField = newStuffingAdaptationField(bytesAvailable)
+			} else {
+				pkt.AdaptationField.StuffingLength = bytesAvailable
+			}
+		}
+
+		n, err = m.writePacket(&pkt)
+		if err != nil {
+			return bytesWritten, err
+		}
+
+		bytesWritten += n
+
+		// Splice countdown only advances with payload
+		if ctx.hasSplice {
+			if ctx.spliceCountdown == 0 {
+				ctx.hasSplice = false
+			} else {
+				ctx.spliceCountdown--
+			}
+		}
+
+		payloadStart = false
+	}
+
+	if m.payloadEndFunc != nil && !payloadStart {
+		m.payloadEndFunc(d.PID)
+	}
+
+	if d.AdaptationField != nil {
+		d.AdaptationField.StuffingLength = 0
+	}
+
+	return bytesWritten, nil
+}
+
+// MuxerWriteInfo represents what has been written to the stream by a single write
+type MuxerWriteInfo struct {
+	BytesWritten   int
+	PacketsWritten int // including tables, PCR and null packets inserted by the muxer
+	TablesWritten  int // packets carrying tables among PacketsWritten
+	TablesOffset   int // offset in bytes of the first packet carrying tables, -1 if no tables were written
+}
+
+// maxRawOptionalHeaderLength returns the maximum length of a raw optional header fitting in the first packet of d,
+// whose adaptation field may be the one of d as well as a PCR and a splice countdown added by the muxer
+func (m *Muxer) maxRawOptionalHeaderLength(d *MuxerData, ctx *esContext) int {
+	max := MpegTsPacketSize - 1 - mpegTsPacketHeaderSize - pesHeaderLength
+	af := &PacketAdaptationField{}
+	if d.AdaptationField != nil {
+		*af = *d.AdaptationField
+	}
+	if m.isPCRPID(d.PID) && m.hasClock() {
+		af.HasPCR = true
+		af.PCR = &ClockReference{}
+	}
+	if ctx.hasSplice {
+		af = ctx.spliceAdaptationField(af)
+	}
+	if d.AdaptationField != nil || af.HasPCR || af.HasSplicingCountdown {
+		// one byte for adaptation field length field
+		max -= 1 + int(calcPacketAdaptationFieldLength(af))
+	}
+	return max
+}
+
+// pesPacketPayload returns the payload of the next packet of a PES, which behaves like writePESData
+// Only the first packet of a PES is copied into m.buf, other packets' payload being a window of the PES data
+// itself, which keeps large PES from being copied byte after byte
+func (m *Muxer) pesPacketPayload(h *PESHeader, payloadLeft []byte, isPayloadStart bool, bytesAvailable int) (payload []byte, payloadBytesWritten int, err error) {
+	headerBytesWritten := 0
+	m.buf.Reset()
+	if isPayloadStart {
+		if headerBytesWritten, err = writePESHeader(m.bufWriter, h, len(payloadLeft)); err != nil {
+			return
+		}
+	}
+
+	payloadBytesWritten = bytesAvailable - headerBytesWritten
+	if payloadBytesWritten > len(payloadLeft) {
+		payloadBytesWritten = len(payloadLeft)
+	}
+
+	if !isPayloadStart {
+		payload = payloadLeft[:payloadBytesWritten]
+		return
+	}
+	m.buf.Write(payloadLeft[:payloadBytesWritten])
+	payload = m.buf.Bytes()
+	return
+}
+
+// WriteDataByLabel writes d to the elementary stream added with AddStream under label
+// d.PID is set to the PID of the stream
+func (m *Muxer) WriteDataByLabel(label string, d *MuxerData) (int, error) {
+	pid, ok := m.labels[label]
+	if !ok {
+		return 0, ErrLabelNotFound
+	}
+	d.PID = pid
+	return m.WriteData(d)
+}
+
+// WriteDataWithInfo writes MuxerData to TS stream the same way WriteData does but returns details about
+// what has been written, which comes in handy to account for the overhead of the muxer
+func (m *Muxer) WriteDataWithInfo(d *MuxerData) (MuxerWriteInfo, error) {
+	start, tables := m.bytesWritten, m.stats.TablePackets
+	n, err := m.WriteData(d)
+
+	i := MuxerWriteInfo{
+		BytesWritten:   n,
+		PacketsWritten: n / m.packetSize,
+		TablesWritten:  int(m.stats.TablePackets - tables),
+		TablesOffset:   -1,
+	}
+	if i.TablesWritten > 0 {
+		i.TablesOffset = int(m.tablesOffset - start)
+	}
+	return i, err
+}
+
+// isPTSLate checks whether the PTS of a PES is behind the last PCR written on the PCR PID of its program
+// PCRs carried by the data itself are taken into account
+func (m *Muxer) isPTSLate(d *MuxerData) bool {
+	if d.PES == nil || d.PES.Header == nil || d.PES.Header.OptionalHeader == nil || d.PES.Header.OptionalHeader.PTS == nil {
+		return false
+	}
+
+	var pcr int64
+	if d.AdaptationField != nil && d.AdaptationField.HasPCR && d.AdaptationField.PCR != nil && m.bitrate <= 0 {
+		pcr = d.AdaptationField.PCR.Base*300 + d.AdaptationField.PCR.Extension
+	} else {
+		var ok bool
+		for _, p := range m.programs {
+			if p.hasElementaryStream(d.PID) {
+				if pcr, ok = m.lastPCRs[p.pmt.PCRPID]; ok {
+					break
+				}
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	// PTS is late when the PCR is less than half the wraparound ahead of it
+	delta := (pcr - d.PES.Header.OptionalHeader.PTS.Base*300 + pcrWrapAround) % pcrWrapAround
+	return delta > 0 && delta < pcrWrapAround/2
+}
+
+// WriteDemuxerData writes data returned by the Demuxer to TS stream, which makes remuxing easier
+// Elementary streams are registered automatically from PMT data, in the program with the same program number.
+// PES data is buffered until the PMT declaring its PID has been written, and other data is ignored since
+// the muxer generates its own tables.
+// Splice countdowns found in the adaptation field of the first packet of PES data are reproduced, see ScheduleSplice.
+func (m *Muxer) WriteDemuxerData(d *DemuxerData) (int, error) {
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+
+	switch {
+	case d.PMT != nil:
+		if err := m.addDemuxerPMT(d.PMT); err != nil {
+			return 0, err
+		}
+		return m.writePendingData()
+	case d.PES != nil:
+		if _, ok := m.esContexts[d.PID]; !ok {
+			m.pendingData = append(m.pendingData, d)
+			return 0, nil
+		}
+		return m.writeDemuxerPES(d)
+	}
+	return 0, nil
+}
+
+// addDemuxerPMT adds the elementary streams of a demuxed PMT that are not known yet
+func (m *Muxer) addDemuxerPMT(pmt *PMTData) error {
+	p, err := m.demuxerProgram(pmt)
+	if err != nil {
+		return err
+	}
+
+	for _, es := range pmt.ElementaryStreams {
+		if _, ok := m.esContexts[es.ElementaryPID]; ok {
+			continue
+		}
+		if err = p.AddElementaryStream(*es); err != nil {
+			return err
+		}
+	}
+
+	if p.pmt.PCRPID == 0 {
+		return p.SetPCRPID(pmt.PCRPID)
+	}
+	return nil
+}
+
+// demuxerProgram returns the program matching the program number of a demuxed PMT
+// The default program takes that program number as long as it's empty, so that no empty program is written
+func (m *Muxer) demuxerProgram(pmt *PMTData) (*MuxerProgram, error) {
+	if p := m.program(pmt.ProgramNumber); p != nil {
+		return p, nil
+	}
+
+	if p := m.claimDefaultProgram(pmt.ProgramNumber); p != nil {
+		return p, nil
+	}
+
+	p, err := m.AddProgram(pmt.ProgramNumber)
+	if err != nil {
+		return nil, err
+	}
+	p.pmt.ProgramDescriptors = pmt.ProgramDescriptors
+	return p, nil
+}
+
+// claimDefaultProgram renumbers the default program and returns it if it has no elementary stream yet, so that
+// callers setting up their own programs don't end up with an empty one
+func (m *Muxer) claimDefaultProgram(programNumber uint16) *MuxerProgram {
+	p := m.defaultProgram
+	if len(p.pmt.ElementaryStreams) > 0 || programNumber == 0 {
+		return nil
+	}
+	if op := m.program(programNumber); op != nil && op != p {
+		return nil
+	}
+
+	p.pmt.ProgramNumber = programNumber
+	p.pmtDirty = true
+	if p.service != nil {
+		p.service.ServiceID = programNumber
+		m.sdtDirty = true
+	}
+	m.pm.set(p.pmtPID, programNumber)
+	// invalidate pat cache
+	m.patDirty = true
+	return p
+}
+
+// writePendingData writes buffered PES data whose PID is now known
+func (m *Muxer) writePendingData() (int, error) {
+	bytesWritten := 0
+	var pending []*DemuxerData
+	for i, d := range m.pendingData {
+		if _, ok := m.esContexts[d.PID]; !ok {
+			pending = append(pending, d)
+			continue
+		}
+
+		n, err := m.writeDemuxerPES(d)
+		bytesWritten += n
+		if err != nil {
+			m.pendingData = append(pending, m.pendingData[i+1:]...)
+			return bytesWritten, err
+		}
+	}
+	m.pendingData = pending
+	return bytesWritten, nil
+}
+
+// writeDemuxerPES writes demuxed PES data, reproducing the splice countdown of its first packet
+// The splice is scheduled again on the first packet of each PES so that the countdown reaches 0 on the same packet as
+// in the demuxed stream, which stays true when the caller changes d.PID to remap PIDs
+func (m *Muxer) writeDemuxerPES(d *DemuxerData) (int, error) {
+	md := newMuxerDataFromDemuxerData(d)
+	if af := md.AdaptationField; af != nil && af.HasSplicingCountdown {
+		// Negative countdowns follow the splicing point
+		if af.SpliceCountdown >= 0 {
+			c := af.SpliceCountdown
+			if c > 127 {
+				c = 127
+			}
+			if err := m.ScheduleSplice(md.PID, c); err != nil {
+				return 0, err
+			}
+		}
+		af.HasSplicingCountdown = false
+		af.SpliceCountdown = 0
+	}
+	return m.WriteData(md)
+}
+
+// newMuxerDataFromDemuxerData builds muxer data out of demuxed PES data
+// The adaptation field of the first packet is copied since the muxer alters it
+func newMuxerDataFromDemuxerData(d *DemuxerData) *MuxerData {
+	md := &MuxerData{
+		PES: d.PES,
+		PID: d.PID,
+	}
+	if d.FirstPacket != nil && d.FirstPacket.AdaptationField != nil {
+		af := *d.FirstPacket.AdaptationField
+		af.StuffingLength = 0
+		md.AdaptationField = &af
+	}
+	return md
+}
+
+// WriteDiscontinuity writes a packet without payload on the elementary stream pid whose adaptation field has its
+// discontinuity indicator set, which signals decoders that the PCR and the continuity counter may jump, for
+// instance when splicing two recordings together
+// Per spec, the continuity counter doesn't increment on packets without payload: the packet repeats the continuity
+// counter of the last packet written on pid and the next packet carrying payload is numbered as if the
+// discontinuity packet had never been written.
+func (m *Muxer) WriteDiscontinuity(pid uint16) error {
+	if m.closed {
+		return ErrMuxerClosed
+	}
+
+	ctx, ok := m.esContexts[pid]
+	if !ok {
+		return ErrPIDNotFound
+	}
+
+	af := &PacketAdaptationField{DiscontinuityIndicator: true}
+	// one byte for adaptation field length field
+	af.StuffingLength = MpegTsPacketSize - 1 - mpegTsPacketHeaderSize - 1 - int(calcPacketAdaptationFieldLength(af))
+
+	_, err := m.writePacket(&Packet{
+		AdaptationField: af,
+		Header: &PacketHeader{
+			ContinuityCounter:  uint8(ctx.cc.last()),
+			HasAdaptationField: true,
+			PID:                pid,
+		},
+	})
+	return err
+}
+
+// Writes given packet to MPEG-TS stream
+// Stuffs with 0xffs if packet turns out to be shorter than target packet length
+func (m *Muxer) WritePacket(p *Packet) (int, error) {
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+	return m.writePacket(p)
+}
+
+// writePacket serializes a 188 bytes packet and writes it to the stream
+func (m *Muxer) writePacket(p *Packet) (int, error) {
+	bytesWritten := 0
+	hasPCR := p.Header.HasAdaptationField && p.AdaptationField != nil && p.AdaptationField.HasPCR
+
+	// In CBR mode, PCRs are given by the muxer clock
+	if hasPCR && m.bitrate > 0 {
+		n, err := m.writeDuePCRs()
+		bytesWritten += n
+		if err != nil {
+			return bytesWritten, err
+		}
+
+		if p.AdaptationField.PCR != nil {
+			m.startClock(p.AdaptationField.PCR.Base*300 + p.AdaptationField.PCR.Extension)
+		}
+		m.clockStarted = true
+
+		// Adaptation field may be the caller's one, which is left untouched
+		af := *p.AdaptationField
+		af.PCR = m.clockReference()
+		c := *p
+		c.AdaptationField = &af
+		p = &c
+	}
+	if hasPCR && m.hasClock() {
+		m.lastPCRs[p.Header.PID] = m.clock()
+	} else if hasPCR && p.AdaptationField.PCR != nil {
+		m.lastPCRs[p.Header.PID] = p.AdaptationField.PCR.Base*300 + p.AdaptationField.PCR.Extension
+	}
+
+	if err := m.serializePacket(p); err != nil {
+		return bytesWritten, err
+	}
+
+	if hasPCR {
+		m.lastPCR = p.AdaptationField.PCR
+	}
+
+	n, err := m.writeRawPackets(m.pktBuf.Bytes())
+	bytesWritten += n
+	return bytesWritten, err
+}
+
+// serializePacket serializes a 188 bytes packet into m.pktBuf the same way writePacket does
+// Payload and stuffing bytes are copied directly into the buffer instead of going through the bits writer
+// byte after byte, which matters for large PES
+func (m *Muxer) serializePacket(p *Packet) error {
+	m.pktBuf.Reset()
+	n, err := writePacketHeaderAndAdaptationField(m.pktBufWriter, p)
+	if err != nil {
+		return err
+	}
+
+	if err = checkPacketPayloadLength(p, MpegTsPacketSize-n); err != nil {
+		return err
+	}
+
+	if p.Header.HasPayload {
+		m.pktBuf.Write(p.Payload)
+		n += len(p.Payload)
+	}
+	m.pktBuf.Write(packetStuffing[:MpegTsPacketSize-n])
+	return nil
+}
+
+// writeRawPackets writes serialized 188 bytes packets to the stream
+// In CBR mode, PCR packets are inserted in between when due
+func (m *Muxer) writeRawPackets(bs []byte) (int, error) {
+	bytesWritten := 0
+	for len(bs) >= MpegTsPacketSize {
+		if m.hasClock() {
+			n, err := m.writeDuePCRs()
+			bytesWritten += n
+			if err != nil {
+				return bytesWritten, err
+			}
+		}
+
+		n, err := m.writeRawPacket(bs[:MpegTsPacketSize])
+		bytesWritten += n
+		if err != nil {
+			return bytesWritten, err
+		}
+		bs = bs[MpegTsPacketSize:]
+	}
+	return bytesWritten, nil
+}
+
+// writeRawPacket writes a serialized 188 bytes packet to the stream
+// The TP_extra_header is prepended when writing 192 bytes packets
+func (m *Muxer) writeRawPacket(bs []byte) (int, error) {
+	if m.pcrCheck {
+		if err := m.checkPCRBitrate(bs); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := m.writeRawPacketToWriter(bs)
+	m.bytesWritten += int64(n)
+	if err == nil {
+		pid := rawPacketPID(bs)
+		m.stats.Packets++
+		m.stats.PIDPackets[pid]++
+		if pid == PIDNull {
+			m.stats.NullPackets++
+		}
+	}
+	return n, err
+}
+
+// rawPacketPID returns the PID of a serialized packet
+func rawPacketPID(bs []byte) uint16 {
+	return uint16(bs[1]&0x1f)<<8 | uint16(bs[2])
+}
+
+func (m *Muxer) writeRawPacketToWriter(bs []byte) (int, error) {
+	if m.packetSize == MpegTsPacketSize {
+		return m.w.Write(bs)
+	}
+
+	// copy_permission_indicator is left to 0
+	if err := m.bitsWriter.Write(m.arrivalTimestamp()); err != nil {
+		return 0, err
+	}
+
+	n, err := m.w.Write(bs)
+	return m2tsHeaderSize + n, err
+}
+
+// arrivalTimestamp returns the 30 bits arrival timestamp of the next M2TS packet
+// Arrival timestamps wrap around but never go backwards, even though a PCR older than the last one is written
+func (m *Muxer) arrivalTimestamp() uint32 {
+	var t uint64
+	if m.arrivalTimeFunc != nil {
+		t = m.arrivalTimeFunc()
+	} else if m.hasClock() {
+		t = uint64(m.clock())
+	} else if m.lastPCR != nil {
+		t = uint64(m.lastPCR.Base)*300 + uint64(m.lastPCR.Extension)
+	} else {
+		// No clock is known yet
+		return m.lastArrivalTimestamp
+	}
+	ats := uint32(t & m2tsArrivalTimestampMask)
+
+	// A forward distance bigger than half the range means the clock went backwards
+	if m.hasArrivalTimestamp && (ats-m.lastArrivalTimestamp)&m2tsArrivalTimestampMask > m2tsArrivalTimestampMask/2 {
+		return m.lastArrivalTimestamp
+	}
+	m.hasArrivalTimestamp = true
+	m.lastArrivalTimestamp = ats
+	return ats
+}
+
+// BytesWritten returns the total number of bytes written to the stream so far, tables included
+// It can be used as the absolute offset of the next packet in the output
+func (m *Muxer) BytesWritten() int64 {
+	return m.bytesWritten
+}
+
+// MuxerStats represents cumulative counters of what the muxer has written
+type MuxerStats struct {
+	BytesWritten int64
+	NullPackets  int64
+	Packets      int64 // including null and table packets
+	PIDPackets   map[uint16]int64
+	TablePackets int64 // packets carrying PAT, PMTs, SDT, NIT, TSDT and sections written through WritePSISection
+}
+
+// Stats returns cumulative counters of what the muxer has written
+func (m *Muxer) Stats() MuxerStats {
+	s := m.stats
+	s.BytesWritten = m.bytesWritten
+	s.PIDPackets = make(map[uint16]int64, len(m.stats.PIDPackets))
+	for pid, n := range m.stats.PIDPackets {
+		s.PIDPackets[pid] = n
+	}
+	return s
+}
+
+// Close finalizes the stream: it writes a final table set if MuxerOptTablesOnClose is set and flushes the writer
+// if it has a Flush() error method (e.g. bufio.Writer).
+// The writer is closed only if it implements io.Closer, in which case its error is returned.
+// PES data passed to WriteDemuxerData whose PID has never been declared in a PMT can't be written, in which case
+// an error wrapping ErrPendingDataDropped is returned once the writer has been flushed and closed.
+// Total bytes written are available through BytesWritten. Any write after Close returns ErrMuxerClosed.
+func (m *Muxer) Close() error {
+	if m.closed {
+		return ErrMuxerClosed
+	}
+
+	if m.tablesOnClose {
+		if _, err := m.WriteTables(); err != nil {
+			return fmt.Errorf("astits: writing tables failed: %w", err)
+		}
+	}
+	m.closed = true
+
+	if f, ok := m.w.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("astits: flushing writer failed: %w", err)
+		}
+	}
+
+	if c, ok := m.w.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			return err
+		}
+	}
+
+	if n := len(m.pendingData); n > 0 {
+		m.pendingData = nil
+		return fmt.Errorf("astits: %d PES whose PID has not been declared in a PMT: %w", n, ErrPendingDataDropped)
+	}
+	return nil
+}
+
+// isPCRPID checks whether pid is the PCR PID of any program
+func (m *Muxer) isPCRPID(pid uint16) bool {
+	for _, p := range m.programs {
+		if p.pmt.PCRPID == pid {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Muxer) retransmitTables(force bool) (int, error) {
+	m.patPeriod.counter++
+	m.pmtPeriod.counter++
+
+	now, hasNow := m.lastPCRClock()
+	pat := force || m.patPeriod.due(m.tablesRetransmitPeriod, now, hasNow)
+	pmt := force || m.pmtPeriod.due(m.tablesRetransmitPeriod, now, hasNow)
+	if !pat && !pmt {
+		return 0, nil
+	}
+
+	// New table versions are written all at once
+	if m.tablesDirty() {
+		pat, pmt = true, true
+	} else if m.spreadTables && !force && pat && pmt {
+		// PMTs are still due on the next write since their period is not reset
+		pmt = false
+	}
+	return m.writeTables(pat, pmt)
+}
+
+// tablesDirty checks whether any table needs to be generated again
+func (m *Muxer) tablesDirty() bool {
+	if m.patDirty || m.sdtDirty || m.nitDirty || m.tsdtDirty {
+		return true
+	}
+	for _, p := range m.programs {
+		if p.pmtDirty {
+			return true
+		}
+	}
+	return false
+}
+
+// lastPCRClock returns the 27MHz clock of the last PCR written, if any
+func (m *Muxer) lastPCRClock() (int64, bool) {
+	if m.lastPCR == nil {
+		return 0, false
+	}
+	return m.lastPCR.Base*300 + m.lastPCR.Extension, true
+}
+
+// WriteTables writes the PAT, PMTs, SDT, NIT and TSDT
+func (m *Muxer) WriteTables() (int, error) {
+	return m.writeTables(true, true)
+}
+
+// writeTables writes the PAT, the SDT, the NIT and the TSDT if pat is true, and PMTs if pmt is true
+func (m *Muxer) writeTables(pat, pmt bool) (n int, err error) {
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+
+	// PCR PIDs are announced already unless tables change
+	announced := !m.tablesDirty()
+
+	// Cached tables are rolled back if they can't be written
+	ss := m.saveTables()
+	defer func() {
+		if err != nil {
+			m.restoreTables(ss)
+		}
+	}()
+
+	if m.patDirty {
+		if err = m.generatePAT(); err != nil {
+			return
+		}
+	}
+
+	for _, p := range m.programs {
+		if p.pmtDirty && !p.isUnused() {
+			if err = p.generatePMT(); err != nil {
+				return
+			}
+		}
+	}
+
+	if m.sdtDirty {
+		if err = m.generateSDT(); err != nil {
+			return
+		}
+	}
+
+	if m.nitDirty {
+		if err = m.generateNIT(); err != nil {
+			return
+		}
+	}
+
+	if m.tsdtDirty {
+		if err = m.generateTSDT(); err != nil {
+			return
+		}
+	}
+
+	// Tables are written all at once
+	buf := &bytes.Buffer{}
+	if pat {
+		buf.Write(m.patBytes.Bytes())
+	}
+	if pmt {
+		for _, p := range m.programs {
+			if !p.isUnused() {
+				buf.Write(p.pmtBytes.Bytes())
+			}
+		}
+	}
+	if pat {
+		buf.Write(m.sdtBytes.Bytes())
+		buf.Write(m.nitBytes.Bytes())
+		buf.Write(m.tsdtBytes.Bytes())
+	}
+
+	// Due PCRs are written after new tables so that they're never written on a PCR PID the PMT doesn't announce yet
+	// Otherwise PCRs that would be late because of the tables are written first
+	bs := buf.Bytes()
+	if announced && m.hasClock() {
+		var nn int
+		nn, err = m.writeDuePCRsAhead(len(bs)/MpegTsPacketSize + 1)
+		n += nn
+		if err != nil {
+			return
+		}
+	}
+	m.tablesOffset = m.bytesWritten
+	for len(bs) >= MpegTsPacketSize {
+		// Cached tables are retransmitted, CCs are therefore set when writing
+		pid := rawPacketPID(bs)
+		bs[3] = bs[3]&0xf0 | uint8(m.tableCC(pid).get())
+
+		var nn int
+		nn, err = m.writeRawPacket(bs[:MpegTsPacketSize])
+		n += nn
+		if err != nil {
+			return
+		}
+		m.stats.TablePackets++
+		bs = bs[MpegTsPacketSize:]
 	}
+
+	now, hasNow := m.lastPCRClock()
+	if pat {
+		m.patPeriod.reset(now, hasNow)
+	}
+	if pmt {
+		m.pmtPeriod.reset(now, hasNow)
+	}
+	return
+}
+
+// tableState represents the state of a cached table
+type tableState struct {
+	bytes   []byte
+	dirty   bool
+	version wrappingCounter
+}
+
+// saveTables returns the state of cached tables, PAT, SDT, NIT and TSDT first and then PMTs
+func (m *Muxer) saveTables() []tableState {
+	ss := []tableState{{
+		bytes:   append([]byte(nil), m.patBytes.Bytes()...),
+		dirty:   m.patDirty,
+		version: m.patVersion,
+	}, {
+		bytes:   append([]byte(nil), m.sdtBytes.Bytes()...),
+		dirty:   m.sdtDirty,
+		version: m.sdtVersion,
+	}, {
+		bytes:   append([]byte(nil), m.nitBytes.Bytes()...),
+		dirty:   m.nitDirty,
+		version: m.nitVersion,
+	}, {
+		bytes:   append([]byte(nil), m.tsdtBytes.Bytes()...),
+		dirty:   m.tsdtDirty,
+		version: m.tsdtVersion,
+	}}
+	for _, p := range m.programs {
+		ss = append(ss, tableState{
+			bytes:   append([]byte(nil), p.pmtBytes.Bytes()...),
+			dirty:   p.pmtDirty,
+			version: p.pmtVersion,
+		})
+	}
+	return ss
+}
+
+// restoreTables restores the state of cached tables returned by saveTables
+func (m *Muxer) restoreTables(ss []tableState) {
+	m.patBytes.Reset()
+	m.patBytes.Write(ss[0].bytes)
+	m.patDirty = ss[0].dirty
+	m.patVersion = ss[0].version
+	m.sdtBytes.Reset()
+	m.sdtBytes.Write(ss[1].bytes)
+	m.sdtDirty = ss[1].dirty
+	m.sdtVersion = ss[1].version
+	m.nitBytes.Reset()
+	m.nitBytes.Write(ss[2].bytes)
+	m.nitDirty = ss[2].dirty
+	m.nitVersion = ss[2].version
+	m.tsdtBytes.Reset()
+	m.tsdtBytes.Write(ss[3].bytes)
+	m.tsdtDirty = ss[3].dirty
+	m.tsdtVersion = ss[3].version
+	for i, p := range m.programs {
+		p.pmtBytes.Reset()
+		p.pmtBytes.Write(ss[i+4].bytes)
+		p.pmtDirty = ss[i+4].dirty
+		p.pmtVersion = ss[i+4].version
+	}
+}
+
+func (m *Muxer) generatePAT() error {
+	d := m.pm.toPATData()
+
+	// Default program is left out when unused
+	if m.defaultProgram.isUnused() {
+		ps := d.Programs[:0]
+		for _, p := range d.Programs {
+			if p.ProgramMapID != m.defaultProgram.pmtPID {
+				ps = append(ps, p)
+			}
+		}
+		d.Programs = ps
+	}
+
+	// Program number 0 is reserved to NIT
+	if m.nit != nil {
+		d.Programs = append([]*PATProgram{{ProgramMapID: PIDNIT}}, d.Programs...)
+	}
+
+	// Version is only updated on success
+	versionCounter := m.patVersion
+	version := uint8(versionCounter.get())
+
+	// PAT is split into several sections when programs don't fit in a single one
+	var sections []*PSISection
+	for first := true; first || len(d.Programs) > 0; first = false {
+		sd := &PATData{TransportStreamID: d.TransportStreamID, Programs: d.Programs}
+		if len(sd.Programs) > patMaxProgramsPerSection {
+			sd.Programs = sd.Programs[:patMaxProgramsPerSection]
+		}
+		d.Programs = d.Programs[len(sd.Programs):]
+
+		sections = append(sections, &PSISection{
+			Header: &PSISectionHeader{
+				SectionLength:          calcPATSectionLength(sd),
+				SectionSyntaxIndicator: true,
+				TableID:                PSITableIDPAT,
+			},
+			Syntax: &PSISectionSyntax{
+				Data: &PSISectionSyntaxData{PAT: sd},
+				Header: &PSISectionSyntaxHeader{
+					CurrentNextIndicator: true,
+					SectionNumber:        uint8(len(sections)),
+					TableIDExtension:     sd.TransportStreamID,
+					VersionNumber:        version,
+				},
+			},
+		})
+	}
+	for _, s := range sections {
+		s.Syntax.Header.LastSectionNumber = uint8(len(sections) - 1)
+	}
+
+	m.buf.Reset()
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.buf})
+	if _, err := writePSIData(w, &PSIData{Sections: sections}); err != nil {
+		return err
+	}
+
+	// Cached PAT is only replaced on success
+	buf := &bytes.Buffer{}
+	wPacket := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	if err := writePSIPackets(wPacket, m.patPID, m.buf.Bytes(), newTableCC()); err != nil {
+		return err
+	}
+
+	m.patBytes.Reset()
+	m.patBytes.Write(buf.Bytes())
+	m.patDirty = false
+	m.patVersion = versionCounter
+	return nil
+}
+
+func (p *MuxerProgram) generatePMT() error {
+	m := p.m
+	if !p.hasPCRPID() {
+		return ErrPCRPIDInvalid
+	}
+
+	// PMT must be a single section, which can however span several packets
 	section := PSISection{
 		Header: &PSISectionHeader{
-			SectionLength:          calcPMTSectionLength(&m.pmt),
+			SectionLength:          calcPMTSectionLength(&p.pmt),
 			SectionSyntaxIndicator: true,
 			TableID:                PSITableIDPMT,
 		},
-		Syntax: syntax,
+		Syntax: &PSISectionSyntax{
+			Data: &PSISectionSyntaxData{PMT: &p.pmt},
+			Header: &PSISectionSyntaxHeader{
+				CurrentNextIndicator: true,
+				TableIDExtension:     p.pmt.ProgramNumber,
+			},
+		},
+	}
+	if err := m.generateTable(p.pmtPID, &section, &p.pmtVersion, &p.pmtBytes); err != nil {
+		return err
+	}
+	p.pmtDirty = false
+
+	// Warn when the PMT starts spanning several packets
+	packets := p.pmtBytes.Len() / MpegTsPacketSize
+	if packets > 1 && p.pmtPackets <= 1 && m.pmtSizeFunc != nil {
+		m.pmtSizeFunc(p.pmt.ProgramNumber, int(calcPSISectionLength(&section)))
+	}
+	p.pmtPackets = packets
+	return nil
+}
+
+func (m *Muxer) generateSDT() error {
+	// SDT shares the transport stream ID of the PAT
+	d := &SDTData{
+		OriginalNetworkID: m.originalNetworkID,
+		TransportStreamID: m.pm.toPATData().TransportStreamID,
+	}
+	for _, p := range m.programs {
+		if p.service != nil && !p.isUnused() {
+			d.Services = append(d.Services, p.service)
+		}
+	}
+
+	// No SDT is written when no service is described
+	if len(d.Services) == 0 {
+		m.sdtBytes.Reset()
+		m.sdtDirty = false
+		return nil
+	}
+
+	section := PSISection{
+		Header: &PSISectionHeader{
+			SectionLength:          calcSDTSectionLength(d),
+			SectionSyntaxIndicator: true,
+			PrivateBit:             true,
+			TableID:                PSITableIDSDTVariant1,
+		},
+		Syntax: &PSISectionSyntax{
+			Data: &PSISectionSyntaxData{SDT: d},
+			Header: &PSISectionSyntaxHeader{
+				CurrentNextIndicator: true,
+				TableIDExtension:     d.TransportStreamID,
+			},
+		},
+	}
+	if err := m.generateTable(PIDSDT, &section, &m.sdtVersion, &m.sdtBytes); err != nil {
+		return err
+	}
+	m.sdtDirty = false
+	return nil
+}
+
+func (m *Muxer) generateNIT() error {
+	// No NIT is written when no network is described
+	if m.nit == nil {
+		m.nitBytes.Reset()
+		m.nitDirty = false
+		return nil
+	}
+
+	// Transport stream described by the muxer gets the muxer's original network id
+	nit := *m.nit
+	nit.TransportStreams = make([]*NITDataTransportStream, 0, len(m.nit.TransportStreams))
+	transportStreamID := m.pm.toPATData().TransportStreamID
+	for _, ts := range m.nit.TransportStreams {
+		if m.hasOriginalNetworkID && ts.TransportStreamID == transportStreamID {
+			c := *ts
+			c.OriginalNetworkID = m.originalNetworkID
+			ts = &c
+		}
+		nit.TransportStreams = append(nit.TransportStreams, ts)
+	}
+
+	section := PSISection{
+		Header: &PSISectionHeader{
+			SectionLength:          calcNITSectionLength(&nit),
+			SectionSyntaxIndicator: true,
+			PrivateBit:             true,
+			TableID:                PSITableIDNITVariant1,
+		},
+		Syntax: &PSISectionSyntax{
+			Data: &PSISectionSyntaxData{NIT: &nit},
+			Header: &PSISectionSyntaxHeader{
+				CurrentNextIndicator: true,
+				TableIDExtension:     nit.NetworkID,
+			},
+		},
+	}
+	if err := m.generateTable(PIDNIT, &section, &m.nitVersion, &m.nitBytes); err != nil {
+		return err
+	}
+	m.nitDirty = false
+	return nil
+}
+
+func (m *Muxer) generateTSDT() error {
+	// No TSDT is written when there are no descriptors
+	if m.tsdt == nil {
+		m.tsdtBytes.Reset()
+		m.tsdtDirty = false
+		return nil
+	}
+
+	section := PSISection{
+		Header: &PSISectionHeader{
+			SectionLength:          calcTSDTSectionLength(m.tsdt),
+			SectionSyntaxIndicator: true,
+			TableID:                PSITableIDTSDT,
+		},
+		Syntax: &PSISectionSyntax{
+			Data: &PSISectionSyntaxData{TSDT: m.tsdt},
+			Header: &PSISectionSyntaxHeader{
+				CurrentNextIndicator: true,
+				TableIDExtension:     0xffff, // reserved
+			},
+		},
+	}
+	if err := m.generateTable(PIDTSDT, &section, &m.tsdtVersion, &m.tsdtBytes); err != nil {
+		return err
 	}
-	psiData := PSIData{
-		Sections: []*PSISection{&section},
+	m.tsdtDirty = false
+	return nil
+}
+
+// generateTable writes a single section table to cache as packets on pid, the section version being given by version
+// Both version and cache are only updated on success
+func (m *Muxer) generateTable(pid uint16, section *PSISection, version *wrappingCounter, cache *bytes.Buffer) error {
+	if calcPSISectionLength(section) > psiSectionMaxLength {
+		return ErrPSISectionTooLong
 	}
 
+	// Version is only updated on success
+	versionCounter := *version
+	section.Syntax.Header.VersionNumber = uint8(versionCounter.get())
+
 	m.buf.Reset()
 	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.buf})
-	if _, err := writePSIData(w, &psiData); err != nil {
+	if _, err := writePSIData(w, &PSIData{Sections: []*PSISection{section}}); err != nil {
 		return err
 	}
 
-	m.pmtBytes.Reset()
-	wPacket := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.pmtBytes})
+	// Cached table is only replaced on success
+	buf := &bytes.Buffer{}
+	wPacket := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	if err := writePSIPackets(wPacket, pid, m.buf.Bytes(), newTableCC()); err != nil {
+		return err
+	}
 
-	pkt := Packet{
-		Header: &PacketHeader{
-			HasPayload:                true,
-			PayloadUnitStartIndicator: true,
-			PID:                       pmtStartPID, // FIXME multiple programs support
+	cache.Reset()
+	cache.Write(buf.Bytes())
+	*version = versionCounter
+	return nil
+}
+
+// newTableCC creates the continuity counter of a table PID
+func newTableCC() *wrappingCounter {
+	cc := newWrappingCounter(0b1111) // CC is 4 bits
+	return &cc
+}
+
+// tableCC returns the continuity counter of tables written on pid
+// It's shared by all tables written on the same PID so that CCs keep increasing across retransmits, and is the one of
+// the elementary stream for sections carried by elementary streams such as SCTE-35 ones
+func (m *Muxer) tableCC(pid uint16) *wrappingCounter {
+	if ctx, ok := m.esContexts[pid]; ok {
+		return &ctx.cc
+	}
+	cc, ok := m.tableCCs[pid]
+	if !ok {
+		cc = newTableCC()
+		m.tableCCs[pid] = cc
+	}
+	return cc
+}
+
+// WritePSISection writes a single PSI section on the given PID, which is useful for tables the muxer doesn't
+// generate such as private or ATSC tables. Table data the library can't write is provided in s.Syntax.Data.Raw,
+// in which case s.Header.SectionSyntaxIndicator decides whether a syntax header and a CRC32 are written.
+// Section length is computed automatically. The PID can't be used by an elementary stream or a PMT.
+func (m *Muxer) WritePSISection(pid uint16, s *PSISection) (int, error) {
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+	if pid == PIDPAT || pid == m.patPID || m.pm.exists(pid) || m.esContexts[pid] != nil {
+		return 0, ErrPIDAlreadyExists
+	}
+
+	h := *s.Header
+	s = &PSISection{Header: &h, Syntax: s.Syntax}
+	h.SectionLength = calcPSISectionLength(s)
+	if h.SectionLength > psiSectionMaxLength {
+		return 0, ErrPSISectionTooLong
+	}
+
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	if _, err := writePSIData(w, &PSIData{Sections: []*PSISection{s}}); err != nil {
+		return 0, err
+	}
+	return m.writePSI(pid, buf.Bytes())
+}
+
+// WriteRawPSI writes a PSI section starting with tableID followed by section on the given PID, which comes in handy
+// for proprietary tables the library doesn't model. The PID can't be used by an elementary stream or a PMT.
+// If computeLengthAndCRC is false, section is written as is and must start with the section syntax indicator and
+// the section length, and end with the CRC32 if any. Otherwise section is the data following the section length,
+// the section syntax indicator and private bits are set, and the section length and a CRC32 are computed.
+func (m *Muxer) WriteRawPSI(pid uint16, tableID uint8, section []byte, computeLengthAndCRC bool) (int, error) {
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+	if pid == PIDPAT || pid == m.patPID || m.pm.exists(pid) || m.esContexts[pid] != nil {
+		return 0, ErrPIDAlreadyExists
+	}
+
+	// Pointer field and table ID
+	bs := []byte{0x00, tableID}
+	if computeLengthAndCRC {
+		sectionLength := len(section) + 4 // CRC32
+		if sectionLength > psiSectionMaxLength {
+			return 0, ErrPSISectionTooLong
+		}
+		// section_syntax_indicator, private_indicator and 2 reserved bits
+		bs = append(bs, 0xf0|uint8(sectionLength>>8), uint8(sectionLength))
+		bs = append(bs, section...)
+		crc32 := computeCRC32(bs[1:])
+		bs = append(bs, uint8(crc32>>24), uint8(crc32>>16), uint8(crc32>>8), uint8(crc32))
+	} else {
+		bs = append(bs, section...)
+	}
+	return m.writePSI(pid, bs)
+}
+
+// writePSI writes PSI data, pointer field included, as table packets on the given PID
+func (m *Muxer) writePSI(pid uint16, psi []byte) (int, error) {
+	pkts := &bytes.Buffer{}
+	wPacket := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: pkts})
+	if err := writePSIPackets(wPacket, pid, psi, m.tableCC(pid)); err != nil {
+		return 0, err
+	}
+
+	n, err := m.writeRawPackets(pkts.Bytes())
+	if err == nil {
+		m.stats.TablePackets += int64(pkts.Len() / MpegTsPacketSize)
+	}
+	return n, err
+}
+
+// WriteTDT writes a time and date table on the TDT PID
+func (m *Muxer) WriteTDT(utc time.Time) (int, error) {
+	return m.WritePSISection(PIDTDT, &PSISection{
+		Header: &PSISectionHeader{
+			PrivateBit: true, // reserved_future_use
+			TableID:    PSITableIDTDT,
+		},
+		Syntax: &PSISectionSyntax{Data: &PSISectionSyntaxData{TDT: &TDTData{UTCTime: utc.UTC()}}},
+	})
+}
+
+// WriteTOT writes a time offset table on the TDT PID
+// Unlike the TDT, the TOT ends with a CRC32
+func (m *Muxer) WriteTOT(utc time.Time, descriptors []*Descriptor) (int, error) {
+	return m.WritePSISection(PIDTDT, &PSISection{
+		Header: &PSISectionHeader{
+			PrivateBit: true, // reserved_future_use
+			TableID:    PSITableIDTOT,
 		},
-		Payload: m.buf.Bytes(),
+		Syntax: &PSISectionSyntax{Data: &PSISectionSyntaxData{TOT: &TOTData{
+			Descriptors: descriptors,
+			UTCTime:     utc.UTC(),
+		}}},
+	})
+}
+
+// WriteSCTE35 writes a SCTE-35 splice info section on the given PID, see MuxerProgram.WriteSCTE35
+// The section is written on the program the PID belongs to if it's already registered, and on the default program
+// otherwise. Muxers with several programs should use MuxerProgram.WriteSCTE35 instead.
+func (m *Muxer) WriteSCTE35(pid uint16, section *SCTE35Data) (int, error) {
+	for _, p := range m.programs {
+		if p.hasElementaryStream(pid) {
+			return p.WriteSCTE35(pid, section)
+		}
 	}
-	if _, err := writePacket(wPacket, &pkt, m.packetSize); err != nil {
-		// FIXME save old PMT and rollback to it here maybe?
-		return err
+	return m.defaultProgram.WriteSCTE35(pid, section)
+}
+
+// WriteSCTE35 writes a SCTE-35 splice info section on the given PID, whose CRC32 is computed automatically
+// Unless it's already the case, the PID is added to the program as an elementary stream of type StreamTypeSCTE35
+// and tables are written first so that the PMT announces it.
+// Encrypted sections are not supported.
+func (p *MuxerProgram) WriteSCTE35(pid uint16, section *SCTE35Data) (n int, err error) {
+	m := p.m
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+	if pid == PIDPAT || pid == m.patPID || m.pm.exists(pid) {
+		return 0, ErrPIDAlreadyExists
+	}
+
+	ctx, ok := m.esContexts[pid]
+	if ok && (ctx.es.StreamType != StreamTypeSCTE35 || !p.hasElementaryStream(pid)) {
+		return 0, ErrPIDAlreadyExists
+	}
+
+	// Splice command is checked before computing the section length
+	if err = checkSCTE35SpliceCommand(section); err != nil {
+		return
+	}
+
+	s := &PSISection{
+		Header: &PSISectionHeader{TableID: PSITableIDSCTE35},
+		Syntax: &PSISectionSyntax{Data: &PSISectionSyntaxData{SCTE35: section}},
+	}
+	s.Header.SectionLength = calcPSISectionLength(s)
+	if s.Header.SectionLength > psiSectionMaxLength {
+		return 0, ErrPSISectionTooLong
+	}
+
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	if _, err = writePSIData(w, &PSIData{Sections: []*PSISection{s}}); err != nil {
+		return
 	}
 
+	// Register the PID
+	if !ok {
+		if err = p.AddElementaryStream(PMTElementaryStream{
+			ElementaryPID: pid,
+			StreamType:    StreamTypeSCTE35,
+		}); err != nil {
+			return
+		}
+	}
+
+	// PMT announces the PID before the section is written
+	if m.tablesDirty() {
+		if n, err = m.writeTables(true, true); err != nil {
+			return
+		}
+	}
+
+	var nn int
+	nn, err = m.writePSI(pid, buf.Bytes())
+	n += nn
+	return
+}
+
+// WriteATSCSTT writes an ATSC system time table on the ATSC base PID
+func (m *Muxer) WriteATSCSTT(d *ATSCSTTData) (int, error) {
+	return m.writeATSCSection(PSITableIDSTT, 0, 0, func(w *astikit.BitsWriter) error {
+		return writeATSCSTTSection(w, d)
+	})
+}
+
+// WriteATSCMGT writes an ATSC master guide table on the ATSC base PID
+func (m *Muxer) WriteATSCMGT(d *ATSCMGTData) (int, error) {
+	return m.writeATSCSection(PSITableIDMGT, 0, d.VersionNumber, func(w *astikit.BitsWriter) error {
+		return writeATSCMGTSection(w, d)
+	})
+}
+
+// WriteATSCTVCT writes an ATSC terrestrial virtual channel table on the ATSC base PID
+func (m *Muxer) WriteATSCTVCT(d *ATSCTVCTData) (int, error) {
+	return m.writeATSCSection(PSITableIDTVCT, d.TransportStreamID, d.VersionNumber, func(w *astikit.BitsWriter) error {
+		return writeATSCTVCTSection(w, d)
+	})
+}
+
+// writeATSCSection writes a single ATSC PSIP section whose data is written by fn
+func (m *Muxer) writeATSCSection(tableID PSITableID, tableIDExtension uint16, versionNumber uint8, fn func(w *astikit.BitsWriter) error) (int, error) {
+	buf := &bytes.Buffer{}
+	if err := fn(astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})); err != nil {
+		return 0, fmt.Errorf("astits: writing %#x section failed: %w", tableID, err)
+	}
+
+	return m.WritePSISection(PIDATSCBase, &PSISection{
+		Header: &PSISectionHeader{
+			PrivateBit:             true,
+			SectionSyntaxIndicator: true,
+			TableID:                tableID,
+		},
+		Syntax: &PSISectionSyntax{
+			Data: &PSISectionSyntaxData{Raw: buf.Bytes()},
+			Header: &PSISectionSyntaxHeader{
+				CurrentNextIndicator: true,
+				TableIDExtension:     tableIDExtension,
+				VersionNumber:        versionNumber,
+			},
+		},
+	})
+}
+
+// writePSIPackets writes PSI data as 188 bytes packets on the given PID
+// Data longer than a packet is split across continuation packets, only the first one having
+// the payload unit start indicator set
+func writePSIPackets(w *astikit.BitsWriter, pid uint16, psi []byte, cc *wrappingCounter) error {
+	for first := true; first || len(psi) > 0; first = false {
+		n := MpegTsPacketSize - 1 - mpegTsPacketHeaderSize // sync byte + header
+		if n > len(psi) {
+			n = len(psi)
+		}
+
+		pkt := Packet{
+			Header: &PacketHeader{
+				ContinuityCounter:         uint8(cc.get()),
+				HasPayload:                true,
+				PayloadUnitStartIndicator: first,
+				PID:                       pid,
+			},
+			Payload: psi[:n],
+		}
+		if _, err := writePacket(w, &pkt, MpegTsPacketSize); err != nil {
+			return err
+		}
+		psi = psi[n:]
+	}
 	return nil
 }
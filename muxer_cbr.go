@@ -0,0 +1,456 @@
+package astits
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/asticode/go-astikit"
+)
+
+const (
+	clockFrequency = 27000000 // PCR clock is 27MHz
+	// Delay between the arrival of a PES packet and its decoding time in CBR mode
+	cbrDelay = clockFrequency / 2
+	// PCR base is 33 bits
+	pcrBaseMask = 0x1ffffffff
+	// Tables retransmission period in signal only mode
+	signalOnlyTablesPeriod = clockFrequency / 10
+	// How far ahead of the wall clock the muxer can go in signal only mode
+	signalOnlyMaxAdvance = 10 * time.Millisecond
+	// Delay between null packets written by WriteIdle when the clock is given by a clock func
+	idleClockFuncPeriod = 10 * time.Millisecond
+)
+
+var (
+	ErrBitrateNotSet          = errors.New("astits: bitrate not set")
+	ErrPadByteMultipleInvalid = errors.New("astits: pad byte multiple invalid")
+)
+
+// MuxerOptConstantBitrate makes the muxer write a constant bitrate stream, bitrate being expressed in bits per second
+// The stream is padded with null packets so that data is written at the pace given by the PCRs written by the caller
+// or, if there are none, shortly before the decoding time of PES packets.
+// PCRs written by the caller are replaced by the muxer clock so that they match the bitrate.
+func MuxerOptConstantBitrate(bitrate int) func(*Muxer) {
+	return func(m *Muxer) {
+		m.bitrate = int64(bitrate)
+	}
+}
+
+// MuxerOptCBR makes the muxer write a constant bitrate stream with PCRs inserted on the PCR PID of each program
+// at least every pcrPeriod. See MuxerOptConstantBitrate.
+func MuxerOptCBR(bitrate int, pcrPeriod time.Duration) func(*Muxer) {
+	return func(m *Muxer) {
+		MuxerOptConstantBitrate(bitrate)(m)
+		MuxerOptPCRPeriod(pcrPeriod)(m)
+	}
+}
+
+// MuxerOptPCRPeriod makes the muxer insert PCR only packets on the PCR PID of each program so that PCRs
+// are at most pcrPeriod apart. PCRs are given by the muxer clock, which requires either a constant bitrate
+// or a clock func.
+func MuxerOptPCRPeriod(pcrPeriod time.Duration) func(*Muxer) {
+	return func(m *Muxer) {
+		m.pcrPeriod = int64(pcrPeriod) * clockFrequency / int64(time.Second)
+	}
+}
+
+// MuxerOptPESPCRPeriod makes the muxer write PCRs in the adaptation field of the packets of PES written on the
+// PCR PID of a program, so that PCRs are at most pcrPeriod apart even within a PES spanning many packets.
+// PCRs are given by the muxer clock, which requires either a constant bitrate or a clock func.
+func MuxerOptPESPCRPeriod(pcrPeriod time.Duration) func(*Muxer) {
+	return func(m *Muxer) {
+		m.pesPCRPeriod = int64(pcrPeriod) * clockFrequency / int64(time.Second)
+	}
+}
+
+// MuxerOptPCROnEveryPacket makes the muxer write a PCR in the adaptation field of every packet of PES written on the
+// PCR PID of a program, which is the simplest compliant way of carrying PCRs when the PCR PID has bitrate to spare.
+// PCRs are given by the muxer clock, which requires either a constant bitrate or a clock func.
+func MuxerOptPCROnEveryPacket() func(*Muxer) {
+	return func(m *Muxer) {
+		m.pcrOnEveryPacket = true
+	}
+}
+
+// MuxerOptClockFunc sets the 27MHz clock giving the value of PCRs inserted by the muxer
+// It's only used when no constant bitrate is set, since the clock is then derived from the bytes written
+func MuxerOptClockFunc(fn func() uint64) func(*Muxer) {
+	return func(m *Muxer) {
+		m.clockFunc = fn
+	}
+}
+
+// hasClock checks whether the muxer has a clock
+func (m *Muxer) hasClock() bool {
+	return m.clockFunc != nil || m.bitrate > 0
+}
+
+// clock returns the 27MHz muxer clock at the current position in the stream
+func (m *Muxer) clock() int64 {
+	if m.bitrate <= 0 {
+		return int64(m.clockFunc())
+	}
+	// Whole seconds and the remainder are computed separately so that the clock doesn't overflow on long streams
+	bits := m.bytesWritten * 8
+	return m.clockStart + bits/m.bitrate*clockFrequency + bits%m.bitrate*clockFrequency/m.bitrate
+}
+
+// packetDuration returns the 27MHz duration of a packet, or 0 if it's unknown
+func (m *Muxer) packetDuration() int64 {
+	if m.bitrate <= 0 {
+		return 0
+	}
+	return int64(m.packetSize) * 8 * clockFrequency / m.bitrate
+}
+
+// startClock makes sure the muxer clock reaches t at the current position in the stream
+// It only has an effect until the first PCR has been written
+func (m *Muxer) startClock(t int64) {
+	if m.clockStarted {
+		return
+	}
+	m.clockStarted = true
+	m.clockStart = 0
+	if t > m.clock() {
+		m.clockStart = t - m.clock()
+	}
+}
+
+// waitForData pads the stream with null packets until data can be written
+func (m *Muxer) waitForData(d *MuxerData) (int, error) {
+	var t int64
+	if d.AdaptationField != nil && d.AdaptationField.HasPCR && d.AdaptationField.PCR != nil {
+		// PCRs written by the caller give the pace. The first one starts the clock once written.
+		if !m.clockStarted {
+			return 0, nil
+		}
+		t = d.AdaptationField.PCR.Base*300 + d.AdaptationField.PCR.Extension
+	} else if cr := pesDecodingTime(d.PES.Header); cr != nil {
+		t = cr.Base*300 - cbrDelay
+		m.startClock(t)
+	} else {
+		return 0, nil
+	}
+
+	bytesWritten := 0
+	for isClockBefore(m.clock(), t) {
+		n, err := m.writeNullPackets(1)
+		bytesWritten += n
+		if err != nil {
+			return bytesWritten, err
+		}
+	}
+	return bytesWritten, nil
+}
+
+// isClockBefore checks whether the 27MHz clock c is before t, both being compared modulo the PCR wrap around so that
+// targets given by PTS, DTS and PCRs that have wrapped are still reached. t is considered after c when it's less than
+// half the wrap around ahead.
+func isClockBefore(c, t int64) bool {
+	d := ((t-c)%pcrWrapAround + pcrWrapAround) % pcrWrapAround
+	return d > 0 && d < pcrWrapAround/2
+}
+
+// pesDecodingTime returns the DTS of a PES packet, or its PTS if there's no DTS
+func pesDecodingTime(h *PESHeader) *ClockReference {
+	if h == nil || h.OptionalHeader == nil {
+		return nil
+	}
+	switch h.OptionalHeader.PTSDTSIndicator {
+	case PTSDTSIndicatorBothPresent:
+		return h.OptionalHeader.DTS
+	case PTSDTSIndicatorOnlyPTS:
+		return h.OptionalHeader.PTS
+	}
+	return nil
+}
+
+// WriteNullPackets writes n null packets to the stream, which comes in handy to pad the stream manually
+// Null packets are written on PID 0x1fff, without adaptation field and with a payload of 0xff bytes
+func (m *Muxer) WriteNullPackets(n int) (int, error) {
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+	return m.writeNullPackets(n)
+}
+
+// PadTo writes null packets until the number of bytes written is a multiple of byteMultiple, which comes in handy
+// to concatenate streams on specific byte boundaries
+func (m *Muxer) PadTo(byteMultiple int) (int, error) {
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+	if byteMultiple <= 0 {
+		return 0, ErrPadByteMultipleInvalid
+	}
+
+	// PCR packets may be written in between in CBR mode
+	bytesWritten := 0
+	for m.bytesWritten%int64(byteMultiple) != 0 {
+		n, err := m.writeNullPackets(1)
+		bytesWritten += n
+		if err != nil {
+			return bytesWritten, err
+		}
+	}
+	return bytesWritten, nil
+}
+
+// writeNullPackets writes n null packets to the stream
+func (m *Muxer) writeNullPackets(n int) (int, error) {
+	if m.nullPacket == nil {
+		buf := &bytes.Buffer{}
+		w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+		if _, err := writePacket(w, &Packet{Header: &PacketHeader{HasPayload: true, PID: PIDNull}}, MpegTsPacketSize); err != nil {
+			return 0, err
+		}
+		m.nullPacket = buf.Bytes()
+	}
+
+	bytesWritten := 0
+	for i := 0; i < n; i++ {
+		nn, err := m.writeRawPackets(m.nullPacket)
+		bytesWritten += nn
+		if err != nil {
+			return bytesWritten, err
+		}
+	}
+	return bytesWritten, nil
+}
+
+// writeDuePCRs writes a PCR packet for each program whose last PCR is older than the PCR period
+func (m *Muxer) writeDuePCRs() (int, error) {
+	return m.writeDuePCRsAhead(1)
+}
+
+// writeDuePCRsAhead writes a PCR packet for each program whose PCR would be older than the PCR period after
+// the given number of packets
+func (m *Muxer) writeDuePCRsAhead(packets int) (int, error) {
+	if m.pcrPeriod <= 0 {
+		return 0, nil
+	}
+
+	bytesWritten := 0
+	for _, p := range m.programs {
+		if _, ok := m.esContexts[p.pmt.PCRPID]; !ok {
+			continue
+		}
+		// PCR must be written now if it can't wait for the next packets
+		now := m.clock()
+		if last, ok := m.lastPCRs[p.pmt.PCRPID]; ok && now+int64(packets)*m.packetDuration()-last <= m.pcrPeriod {
+			continue
+		}
+
+		n, err := m.writePCRPacket(p.pmt.PCRPID, newClockReferenceFromPCR(now))
+		bytesWritten += n
+		if err != nil {
+			return bytesWritten, err
+		}
+	}
+	return bytesWritten, nil
+}
+
+// isPESPCRDue checks whether the next packet of a PES written on pid must carry a PCR
+func (m *Muxer) isPESPCRDue(pid uint16) bool {
+	if m.pcrOnEveryPacket && m.hasClock() {
+		return true
+	}
+	if m.pesPCRPeriod <= 0 || !m.hasClock() {
+		return false
+	}
+	last, ok := m.lastPCRs[pid]
+	return !ok || m.clock()+m.packetDuration()-last > m.pesPCRPeriod
+}
+
+// WritePCR writes a packet carrying only the given PCR on the PCR PID of the default program
+// PCR is expressed in 27MHz ticks
+func (m *Muxer) WritePCR(pcr uint64) (int, error) {
+	return m.defaultProgram.WritePCR(pcr)
+}
+
+// WritePCR writes a packet carrying only the given PCR on the PCR PID of the program
+// PCR is expressed in 27MHz ticks. In constant bitrate mode, it gives the pace the same way PCRs written
+// through WriteData do.
+func (p *MuxerProgram) WritePCR(pcr uint64) (int, error) {
+	m := p.m
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+	if _, ok := m.esContexts[p.pmt.PCRPID]; !ok {
+		return 0, ErrPCRPIDInvalid
+	}
+
+	// PES is nil since there's no payload
+	d := &MuxerData{
+		AdaptationField: &PacketAdaptationField{
+			HasPCR: true,
+			PCR:    newClockReferenceFromPCR(int64(pcr)),
+		},
+		PID: p.pmt.PCRPID,
+	}
+
+	bytesWritten := 0
+	if m.bitrate > 0 {
+		n, err := m.waitForData(d)
+		bytesWritten += n
+		if err != nil {
+			return bytesWritten, err
+		}
+	}
+
+	if m.forceTablesFunc != nil && m.forceTablesFunc(d, true) {
+		n, err := m.WriteTables()
+		bytesWritten += n
+		if err != nil {
+			return bytesWritten, err
+		}
+	}
+
+	n, err := m.writePCRPacket(d.PID, d.AdaptationField.PCR)
+	bytesWritten += n
+	return bytesWritten, err
+}
+
+// writePCRPacket writes a packet containing only an adaptation field with a PCR
+// Since there's no payload, continuity counter is not incremented
+// In constant bitrate mode, the PCR is replaced by the muxer clock
+func (m *Muxer) writePCRPacket(pid uint16, pcr *ClockReference) (int, error) {
+	if m.bitrate > 0 {
+		m.startClock(pcr.Base*300 + pcr.Extension)
+		pcr = m.clockReference()
+	}
+	m.lastPCRs[pid] = pcr.Base*300 + pcr.Extension
+
+	af := &PacketAdaptationField{
+		HasPCR: true,
+		PCR:    pcr,
+	}
+	// one byte for adaptation field length field
+	af.StuffingLength = MpegTsPacketSize - 1 - mpegTsPacketHeaderSize - 1 - int(calcPacketAdaptationFieldLength(af))
+
+	pkt := Packet{
+		AdaptationField: af,
+		Header: &PacketHeader{
+			ContinuityCounter:  uint8(m.esContexts[pid].cc.last()),
+			HasAdaptationField: true,
+			PID:                pid,
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	if _, err := writePacket(w, &pkt, MpegTsPacketSize); err != nil {
+		return 0, err
+	}
+
+	m.lastPCR = af.PCR
+	return m.writeRawPacket(buf.Bytes())
+}
+
+// clockReference returns the current muxer clock as a PCR
+func (m *Muxer) clockReference() *ClockReference {
+	return newClockReferenceFromPCR(m.clock())
+}
+
+// newClockReferenceFromPCR builds a clock reference from a 27MHz value
+func newClockReferenceFromPCR(c int64) *ClockReference {
+	return newClockReference((c/300)&pcrBaseMask, c%300)
+}
+
+// WriteIdle pads the stream with null packets for d at the configured bitrate, without any PES packet
+// When no bitrate is set, a null packet is written every 10ms until the clock func has moved forward by d, since
+// the clock func doesn't move forward with the bytes written. It blocks until then or until the muxer context is
+// cancelled, in which case the context error is returned.
+// PCRs keep flowing when a PCR period is set and tables are retransmitted the same way WriteSignalOnly does, which
+// comes in handy to display a still picture for a while after its PES has been written.
+func (m *Muxer) WriteIdle(d time.Duration) (int, error) {
+	if m.closed {
+		return 0, ErrMuxerClosed
+	}
+	if !m.hasClock() {
+		return 0, ErrBitrateNotSet
+	}
+
+	bytesWritten := 0
+	start := m.clock()
+	end := start + int64(d)*clockFrequency/int64(time.Second)
+	lastTables := start
+	for isClockBefore(m.clock(), end) {
+		// Check ctx error
+		if err := m.ctx.Err(); err != nil {
+			return bytesWritten, err
+		}
+
+		// Write tables
+		if !isClockBefore(m.clock(), lastTables+signalOnlyTablesPeriod) {
+			lastTables = m.clock()
+			n, err := m.WriteTables()
+			bytesWritten += n
+			if err != nil {
+				return bytesWritten, err
+			}
+		}
+
+		// Write padding
+		n, err := m.writeNullPackets(1)
+		bytesWritten += n
+		if err != nil {
+			return bytesWritten, err
+		}
+
+		// Clock func is waited for
+		if m.bitrate <= 0 {
+			select {
+			case <-m.ctx.Done():
+				return bytesWritten, m.ctx.Err()
+			case <-time.After(idleClockFuncPeriod):
+			}
+		}
+	}
+	return bytesWritten, nil
+}
+
+// WriteSignalOnly writes tables and null packets at the configured bitrate, without any PES packet
+// It's useful to keep a valid stream while a channel is off air.
+// It blocks until the muxer context is cancelled, in which case the context error is returned
+func (m *Muxer) WriteSignalOnly() error {
+	if m.closed {
+		return ErrMuxerClosed
+	}
+	if m.bitrate <= 0 {
+		return ErrBitrateNotSet
+	}
+
+	start := time.Now()
+	clockStart := m.clock()
+	lastTables := int64(-1)
+	for {
+		// Check ctx error
+		if err := m.ctx.Err(); err != nil {
+			return err
+		}
+
+		// Write tables
+		if lastTables < 0 || m.clock()-lastTables >= signalOnlyTablesPeriod {
+			lastTables = m.clock()
+			if _, err := m.WriteTables(); err != nil {
+				return err
+			}
+		}
+
+		// Write padding
+		if _, err := m.writeNullPackets(1); err != nil {
+			return err
+		}
+
+		// Don't go faster than the bitrate
+		if d := time.Duration((m.clock()-clockStart)*int64(time.Second)/clockFrequency) - time.Since(start); d > signalOnlyMaxAdvance {
+			select {
+			case <-m.ctx.Done():
+				return m.ctx.Err()
+			case <-time.After(d):
+			}
+		}
+	}
+}
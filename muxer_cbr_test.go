@@ -0,0 +1,726 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuxer_CBR(t *testing.T) {
+	const bitrate = 1000000
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptCBR(bitrate, 40*time.Millisecond))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// 1 second of video at 25 fps
+	frame := bytes.Repeat([]byte{0x1}, 2000)
+	for i := 0; i < 25; i++ {
+		dts := &ClockReference{Base: 900000 + int64(i)*3600}
+		_, err = muxer.WriteData(&MuxerData{
+			PID: 0x0100,
+			PES: &PESData{
+				Data: frame,
+				Header: &PESHeader{
+					OptionalHeader: &PESOptionalHeader{
+						DTS:             dts,
+						PTS:             dts,
+						PTSDTSIndicator: PTSDTSIndicatorBothPresent,
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(buf.Len()), muxer.BytesWritten())
+
+	type pcrAt struct {
+		offset int64
+		pcr    int64
+	}
+	var pcrs []pcrAt
+	var nulls int
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for offset := int64(0); ; offset += MpegTsPacketSize {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PID == PIDNull {
+			nulls++
+		}
+		if p.Header.HasAdaptationField && p.AdaptationField.HasPCR {
+			assert.Equal(t, uint16(0x0100), p.Header.PID)
+			pcrs = append(pcrs, pcrAt{offset: offset, pcr: p.AdaptationField.PCR.Base*300 + p.AdaptationField.PCR.Extension})
+		}
+	}
+	assert.NotZero(t, nulls)
+	assert.True(t, len(pcrs) >= 25)
+
+	// PCRs must match the byte rate and be spaced by at most the PCR period
+	for i := 1; i < len(pcrs); i++ {
+		delta := pcrs[i].pcr - pcrs[i-1].pcr
+		assert.Equal(t, (pcrs[i].offset-pcrs[i-1].offset)*8*27000000/bitrate, delta)
+		assert.True(t, delta <= 40*27000, "PCR spacing %d too large", delta)
+	}
+
+	// Last frame is written shortly before its decoding time
+	last := pcrs[len(pcrs)-1]
+	assert.InDelta(t, (900000+24*3600)*300-cbrDelay, last.pcr, 40*27000)
+}
+
+func TestMuxer_ClockLongStream(t *testing.T) {
+	muxer := NewMuxer(context.Background(), nil, MuxerOptConstantBitrate(3000000))
+
+	// Bits written times the clock frequency overflows int64
+	muxer.bytesWritten = 45000000000
+	assert.Equal(t, int64(120000*clockFrequency), muxer.clock())
+	muxer.bytesWritten += MpegTsPacketSize
+	assert.Equal(t, int64(120000*clockFrequency+MpegTsPacketSize*8*9), muxer.clock())
+}
+
+func TestMuxer_CBRClockWrapAround(t *testing.T) {
+	const bitrate = 1000000
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptConstantBitrate(bitrate))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	err = muxer.SetPCRPID(0x0100)
+	assert.NoError(t, err)
+
+	// Clock is about to wrap around while the DTS has already wrapped around
+	muxer.clockStarted = true
+	muxer.clockStart = pcrWrapAround - 1000
+	dts := &ClockReference{Base: (cbrDelay + clockFrequency/100) / 300}
+	n, err := muxer.waitForData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+			DTS:             dts,
+			PTS:             dts,
+			PTSDTSIndicator: PTSDTSIndicatorBothPresent,
+		}}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 7*MpegTsPacketSize, n)
+	assert.GreaterOrEqual(t, muxer.clock(), int64(pcrWrapAround+clockFrequency/100))
+
+	// Target is already reached once the clock has wrapped around
+	n, err = muxer.waitForData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+			PTS:             &ClockReference{Base: cbrDelay / 300},
+			PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+		}}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestIsClockBefore(t *testing.T) {
+	assert.True(t, isClockBefore(0, 1))
+	assert.False(t, isClockBefore(1, 1))
+	assert.False(t, isClockBefore(1, 0))
+	assert.True(t, isClockBefore(pcrWrapAround-1, 1))
+	assert.False(t, isClockBefore(pcrWrapAround+1, 1))
+	assert.True(t, isClockBefore(pcrWrapAround+1, 2))
+}
+
+func TestMuxer_CBRCallerPCR(t *testing.T) {
+	muxer := NewMuxer(context.Background(), &bytes.Buffer{}, MuxerOptConstantBitrate(1000000))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	err = muxer.SetPCRPID(0x0100)
+	assert.NoError(t, err)
+
+	// PCR is replaced by the muxer clock in the stream only
+	pcr := &ClockReference{Base: 900000}
+	af := &PacketAdaptationField{HasPCR: true, PCR: pcr}
+	for i := 0; i < 2; i++ {
+		_, err = muxer.WriteData(&MuxerData{
+			AdaptationField: af,
+			PID:             0x0100,
+			PES:             &PESData{Data: testPayload(), Header: &PESHeader{}},
+		})
+		assert.NoError(t, err)
+	}
+	assert.Same(t, pcr, af.PCR)
+	assert.Equal(t, &ClockReference{Base: 900000}, pcr)
+}
+
+func TestMuxer_WriteIdle(t *testing.T) {
+	const bitrate = 1000000
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptCBR(bitrate, 40*time.Millisecond))
+
+	_, err := NewMuxer(context.Background(), &buf).WriteIdle(time.Second)
+	assert.Equal(t, ErrBitrateNotSet, err)
+
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// Still picture
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Data: bytes.Repeat([]byte{0x1}, 20000),
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{
+					MarkerBits:      2,
+					PTS:             &ClockReference{Base: 900000},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	n := buf.Len()
+
+	// Picture is displayed for 3 seconds
+	_, err = muxer.WriteIdle(3 * time.Second)
+	assert.NoError(t, err)
+	assert.InDelta(t, 3*bitrate/8, buf.Len()-n, MpegTsPacketSize)
+
+	var pcrs []int64
+	var pats int
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()[n:]))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		switch p.Header.PID {
+		case PIDPAT:
+			pats++
+		case 0x0100:
+			// Frame is not sent again
+			assert.False(t, p.Header.HasPayload)
+			if assert.True(t, p.AdaptationField.HasPCR) {
+				pcrs = append(pcrs, p.AdaptationField.PCR.Base*300+p.AdaptationField.PCR.Extension)
+			}
+		}
+	}
+	assert.True(t, len(pcrs) >= 75)
+	for i := 1; i < len(pcrs); i++ {
+		assert.True(t, pcrs[i]-pcrs[i-1] <= 40*27000)
+	}
+	assert.True(t, pats >= 29)
+}
+
+func TestMuxer_WriteIdleClockFunc(t *testing.T) {
+	// Wall clock
+	buf := bytes.Buffer{}
+	start := time.Now()
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptClockFunc(func() uint64 {
+		return uint64(time.Since(start) * clockFrequency / time.Second)
+	}))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	err = muxer.SetPCRPID(0x0100)
+	assert.NoError(t, err)
+
+	n, err := muxer.WriteIdle(100 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, buf.Len(), n)
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+	// A null packet every 10ms and tables once
+	assert.True(t, n > 0 && n <= 15*MpegTsPacketSize)
+
+	// Frozen clock
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	buf.Reset()
+	muxer = NewMuxer(ctx, &buf, MuxerOptClockFunc(func() uint64 { return 27000000 }))
+	n, err = muxer.WriteIdle(time.Second)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, buf.Len(), n)
+	assert.True(t, n <= 10*MpegTsPacketSize)
+}
+
+func TestMuxer_WriteSignalOnly(t *testing.T) {
+	const bitrate = 1000000
+	buf := bytes.Buffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	muxer := NewMuxer(ctx, &buf)
+	assert.Equal(t, ErrBitrateNotSet, muxer.WriteSignalOnly())
+
+	muxer = NewMuxer(ctx, &buf, MuxerOptCBR(bitrate, 40*time.Millisecond))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	start := time.Now()
+	time.AfterFunc(100*time.Millisecond, cancel)
+	assert.Equal(t, context.Canceled, muxer.WriteSignalOnly())
+	elapsed := time.Since(start)
+
+	// Output must not go faster than the bitrate
+	assert.NotZero(t, buf.Len())
+	assert.True(t, int64(buf.Len()) <= int64(elapsed)*bitrate/8/int64(time.Second)+int64(signalOnlyMaxAdvance)*bitrate/8/int64(time.Second)+MpegTsPacketSize)
+
+	pids := map[uint16]int{}
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		pids[p.Header.PID]++
+		if p.Header.PID == 0x0100 {
+			assert.False(t, p.Header.HasPayload)
+		}
+	}
+	assert.NotZero(t, pids[PIDPAT])
+	assert.NotZero(t, pids[pmtStartPID])
+	assert.NotZero(t, pids[PIDNull])
+	assert.NotZero(t, pids[0x0100])
+}
+
+func TestMuxer_ConstantBitrate(t *testing.T) {
+	const bitrate = 2000000
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptConstantBitrate(bitrate))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// One second of PCR-spaced payload, PCRs being 40ms apart
+	for i := int64(0); i <= 25; i++ {
+		pcr := &ClockReference{Base: 900000 + i*3600}
+		_, err = muxer.WriteData(&MuxerData{
+			PID:             0x0100,
+			AdaptationField: &PacketAdaptationField{HasPCR: true, PCR: pcr},
+			PES: &PESData{
+				Data:   bytes.Repeat([]byte{0x1}, 3000),
+				Header: &PESHeader{},
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	var pcrOffsets []int64
+	var firstPCR *ClockReference
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for offset := int64(0); ; offset += MpegTsPacketSize {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.HasAdaptationField && p.AdaptationField.HasPCR {
+			if firstPCR == nil {
+				firstPCR = p.AdaptationField.PCR
+			}
+			pcrOffsets = append(pcrOffsets, offset)
+		}
+	}
+	assert.Len(t, pcrOffsets, 26)
+	assert.InDelta(t, bitrate/8, pcrOffsets[25]-pcrOffsets[0], MpegTsPacketSize)
+
+	// Clock starts with the first PCR written by the caller
+	assert.Equal(t, int64(900000), firstPCR.Base)
+	assert.Equal(t, int64(0), firstPCR.Extension)
+}
+
+func TestMuxer_WritePCR(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptTablesRetransmitPeriod(100))
+
+	_, err := muxer.WritePCR(0)
+	assert.Equal(t, ErrPCRPIDInvalid, err)
+
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// 2 packets of payload
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Data:   bytes.Repeat([]byte{0x1}, 200),
+			Header: &PESHeader{},
+		},
+	})
+	assert.NoError(t, err)
+	buf.Reset()
+
+	n, err := muxer.WritePCR(5726623061*300 + 41)
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize, n)
+
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Data:   []byte("test"),
+			Header: &PESHeader{},
+		},
+	})
+	assert.NoError(t, err)
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	p, err := dmx.NextPacket()
+	assert.NoError(t, err)
+	assert.False(t, p.Header.HasPayload)
+	assert.True(t, p.Header.HasAdaptationField)
+	assert.Equal(t, &ClockReference{Base: 5726623061, Extension: 41}, p.AdaptationField.PCR)
+
+	// Continuity counter only advances with payload
+	assert.Equal(t, uint8(1), p.Header.ContinuityCounter)
+	p, err = dmx.NextPacket()
+	assert.NoError(t, err)
+	assert.True(t, p.Header.HasPayload)
+	assert.Equal(t, uint8(2), p.Header.ContinuityCounter)
+}
+
+func TestMuxer_PCRPeriodClockFunc(t *testing.T) {
+	buf := bytes.Buffer{}
+	var clock uint64
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPCRPeriod(40*time.Millisecond), MuxerOptClockFunc(func() uint64 {
+		// 1ms per call
+		clock += 27000
+		return clock
+	}))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// PES spans many packets
+	payload := make([]byte, 20000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Data: payload,
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{
+					PTS:             &ClockReference{Base: 900000},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	var pcrs []int64
+	var pes *PESData
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.HasAdaptationField && p.AdaptationField.HasPCR {
+			assert.False(t, p.Header.HasPayload)
+			pcrs = append(pcrs, p.AdaptationField.PCR.Base*300+p.AdaptationField.PCR.Extension)
+		}
+	}
+	assert.True(t, len(pcrs) > 2)
+	for i := 1; i < len(pcrs); i++ {
+		assert.True(t, pcrs[i]-pcrs[i-1] <= 41*27000)
+	}
+
+	// PES is not corrupted by PCR packets
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PES != nil {
+			pes = d.PES
+		}
+	}
+	assert.NotNil(t, pes)
+	assert.Equal(t, payload, pes.Data)
+}
+
+func TestMuxer_SetPCRPIDMidStream(t *testing.T) {
+	buf := bytes.Buffer{}
+	var clock uint64
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPCRPeriod(40*time.Millisecond), MuxerOptClockFunc(func() uint64 {
+		// 1ms per call
+		clock += 27000
+		return clock
+	}))
+
+	for _, pid := range []uint16{0x0100, 0x0101} {
+		err := muxer.AddElementaryStream(PMTElementaryStream{
+			ElementaryPID: pid,
+			StreamType:    StreamTypeH264Video,
+		})
+		assert.NoError(t, err)
+	}
+	assert.True(t, errors.Is(muxer.SetPCRPID(0x0102), ErrPCRPIDInvalid))
+	assert.NoError(t, muxer.SetPCRPID(0x0100))
+
+	write := func(pid uint16) {
+		_, err := muxer.WriteData(&MuxerData{
+			AdaptationField: &PacketAdaptationField{RandomAccessIndicator: true},
+			PID:             pid,
+			PES: &PESData{
+				Data: make([]byte, 2000),
+				Header: &PESHeader{
+					OptionalHeader: &PESOptionalHeader{
+						PTS:             &ClockReference{Base: 900000},
+						PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+	}
+	write(0x0100)
+	write(0x0101)
+	assert.NoError(t, muxer.SetPCRPID(0x0101))
+	assert.True(t, muxer.defaultProgram.pmtDirty)
+	write(0x0101)
+
+	// PMT is written again with the new PCR PID and a new version
+	var pmtPCRPIDs []uint16
+	var pmtVersions []uint8
+	bs := buf.Bytes()
+	for ; len(bs) >= MpegTsPacketSize; bs = bs[MpegTsPacketSize:] {
+		if pid := uint16(bs[1]&0x1f)<<8 | uint16(bs[2]); pid == pmtStartPID {
+			pmtVersions = append(pmtVersions, bs[10]>>1&0x1f)
+			pmtPCRPIDs = append(pmtPCRPIDs, uint16(bs[13]&0x1f)<<8|uint16(bs[14]))
+		}
+	}
+	assert.Equal(t, []uint16{0x0100, 0x0101}, pmtPCRPIDs)
+	assert.Equal(t, []uint8{0, 1}, pmtVersions)
+
+	// PCRs move to the new PID once the new PMT has been written
+	var pcrPIDsAfterChange []uint16
+	pmts := 0
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PID == pmtStartPID && p.Header.PayloadUnitStartIndicator {
+			pmts++
+		}
+		if p.Header.HasAdaptationField && p.AdaptationField.HasPCR {
+			if pmts < 2 {
+				assert.Equal(t, uint16(0x0100), p.Header.PID)
+			} else {
+				pcrPIDsAfterChange = append(pcrPIDsAfterChange, p.Header.PID)
+			}
+		}
+	}
+	assert.Equal(t, 2, pmts)
+	assert.NotEmpty(t, pcrPIDsAfterChange)
+	for _, pid := range pcrPIDsAfterChange {
+		assert.Equal(t, uint16(0x0101), pid)
+	}
+}
+
+func TestMuxer_WriteNullPackets(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	n, err := muxer.WriteNullPackets(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3*MpegTsPacketSize, n)
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for i := 0; i < 3; i++ {
+		p, err := dmx.NextPacket()
+		assert.NoError(t, err)
+		assert.Equal(t, PIDNull, p.Header.PID)
+		assert.False(t, p.Header.HasAdaptationField)
+		assert.Equal(t, bytes.Repeat([]byte{0xff}, MpegTsPacketSize-4), p.Payload)
+	}
+
+	assert.NoError(t, muxer.Close())
+	_, err = muxer.WriteNullPackets(1)
+	assert.Equal(t, ErrMuxerClosed, err)
+}
+
+func TestMuxer_PadTo(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	_, err := muxer.WriteNullPackets(1)
+	assert.NoError(t, err)
+
+	// 4096 is reached after 1024 packets
+	n, err := muxer.PadTo(4096)
+	assert.NoError(t, err)
+	assert.Equal(t, 1023*MpegTsPacketSize, n)
+	assert.Equal(t, 4096*47, buf.Len())
+
+	// Already aligned
+	n, err = muxer.PadTo(4096)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// 192 bytes packets
+	buf.Reset()
+	muxer = NewMuxer(context.Background(), &buf, MuxerOptPacketSize(192))
+	_, err = muxer.WriteNullPackets(1)
+	assert.NoError(t, err)
+	n, err = muxer.PadTo(6144)
+	assert.NoError(t, err)
+	assert.Equal(t, 31*192, n)
+
+	_, err = muxer.PadTo(0)
+	assert.Equal(t, ErrPadByteMultipleInvalid, err)
+}
+
+func TestMuxer_PESPCRPeriod(t *testing.T) {
+	buf := bytes.Buffer{}
+	var clock uint64
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPESPCRPeriod(10*time.Millisecond), MuxerOptClockFunc(func() uint64 {
+		// 1ms per call
+		clock += 27000
+		return clock
+	}))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// PES spans many packets
+	payload := make([]byte, 20000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Data: payload,
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{
+					MarkerBits:      2,
+					PTS:             &ClockReference{Base: 900000},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	var pcrs []int64
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.HasAdaptationField && p.AdaptationField.HasPCR {
+			// PCRs are carried by the PES packets
+			assert.True(t, p.Header.HasPayload)
+			pcrs = append(pcrs, p.AdaptationField.PCR.Base*300+p.AdaptationField.PCR.Extension)
+		}
+	}
+	assert.True(t, len(pcrs) > 2)
+	for i := 1; i < len(pcrs); i++ {
+		assert.True(t, pcrs[i]-pcrs[i-1] <= 14*27000)
+	}
+
+	// PES is not corrupted by PCRs
+	var pes *PESData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PES != nil {
+			pes = d.PES
+		}
+	}
+	assert.NotNil(t, pes)
+	assert.Equal(t, payload, pes.Data)
+}
+
+func TestMuxer_PCROnEveryPacket(t *testing.T) {
+	buf := bytes.Buffer{}
+	var clock uint64
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPCROnEveryPacket(), MuxerOptClockFunc(func() uint64 {
+		clock += 27000
+		return clock
+	}))
+
+	for _, pid := range []uint16{0x0100, 0x0101} {
+		err := muxer.AddElementaryStream(PMTElementaryStream{
+			ElementaryPID: pid,
+			StreamType:    StreamTypeH264Video,
+		})
+		assert.NoError(t, err)
+	}
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// Adaptation field of the caller is completed
+	af := &PacketAdaptationField{RandomAccessIndicator: true}
+	for _, pid := range []uint16{0x0100, 0x0101} {
+		_, err := muxer.WriteData(&MuxerData{
+			AdaptationField: af,
+			PID:             pid,
+			PES: &PESData{
+				Data:   make([]byte, 500),
+				Header: &PESHeader{},
+			},
+		})
+		assert.NoError(t, err)
+	}
+	assert.False(t, af.HasPCR)
+
+	pcrs := map[uint16][]bool{}
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PID == 0x0100 || p.Header.PID == 0x0101 {
+			pcrs[p.Header.PID] = append(pcrs[p.Header.PID], p.Header.HasAdaptationField && p.AdaptationField.HasPCR)
+			if len(pcrs[p.Header.PID]) == 1 {
+				assert.True(t, p.AdaptationField.RandomAccessIndicator)
+			}
+		}
+	}
+	assert.Equal(t, []bool{true, true, true}, pcrs[0x0100])
+	assert.Equal(t, []bool{false, false, false}, pcrs[0x0101])
+}
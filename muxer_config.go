@@ -0,0 +1,212 @@
+package astits
+
+import (
+	"fmt"
+)
+
+// MuxerConfig describes the programs, elementary streams and SI tables of a mux, see Muxer.ApplyConfig
+type MuxerConfig struct {
+	Network                    *MuxerNetworkConfig // Written in the NIT, see Muxer.SetNetworkInformation
+	OriginalNetworkID          uint16              // 0 leaves it untouched, see Muxer.SetOriginalNetworkID
+	Programs                   []MuxerProgramConfig
+	TransportStreamDescriptors []*Descriptor // Written in the TSDT, see Muxer.SetTransportStreamDescriptors
+}
+
+// MuxerNetworkConfig describes the network in the NIT
+type MuxerNetworkConfig struct {
+	NetworkID        uint16
+	NetworkName      string
+	TransportStreams []*NITDataTransportStream
+}
+
+// MuxerProgramConfig describes a program, exactly one of its elementary streams carrying the PCR
+type MuxerProgramConfig struct {
+	ElementaryStreams  []MuxerStreamConfig
+	ProgramDescriptors []*Descriptor // Written in the PMT, their length being computed from their content
+	ProgramNumber      uint16
+	Service            *MuxerServiceConfig // Written in the SDT
+}
+
+// MuxerStreamConfig describes an elementary stream, whose PID is allocated automatically if
+// ElementaryStream.ElementaryPID is zero. If Label is not empty, the stream can be written with WriteDataByLabel.
+type MuxerStreamConfig struct {
+	ElementaryStream PMTElementaryStream
+	IsPCR            bool
+	Label            string
+}
+
+// MuxerServiceConfig describes a program in the SDT, see MuxerProgram.SetServiceDescription and
+// MuxerProgram.SetServiceDescriptors
+type MuxerServiceConfig struct {
+	Descriptors   []*Descriptor
+	ProviderName  string
+	RunningStatus uint8 // 0 means running
+	ServiceName   string
+	ServiceType   uint8
+}
+
+// ApplyConfig adds the programs and elementary streams described by cfg and sets its SI tables, which comes in handy
+// to stand up a mux from a config file. cfg is validated against itself and the muxer before anything is applied, so
+// that the muxer is left untouched when an error, naming the offending program or PID, is returned.
+// As with AddFillerProgram, the default program is used for the first program as long as it has no elementary
+// stream. PMT PIDs are allocated before elementary stream PIDs.
+func (m *Muxer) ApplyConfig(cfg MuxerConfig) error {
+	pids, err := m.validateConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Programs are added first so that PMT PIDs are allocated as validated
+	ps := make([]*MuxerProgram, len(cfg.Programs))
+	for i, pc := range cfg.Programs {
+		if i == 0 {
+			ps[i] = m.claimDefaultProgram(pc.ProgramNumber)
+		}
+		if ps[i] == nil {
+			if ps[i], err = m.AddProgram(pc.ProgramNumber); err != nil {
+				return fmt.Errorf("astits: adding program %d failed: %w", pc.ProgramNumber, err)
+			}
+		}
+	}
+
+	// Loop through programs
+	for i, pc := range cfg.Programs {
+		p := ps[i]
+
+		// Elementary streams
+		for _, sc := range pc.ElementaryStreams {
+			// Allocated PIDs must not be the ones of elementary streams added afterwards
+			es := sc.ElementaryStream
+			if es.ElementaryPID == 0 {
+				es.ElementaryPID = m.allocatePID()
+				for _, ok := pids[es.ElementaryPID]; ok; _, ok = pids[es.ElementaryPID] {
+					es.ElementaryPID = m.allocatePID()
+				}
+			}
+			if err = p.AddElementaryStream(es); err != nil {
+				return fmt.Errorf("astits: adding elementary stream to program %d failed: %w", pc.ProgramNumber, err)
+			}
+			if sc.Label != "" {
+				m.labels[sc.Label] = es.ElementaryPID
+			}
+			if sc.IsPCR {
+				if err = p.SetPCRPID(es.ElementaryPID); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Program descriptors
+		for _, d := range pc.ProgramDescriptors {
+			d.Length = calcDescriptorLength(d)
+		}
+		p.pmt.ProgramDescriptors = pc.ProgramDescriptors
+		p.pmtDirty = true
+
+		// Service
+		if sc := pc.Service; sc != nil {
+			p.SetServiceDescription(sc.ProviderName, sc.ServiceName, sc.ServiceType)
+			if len(sc.Descriptors) > 0 {
+				p.SetServiceDescriptors(sc.Descriptors)
+			}
+			if sc.RunningStatus > 0 {
+				p.SetServiceRunningStatus(sc.RunningStatus)
+			}
+		}
+	}
+
+	// Tables
+	if cfg.Network != nil {
+		m.SetNetworkInformation(cfg.Network.NetworkID, cfg.Network.NetworkName, cfg.Network.TransportStreams)
+	}
+	if cfg.OriginalNetworkID > 0 {
+		m.SetOriginalNetworkID(cfg.OriginalNetworkID)
+	}
+	if len(cfg.TransportStreamDescriptors) > 0 {
+		m.SetTransportStreamDescriptors(cfg.TransportStreamDescriptors)
+	}
+	return nil
+}
+
+// validateConfig checks that cfg can be applied without conflicting with itself or the muxer and returns the PIDs of
+// its elementary streams, indexed by PID, whose value is the program number
+func (m *Muxer) validateConfig(cfg MuxerConfig) (pids map[uint16]uint16, err error) {
+	// The default program is claimed by the first program if it has no elementary stream
+	claimDefault := len(cfg.Programs) > 0 && len(m.defaultProgram.pmt.ElementaryStreams) == 0
+
+	// PMT PIDs of added programs are allocated the way AddProgram does
+	nextPMTPID := m.nextPMTPID
+	pmtPIDs := make(map[uint16]uint16) // pmt pid -> program number
+
+	// Loop through programs
+	programNumbers := make(map[uint16]bool)
+	labels := make(map[string]bool)
+	pids = make(map[uint16]uint16)
+	for i, pc := range cfg.Programs {
+		// Program number
+		if pc.ProgramNumber == 0 {
+			return nil, fmt.Errorf("astits: program #%d has program number 0: %w", i, ErrProgramNumberInvalid)
+		}
+		if programNumbers[pc.ProgramNumber] {
+			return nil, fmt.Errorf("astits: program %d is declared twice: %w", pc.ProgramNumber, ErrProgramNumberAlreadyExists)
+		}
+		if op := m.program(pc.ProgramNumber); op != nil && !(claimDefault && op == m.defaultProgram) {
+			return nil, fmt.Errorf("astits: program %d is already used by the muxer: %w", pc.ProgramNumber, ErrProgramNumberAlreadyExists)
+		}
+		programNumbers[pc.ProgramNumber] = true
+
+		// PMT PID
+		if i > 0 || !claimDefault {
+			for m.pm.exists(nextPMTPID) {
+				nextPMTPID++
+			}
+			pmtPIDs[nextPMTPID] = pc.ProgramNumber
+			nextPMTPID++
+		}
+
+		// Loop through elementary streams
+		pcrs := 0
+		for _, sc := range pc.ElementaryStreams {
+			if sc.IsPCR {
+				pcrs++
+			}
+
+			// Label
+			if sc.Label != "" {
+				if _, ok := m.labels[sc.Label]; ok || labels[sc.Label] {
+					return nil, fmt.Errorf("astits: label %q of program %d is already used: %w", sc.Label, pc.ProgramNumber, ErrLabelAlreadyExists)
+				}
+				labels[sc.Label] = true
+			}
+
+			// PID
+			pid := sc.ElementaryStream.ElementaryPID
+			if pid == 0 {
+				continue
+			}
+			if isReservedPID(pid) {
+				return nil, fmt.Errorf("astits: PID %d of program %d is reserved: %w", pid, pc.ProgramNumber, ErrPIDAlreadyExists)
+			}
+			if _, ok := m.esContexts[pid]; ok || m.pm.exists(pid) {
+				return nil, fmt.Errorf("astits: PID %d of program %d is already used by the muxer: %w", pid, pc.ProgramNumber, ErrPIDAlreadyExists)
+			}
+			if pn, ok := pids[pid]; ok {
+				return nil, fmt.Errorf("astits: PID %d of program %d is already used by program %d: %w", pid, pc.ProgramNumber, pn, ErrPIDAlreadyExists)
+			}
+			pids[pid] = pc.ProgramNumber
+		}
+
+		// PCR
+		if pcrs != 1 {
+			return nil, fmt.Errorf("astits: program %d has %d elementary streams carrying the PCR instead of 1: %w", pc.ProgramNumber, pcrs, ErrPCRPIDInvalid)
+		}
+	}
+
+	// Elementary stream PIDs must not be allocated as PMT PIDs
+	for pid, pn := range pids {
+		if ppn, ok := pmtPIDs[pid]; ok {
+			return nil, fmt.Errorf("astits: PID %d of program %d is the PMT PID of program %d: %w", pid, pn, ppn, ErrPIDAlreadyExists)
+		}
+	}
+	return
+}
@@ -0,0 +1,174 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMuxer_ApplyConfig(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	err := muxer.ApplyConfig(MuxerConfig{
+		Network: &MuxerNetworkConfig{NetworkID: 2, NetworkName: "network"},
+		Programs: []MuxerProgramConfig{
+			{
+				ElementaryStreams: []MuxerStreamConfig{
+					{ElementaryStream: PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}, IsPCR: true, Label: "video"},
+					{ElementaryStream: PMTElementaryStream{StreamType: StreamTypeAACAudio}, Label: "audio"},
+				},
+				ProgramNumber: 3,
+				Service:       &MuxerServiceConfig{ProviderName: "provider", ServiceName: "service", ServiceType: ServiceTypeDigitalTelevisionService},
+			},
+			{
+				ElementaryStreams: []MuxerStreamConfig{
+					{ElementaryStream: PMTElementaryStream{ElementaryPID: 0x101, StreamType: StreamTypeMPEG1Audio}, IsPCR: true},
+				},
+				ProgramDescriptors: []*Descriptor{{
+					Registration: &DescriptorRegistration{FormatIdentifier: RegistrationFormatIdentifierHDMV},
+					Tag:          DescriptorTagRegistration,
+				}},
+				ProgramNumber: 4,
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	// Allocated PIDs skip the ones of the config
+	assert.Equal(t, uint16(0x102), muxer.labels["audio"])
+	_, err = muxer.WriteDataByLabel("video", &MuxerData{PES: &PESData{
+		Data: []byte{0x1},
+		Header: &PESHeader{
+			OptionalHeader: &PESOptionalHeader{MarkerBits: 2, PTS: &ClockReference{Base: 1}, PTSDTSIndicator: PTSDTSIndicatorOnlyPTS},
+			StreamID:       0xe0,
+		},
+	}})
+	assert.NoError(t, err)
+
+	var pat *PATData
+	var nit *NITData
+	var sdt *SDTData
+	pmts := make(map[uint16]*PMTData)
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		switch {
+		case d.PAT != nil:
+			pat = d.PAT
+		case d.NIT != nil:
+			nit = d.NIT
+		case d.PMT != nil:
+			pmts[d.PID] = d.PMT
+		case d.SDT != nil:
+			sdt = d.SDT
+		}
+	}
+
+	if assert.NotNil(t, pat) {
+		assert.Equal(t, []*PATProgram{
+			{ProgramMapID: PIDNIT, ProgramNumber: 0},
+			{ProgramMapID: pmtStartPID, ProgramNumber: 3},
+			{ProgramMapID: pmtStartPID + 1, ProgramNumber: 4},
+		}, pat.Programs)
+	}
+	if pmt := pmts[pmtStartPID]; assert.NotNil(t, pmt) {
+		assert.Equal(t, uint16(0x100), pmt.PCRPID)
+		if assert.Len(t, pmt.ElementaryStreams, 2) {
+			assert.Equal(t, uint16(0x100), pmt.ElementaryStreams[0].ElementaryPID)
+			assert.Equal(t, uint16(0x102), pmt.ElementaryStreams[1].ElementaryPID)
+		}
+	}
+	if pmt := pmts[pmtStartPID+1]; assert.NotNil(t, pmt) {
+		assert.Equal(t, uint16(0x101), pmt.PCRPID)
+		if assert.Len(t, pmt.ProgramDescriptors, 1) {
+			assert.Equal(t, uint8(4), pmt.ProgramDescriptors[0].Length)
+		}
+	}
+	if assert.NotNil(t, nit) {
+		assert.Equal(t, uint16(2), nit.NetworkID)
+	}
+	if assert.NotNil(t, sdt) && assert.Len(t, sdt.Services, 1) {
+		assert.Equal(t, uint16(3), sdt.Services[0].ServiceID)
+		assert.Equal(t, []byte("service"), sdt.Services[0].Descriptors[0].Service.Name)
+	}
+}
+
+func TestMuxer_ApplyConfigErrors(t *testing.T) {
+	stream := func(pid uint16, isPCR bool) MuxerStreamConfig {
+		return MuxerStreamConfig{ElementaryStream: PMTElementaryStream{ElementaryPID: pid, StreamType: StreamTypeH264Video}, IsPCR: isPCR}
+	}
+	for _, v := range []struct {
+		err      error
+		name     string
+		programs []MuxerProgramConfig
+	}{
+		{
+			err:      ErrProgramNumberInvalid,
+			name:     "program number 0",
+			programs: []MuxerProgramConfig{{ElementaryStreams: []MuxerStreamConfig{stream(0, true)}}},
+		},
+		{
+			err:  ErrProgramNumberAlreadyExists,
+			name: "duplicate program number",
+			programs: []MuxerProgramConfig{
+				{ElementaryStreams: []MuxerStreamConfig{stream(0, true)}, ProgramNumber: 1},
+				{ElementaryStreams: []MuxerStreamConfig{stream(0, true)}, ProgramNumber: 1},
+			},
+		},
+		{
+			err:  ErrPIDAlreadyExists,
+			name: "duplicate PID",
+			programs: []MuxerProgramConfig{
+				{ElementaryStreams: []MuxerStreamConfig{stream(0x100, true)}, ProgramNumber: 1},
+				{ElementaryStreams: []MuxerStreamConfig{stream(0x100, true)}, ProgramNumber: 2},
+			},
+		},
+		{
+			err:  ErrPIDAlreadyExists,
+			name: "PMT PID",
+			programs: []MuxerProgramConfig{
+				{ElementaryStreams: []MuxerStreamConfig{stream(0x100, true)}, ProgramNumber: 1},
+				{ElementaryStreams: []MuxerStreamConfig{stream(pmtStartPID+1, true)}, ProgramNumber: 2},
+			},
+		},
+		{
+			err:      ErrPIDAlreadyExists,
+			name:     "reserved PID",
+			programs: []MuxerProgramConfig{{ElementaryStreams: []MuxerStreamConfig{stream(PIDNIT, true)}, ProgramNumber: 1}},
+		},
+		{
+			err:      ErrPCRPIDInvalid,
+			name:     "no PCR",
+			programs: []MuxerProgramConfig{{ElementaryStreams: []MuxerStreamConfig{stream(0, false)}, ProgramNumber: 1}},
+		},
+		{
+			err:      ErrPCRPIDInvalid,
+			name:     "several PCRs",
+			programs: []MuxerProgramConfig{{ElementaryStreams: []MuxerStreamConfig{stream(0, true), stream(0, true)}, ProgramNumber: 1}},
+		},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			muxer := NewMuxer(context.Background(), &bytes.Buffer{})
+			err := muxer.ApplyConfig(MuxerConfig{Programs: v.programs})
+			assert.True(t, errors.Is(err, v.err), err)
+
+			// Muxer is left untouched
+			assert.Len(t, muxer.programs, 1)
+			assert.Empty(t, muxer.esContexts)
+			assert.Equal(t, programNumberStart, muxer.defaultProgram.ProgramNumber())
+		})
+	}
+
+	// Label and program number already used by the muxer
+	muxer := NewMuxer(context.Background(), &bytes.Buffer{})
+	_, err := muxer.AddStream("video", PMTElementaryStream{StreamType: StreamTypeH264Video}, true)
+	assert.NoError(t, err)
+	err = muxer.ApplyConfig(MuxerConfig{Programs: []MuxerProgramConfig{{ElementaryStreams: []MuxerStreamConfig{stream(0, true)}, ProgramNumber: 1}}})
+	assert.True(t, errors.Is(err, ErrProgramNumberAlreadyExists))
+	s := stream(0, true)
+	s.Label = "video"
+	err = muxer.ApplyConfig(MuxerConfig{Programs: []MuxerProgramConfig{{ElementaryStreams: []MuxerStreamConfig{s}, ProgramNumber: 2}}})
+	assert.True(t, errors.Is(err, ErrLabelAlreadyExists))
+}
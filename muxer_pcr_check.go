@@ -0,0 +1,80 @@
+package astits
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrPCRBackwards      = errors.New("astits: PCR went backwards")
+	ErrPCRBitrateTooHigh = errors.New("astits: PCR bitrate too high")
+)
+
+// MuxerPCRBitrateFunc is called with the instantaneous bitrate, in bits per second, implied by the bytes written
+// between two consecutive PCRs of pid
+type MuxerPCRBitrateFunc func(pid uint16, bitrate int64)
+
+// pcrCheckPoint is the last PCR written on a PCR PID
+type pcrCheckPoint struct {
+	pcr     int64 // 27MHz
+	packets int64 // packets written before the PCR packet
+}
+
+// MuxerOptPCRBitrateCheck makes the muxer validate the PCRs written on the PCR PID of each program, which comes in
+// handy to catch clock bugs when PCRs are given by the caller.
+// The bitrate implied by two consecutive PCRs is computed and given to fn, if not nil. Writing fails with
+// ErrPCRBackwards when a PCR doesn't come after the previous one and, if maxBitrate > 0, with ErrPCRBitrateTooHigh
+// when the bitrate exceeds maxBitrate, in which case the packet carrying the PCR is not written.
+// PCRs following a discontinuity indicator are not checked.
+func MuxerOptPCRBitrateCheck(maxBitrate int, fn MuxerPCRBitrateFunc) func(*Muxer) {
+	return func(m *Muxer) {
+		m.pcrCheck = true
+		m.pcrCheckMaxBitrate = int64(maxBitrate)
+		m.pcrCheckFunc = fn
+	}
+}
+
+// checkPCRBitrate validates the PCR of a serialized packet against the previous one of its PID
+func (m *Muxer) checkPCRBitrate(bs []byte) error {
+	pcr, discontinuity, ok := rawPacketPCR(bs)
+	if !ok {
+		return nil
+	}
+	pid := rawPacketPID(bs)
+	if !m.isPCRPID(pid) {
+		return nil
+	}
+
+	if last, ok := m.pcrCheckPoints[pid]; ok && !discontinuity {
+		// Handle wraparound, PCRs more than half the wraparound period ahead are considered behind
+		delta := (pcr - last.pcr + pcrWrapAround) % pcrWrapAround
+		if delta == 0 || delta > pcrWrapAround/2 {
+			return fmt.Errorf("astits: PCR %d of PID %d doesn't come after %d: %w", pcr, pid, last.pcr, ErrPCRBackwards)
+		}
+
+		bitrate := (m.stats.Packets - last.packets) * MpegTsPacketSize * 8 * clockFrequency / delta
+		if m.pcrCheckFunc != nil {
+			m.pcrCheckFunc(pid, bitrate)
+		}
+		if m.pcrCheckMaxBitrate > 0 && bitrate > m.pcrCheckMaxBitrate {
+			return fmt.Errorf("astits: PCR %d of PID %d implies a bitrate of %d bps: %w", pcr, pid, bitrate, ErrPCRBitrateTooHigh)
+		}
+	}
+
+	m.pcrCheckPoints[pid] = pcrCheckPoint{
+		pcr:     pcr,
+		packets: m.stats.Packets,
+	}
+	return nil
+}
+
+// rawPacketPCR returns the 27MHz PCR of a serialized packet and whether its discontinuity indicator is set
+func rawPacketPCR(bs []byte) (pcr int64, discontinuity bool, ok bool) {
+	// Adaptation field must be present, not empty and have its PCR flag set
+	if bs[3]&0x20 == 0 || bs[4] == 0 || bs[5]&0x10 == 0 {
+		return
+	}
+	base := int64(bs[6])<<25 | int64(bs[7])<<17 | int64(bs[8])<<9 | int64(bs[9])<<1 | int64(bs[10])>>7
+	ext := int64(bs[10]&0x1)<<8 | int64(bs[11])
+	return base*300 + ext, bs[5]&0x80 > 0, true
+}
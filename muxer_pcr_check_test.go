@@ -0,0 +1,76 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuxer_PCRBitrateCheck(t *testing.T) {
+	type report struct {
+		bitrate int64
+		pid     uint16
+	}
+	var reports []report
+	muxer := NewMuxer(context.Background(), &bytes.Buffer{}, MuxerOptPCRBitrateCheck(20000000, func(pid uint16, bitrate int64) {
+		reports = append(reports, report{bitrate: bitrate, pid: pid})
+	}))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	writePCR := func(pcr int64, discontinuity bool) error {
+		_, err := muxer.WritePacket(&Packet{
+			AdaptationField: &PacketAdaptationField{
+				DiscontinuityIndicator: discontinuity,
+				HasPCR:                 true,
+				PCR:                    newClockReferenceFromPCR(pcr),
+			},
+			Header: &PacketHeader{
+				HasAdaptationField: true,
+				PID:                0x0100,
+			},
+		})
+		return err
+	}
+	writePackets := func(n int) {
+		for i := 0; i < n; i++ {
+			_, err := muxer.WritePacket(&Packet{
+				Header:  &PacketHeader{HasPayload: true, PID: 0x0100},
+				Payload: []byte("test"),
+			})
+			assert.NoError(t, err)
+		}
+	}
+
+	// 10 packets in 1ms
+	assert.NoError(t, writePCR(clockFrequency, false))
+	writePackets(9)
+	assert.NoError(t, writePCR(clockFrequency+27000, false))
+	assert.Equal(t, []report{{bitrate: 15040000, pid: 0x0100}}, reports)
+
+	// 10 packets in 0.5ms
+	writePackets(9)
+	err = writePCR(clockFrequency+40500, false)
+	assert.True(t, errors.Is(err, ErrPCRBitrateTooHigh))
+
+	// PCR going backwards
+	err = writePCR(clockFrequency, false)
+	assert.True(t, errors.Is(err, ErrPCRBackwards))
+
+	// Discontinuities aren't checked
+	assert.NoError(t, writePCR(0, true))
+
+	// PCR wrapping around
+	assert.NoError(t, writePCR(pcrWrapAround-27000, true))
+	writePackets(9)
+	assert.NoError(t, writePCR(0, false))
+	assert.Equal(t, report{bitrate: 15040000, pid: 0x0100}, reports[len(reports)-1])
+}
@@ -1,11 +1,15 @@
 package astits
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"github.com/asticode/go-astikit"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func patExpectedBytes(versionNumber uint8) []byte {
@@ -155,13 +159,13 @@ func TestMuxer_generatePMT(t *testing.T) {
 		ElementaryPID: 0x1234,
 		StreamType:    StreamTypeH264Video,
 	})
-	muxer.SetPCRPID(0x1234)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
 	assert.NoError(t, err)
 
-	err = muxer.generatePMT()
+	err = muxer.defaultProgram.generatePMT()
 	assert.NoError(t, err)
-	assert.Equal(t, MpegTsPacketSize, muxer.pmtBytes.Len())
-	assert.Equal(t, pmtExpectedBytesVideoOnly(0), muxer.pmtBytes.Bytes())
+	assert.Equal(t, MpegTsPacketSize, muxer.defaultProgram.pmtBytes.Len())
+	assert.Equal(t, pmtExpectedBytesVideoOnly(0), muxer.defaultProgram.pmtBytes.Bytes())
 
 	err = muxer.AddElementaryStream(PMTElementaryStream{
 		ElementaryPID: 0x0234,
@@ -169,10 +173,10 @@ func TestMuxer_generatePMT(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
-	err = muxer.generatePMT()
+	err = muxer.defaultProgram.generatePMT()
 	assert.NoError(t, err)
-	assert.Equal(t, MpegTsPacketSize, muxer.pmtBytes.Len())
-	assert.Equal(t, pmtExpectedBytesVideoAndAudio(1), muxer.pmtBytes.Bytes())
+	assert.Equal(t, MpegTsPacketSize, muxer.defaultProgram.pmtBytes.Len())
+	assert.Equal(t, pmtExpectedBytesVideoAndAudio(1), muxer.defaultProgram.pmtBytes.Bytes())
 }
 
 func TestMuxer_WriteTables(t *testing.T) {
@@ -182,7 +186,7 @@ func TestMuxer_WriteTables(t *testing.T) {
 		ElementaryPID: 0x1234,
 		StreamType:    StreamTypeH264Video,
 	})
-	muxer.SetPCRPID(0x1234)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
 	assert.NoError(t, err)
 
 	n, err := muxer.WriteTables()
@@ -206,6 +210,51 @@ func TestMuxer_WriteTables_Error(t *testing.T) {
 	assert.Equal(t, ErrPCRPIDInvalid, err)
 }
 
+func TestMuxer_PMTSizeFunc(t *testing.T) {
+	buf := bytes.Buffer{}
+	var lengths []int
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPMTSizeFunc(func(programNumber uint16, sectionLength int) {
+		assert.Equal(t, programNumberStart, programNumber)
+		lengths = append(lengths, sectionLength)
+	}))
+
+	// Streams have 10 descriptors, the PMT spanning 2 packets from the 5th stream on
+	var ds []*Descriptor
+	for i := 0; i < 10; i++ {
+		ds = append(ds, &Descriptor{
+			Length:           1,
+			StreamIdentifier: &DescriptorStreamIdentifier{ComponentTag: uint8(i)},
+			Tag:              DescriptorTagStreamIdentifier,
+		})
+	}
+	for i := uint16(0); i < 8; i++ {
+		err := muxer.AddElementaryStream(PMTElementaryStream{
+			ElementaryPID:               0x100 + i,
+			ElementaryStreamDescriptors: ds,
+			StreamType:                  StreamTypeH264Video,
+		})
+		assert.NoError(t, err)
+		if i == 0 {
+			assert.NoError(t, muxer.SetPCRPID(0x100))
+		}
+		_, err = muxer.WriteTables()
+		assert.NoError(t, err)
+		if i < 4 {
+			assert.Empty(t, lengths)
+		}
+	}
+	assert.Equal(t, []int{9 + 5*35 + 4}, lengths)
+
+	// PMT is still read back
+	var pmt *PMTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PMT != nil {
+			pmt = d.PMT
+		}
+	}
+	assert.Len(t, pmt.ElementaryStreams, 8)
+}
+
 func TestMuxer_AddElementaryStream(t *testing.T) {
 	muxer := NewMuxer(context.Background(), nil)
 	err := muxer.AddElementaryStream(PMTElementaryStream{
@@ -252,7 +301,7 @@ func TestMuxer_WritePayload(t *testing.T) {
 		ElementaryPID: 0x1234,
 		StreamType:    StreamTypeH264Video,
 	})
-	muxer.SetPCRPID(0x1234)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
 	assert.NoError(t, err)
 
 	err = muxer.AddElementaryStream(PMTElementaryStream{
@@ -319,3 +368,2687 @@ func TestMuxer_WritePayload(t *testing.T) {
 	assert.Equal(t, patExpectedBytes(0), bs[:MpegTsPacketSize])
 	assert.Equal(t, pmtExpectedBytesVideoAndAudio(0), bs[MpegTsPacketSize:MpegTsPacketSize*2])
 }
+
+// withCC sets the continuity counter of a serialized packet
+func withCC(bs []byte, cc uint8) []byte {
+	bs[3] = bs[3]&0xf0 | cc
+	return bs
+}
+
+func demuxAllData(t *testing.T, bs []byte, opts ...func(*Demuxer)) (ds []*DemuxerData) {
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(bs), opts...)
+	for {
+		d, err := dmx.NextData()
+		if err == ErrNoMorePackets {
+			return
+		}
+		assert.NoError(t, err)
+		if err != nil {
+			return
+		}
+		ds = append(ds, d)
+	}
+}
+
+func TestMuxer_SetContinuityCounter(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	assert.Equal(t, ErrPIDNotFound, muxer.SetContinuityCounter(0x0234, 0))
+	assert.Equal(t, ErrContinuityCounterInvalid, muxer.SetContinuityCounter(0x1234, 16))
+	assert.NoError(t, muxer.SetContinuityCounter(0x1234, 14))
+
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x1234,
+		PES: &PESData{
+			Data:   make([]byte, 3*MpegTsPacketSize),
+			Header: &PESHeader{},
+		},
+	})
+	assert.NoError(t, err)
+
+	// CCs continue from the one set and wrap
+	var ccs []uint8
+	for bs := buf.Bytes(); len(bs) >= MpegTsPacketSize; bs = bs[MpegTsPacketSize:] {
+		if rawPacketPID(bs) == 0x1234 {
+			ccs = append(ccs, bs[3]&0xf)
+		}
+	}
+	assert.Equal(t, []uint8{14, 15, 0, 1}, ccs)
+}
+
+func TestMuxer_ResetContinuityCounter(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	assert.Equal(t, ErrPIDNotFound, muxer.ResetContinuityCounter(0x0234, true))
+
+	af := &PacketAdaptationField{RandomAccessIndicator: true}
+	write := func() {
+		_, err := muxer.WriteData(&MuxerData{
+			AdaptationField: af,
+			PID:             0x1234,
+			PES: &PESData{
+				Data:   make([]byte, MpegTsPacketSize),
+				Header: &PESHeader{},
+			},
+		})
+		assert.NoError(t, err)
+	}
+	write()
+	assert.NoError(t, muxer.ResetContinuityCounter(0x1234, true))
+	write()
+	assert.NoError(t, muxer.ResetContinuityCounter(0x1234, false))
+	write()
+
+	// Only the packet following the first reset signals the discontinuity
+	var ccs []uint8
+	var discontinuities []bool
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PID == 0x1234 {
+			ccs = append(ccs, p.Header.ContinuityCounter)
+			discontinuities = append(discontinuities, p.AdaptationField != nil && p.AdaptationField.DiscontinuityIndicator)
+		}
+	}
+	assert.Equal(t, []uint8{0, 1, 0, 1, 0, 1}, ccs)
+	assert.Equal(t, []bool{false, false, true, false, false, false}, discontinuities)
+
+	// Caller's adaptation field is left untouched
+	assert.False(t, af.DiscontinuityIndicator)
+}
+
+func TestMuxer_WriteDiscontinuity(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	assert.Equal(t, ErrPIDNotFound, muxer.WriteDiscontinuity(0x0234))
+
+	d := &MuxerData{
+		PID: 0x1234,
+		PES: &PESData{
+			Data:   []byte("test"),
+			Header: &PESHeader{},
+		},
+	}
+	_, err = muxer.WriteData(d)
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.WriteDiscontinuity(0x1234))
+	_, err = muxer.WriteData(d)
+	assert.NoError(t, err)
+
+	var ps []*Packet
+	for bs := buf.Bytes(); len(bs) >= MpegTsPacketSize; bs = bs[MpegTsPacketSize:] {
+		if rawPacketPID(bs) == 0x1234 {
+			p, err := parsePacket(astikit.NewBytesIterator(bs[:MpegTsPacketSize]))
+			assert.NoError(t, err)
+			ps = append(ps, p)
+		}
+	}
+	if assert.Len(t, ps, 3) {
+		// CC doesn't advance on the adaptation field only packet
+		assert.Equal(t, uint8(0), ps[0].Header.ContinuityCounter)
+		assert.Equal(t, uint8(0), ps[1].Header.ContinuityCounter)
+		assert.Equal(t, uint8(1), ps[2].Header.ContinuityCounter)
+
+		assert.False(t, ps[1].Header.HasPayload)
+		assert.True(t, ps[1].Header.HasAdaptationField)
+		assert.True(t, ps[1].AdaptationField.DiscontinuityIndicator)
+		assert.Equal(t, 183, ps[1].AdaptationField.Length)
+	}
+}
+
+func TestMuxer_WriteDataByLabel(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	video, err := muxer.AddStream("video", PMTElementaryStream{StreamType: StreamTypeH264Video}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x0100), video)
+	audio, err := muxer.AddStream("audio-en", PMTElementaryStream{StreamType: StreamTypeAACAudio}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x0101), audio)
+	_, err = muxer.AddStream("video", PMTElementaryStream{StreamType: StreamTypeH264Video}, false)
+	assert.Equal(t, ErrLabelAlreadyExists, err)
+	assert.Equal(t, video, muxer.PMTData().PCRPID)
+
+	for _, label := range []string{"video", "audio-en"} {
+		_, err = muxer.WriteDataByLabel(label, &MuxerData{
+			PES: &PESData{
+				Data:   []byte(label),
+				Header: &PESHeader{OptionalHeader: &PESOptionalHeader{MarkerBits: 2, PTS: &ClockReference{}, PTSDTSIndicator: PTSDTSIndicatorOnlyPTS}},
+			},
+		})
+		assert.NoError(t, err)
+	}
+	_, err = muxer.WriteDataByLabel("audio-fr", &MuxerData{PES: &PESData{Header: &PESHeader{}}})
+	assert.Equal(t, ErrLabelNotFound, err)
+
+	pess := map[uint16][]byte{}
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PES != nil {
+			pess[d.PID] = d.PES.Data
+		}
+	}
+	assert.Equal(t, map[uint16][]byte{video: []byte("video"), audio: []byte("audio-en")}, pess)
+
+	// Labels are released with their stream
+	assert.NoError(t, muxer.RemoveElementaryStream(audio))
+	_, err = muxer.WriteDataByLabel("audio-en", &MuxerData{PES: &PESData{Header: &PESHeader{}}})
+	assert.Equal(t, ErrLabelNotFound, err)
+}
+
+func TestMuxer_AddFillerProgram(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	p, err := muxer.AddFillerProgram(5, "provider", "filler")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(5), p.ProgramNumber())
+	_, err = muxer.AddFillerProgram(5, "provider", "filler")
+	assert.Equal(t, ErrProgramNumberAlreadyExists, err)
+
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	var pat *PATData
+	var pmt *PMTData
+	var sdt *SDTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		switch {
+		case d.PAT != nil:
+			pat = d.PAT
+		case d.PMT != nil:
+			pmt = d.PMT
+		case d.SDT != nil:
+			sdt = d.SDT
+		}
+	}
+
+	if assert.NotNil(t, pat) {
+		assert.Equal(t, []*PATProgram{{ProgramMapID: pmtStartPID, ProgramNumber: 5}}, pat.Programs)
+	}
+	if assert.NotNil(t, pmt) {
+		assert.Equal(t, uint16(5), pmt.ProgramNumber)
+		assert.Equal(t, uint16(0x0100), pmt.PCRPID)
+		if assert.Len(t, pmt.ElementaryStreams, 2) {
+			v, a := pmt.ElementaryStreams[0], pmt.ElementaryStreams[1]
+			assert.Equal(t, uint16(0x0100), v.ElementaryPID)
+			assert.Equal(t, StreamTypeMPEG2Video, v.StreamType)
+			if assert.Len(t, v.ElementaryStreamDescriptors, 1) {
+				assert.Equal(t, uint8(1), v.ElementaryStreamDescriptors[0].StreamIdentifier.ComponentTag)
+			}
+			assert.Equal(t, uint16(0x0101), a.ElementaryPID)
+			assert.Equal(t, StreamTypeMPEG1Audio, a.StreamType)
+			if assert.Len(t, a.ElementaryStreamDescriptors, 2) {
+				assert.Equal(t, uint8(2), a.ElementaryStreamDescriptors[0].StreamIdentifier.ComponentTag)
+				assert.Equal(t, []byte("und"), a.ElementaryStreamDescriptors[1].ISO639LanguageAndAudioType.Language)
+			}
+		}
+	}
+	if assert.NotNil(t, sdt) && assert.Len(t, sdt.Services, 1) {
+		assert.Equal(t, uint16(5), sdt.Services[0].ServiceID)
+		assert.Equal(t, []byte("filler"), sdt.Services[0].Descriptors[0].Service.Name)
+	}
+
+	// A new program is added when the default one is in use
+	muxer = NewMuxer(context.Background(), &bytes.Buffer{})
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{StreamType: StreamTypeH264Video}))
+	_, err = muxer.AddFillerProgram(programNumberStart, "provider", "filler")
+	assert.Equal(t, ErrProgramNumberAlreadyExists, err)
+	p, err = muxer.AddFillerProgram(2, "provider", "filler")
+	assert.NoError(t, err)
+	assert.Equal(t, pmtStartPID+1, p.PMTPID())
+	assert.Equal(t, uint16(0x0101), p.PMTData().PCRPID)
+}
+
+func TestMuxer_AddProgram(t *testing.T) {
+	muxer := NewMuxer(context.Background(), nil)
+
+	_, err := muxer.AddProgram(programNumberStart)
+	assert.Equal(t, ErrProgramNumberAlreadyExists, err)
+
+	_, err = muxer.AddProgram(0)
+	assert.Equal(t, ErrProgramNumberInvalid, err)
+
+	p, err := muxer.AddProgram(2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(2), p.ProgramNumber())
+	assert.Equal(t, pmtStartPID+1, p.PMTPID())
+
+	err = p.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+
+	// PIDs must be unique across programs
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.Equal(t, ErrPIDAlreadyExists, err)
+}
+
+func TestMuxer_MultiplePrograms(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	p, err := muxer.AddProgram(2)
+	assert.NoError(t, err)
+	err = p.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0200,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	err = p.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0201,
+		StreamType:    StreamTypeAACAudio,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, p.SetPCRPID(0x0200))
+
+	n, err := muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, 3*MpegTsPacketSize, n)
+
+	var pat *PATData
+	pmts := map[uint16]*PMTData{}
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PAT != nil {
+			pat = d.PAT
+		}
+		if d.PMT != nil {
+			pmts[d.PID] = d.PMT
+		}
+	}
+
+	assert.Equal(t, []*PATProgram{
+		{ProgramMapID: pmtStartPID, ProgramNumber: 1},
+		{ProgramMapID: pmtStartPID + 1, ProgramNumber: 2},
+	}, pat.Programs)
+	assert.Len(t, pmts, 2)
+	assert.Equal(t, uint16(1), pmts[pmtStartPID].ProgramNumber)
+	assert.Len(t, pmts[pmtStartPID].ElementaryStreams, 1)
+	assert.Equal(t, uint16(2), pmts[pmtStartPID+1].ProgramNumber)
+	assert.Equal(t, uint16(0x0200), pmts[pmtStartPID+1].PCRPID)
+	assert.Len(t, pmts[pmtStartPID+1].ElementaryStreams, 2)
+}
+
+func TestMuxer_MultipleProgramsWithoutDefaultProgram(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	p, err := muxer.AddProgram(2)
+	assert.NoError(t, err)
+	err = p.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	err = p.SetPCRPID(0x0100)
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.Validate())
+
+	// Unused default program is left out of the PAT and has no PMT
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Data: testPayload(),
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{
+					MarkerBits:      2,
+					PTS:             &ClockReference{Base: 5726623060},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	var pat *PATData
+	pmts := map[uint16]*PMTData{}
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PAT != nil {
+			pat = d.PAT
+		}
+		if d.PMT != nil {
+			pmts[d.PID] = d.PMT
+		}
+	}
+	if assert.NotNil(t, pat) {
+		assert.Equal(t, []*PATProgram{{ProgramMapID: pmtStartPID + 1, ProgramNumber: 2}}, pat.Programs)
+	}
+	assert.Len(t, pmts, 1)
+	assert.NotNil(t, pmts[pmtStartPID+1])
+
+	// Default program is announced again as soon as it has an elementary stream
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0200,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	err = muxer.SetPCRPID(0x0200)
+	assert.NoError(t, err)
+	buf.Reset()
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+	pat = nil
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PAT != nil {
+			pat = d.PAT
+		}
+	}
+	if assert.NotNil(t, pat) {
+		assert.Len(t, pat.Programs, 2)
+	}
+}
+
+func TestMuxer_M2TS(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPacketSize(192))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	payload := testPayload()
+	pcr := ClockReference{
+		Base:      5726623061,
+		Extension: 341,
+	}
+	pts := ClockReference{Base: 5726623060}
+
+	n, err := muxer.WriteData(&MuxerData{
+		PID: 0x1234,
+		AdaptationField: &PacketAdaptationField{
+			HasPCR:                true,
+			PCR:                   &pcr,
+			RandomAccessIndicator: true,
+		},
+		PES: &PESData{
+			Data: payload,
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{
+					PTS:             &pts,
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, buf.Len(), n)
+	assert.Equal(t, 0, buf.Len()%192)
+
+	// Tables are written before any PCR is known, data packets carry the PCR based arrival timestamp
+	bs := buf.Bytes()
+	assert.Equal(t, []byte{0, 0, 0, 0}, bs[:4])
+	assert.Equal(t, patExpectedBytes(0), bs[4:192])
+	assert.Equal(t, pmtExpectedBytesVideoOnly(0), bs[196:384])
+	ats := uint32((5726623061*300 + 341) & 0x3fffffff)
+	for o := 384; o < len(bs); o += 192 {
+		assert.Equal(t, ats, uint32(bs[o])<<24|uint32(bs[o+1])<<16|uint32(bs[o+2])<<8|uint32(bs[o+3]))
+		assert.Equal(t, uint8(syncByte), bs[o+4])
+	}
+
+	// Packet size is auto detected
+	var pes *PESData
+	for _, d := range demuxAllData(t, bs) {
+		if d.PES != nil {
+			pes = d.PES
+		}
+	}
+	assert.NotNil(t, pes)
+	assert.Equal(t, payload, pes.Data)
+	assert.Equal(t, pts.Base, pes.Header.OptionalHeader.PTS.Base)
+}
+
+func TestMuxer_PacketSizeInvalid(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPacketSize(204))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	err = muxer.SetPCRPID(0x1234)
+	assert.NoError(t, err)
+
+	err = muxer.Validate()
+	assert.True(t, errors.Is(err, ErrPacketSizeInvalid))
+
+	n, err := muxer.WriteData(&MuxerData{
+		PID: 0x1234,
+		PES: &PESData{Data: testPayload()},
+	})
+	assert.True(t, errors.Is(err, ErrPacketSizeInvalid))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestMuxer_M2TSArrivalTimeFunc(t *testing.T) {
+	buf := bytes.Buffer{}
+	clock := uint64(0x3ffffffe)
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPacketSize(192), MuxerOptArrivalTimeFunc(func() uint64 {
+		clock++
+		return clock
+	}))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	n, err := muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, 2*192, n)
+
+	// Arrival timestamp is 30 bits and wraps around
+	bs := buf.Bytes()
+	assert.Equal(t, []byte{0x3f, 0xff, 0xff, 0xff}, bs[:4])
+	assert.Equal(t, []byte{0, 0, 0, 0}, bs[192:196])
+
+	var pmt *PMTData
+	for _, d := range demuxAllData(t, bs, DemuxerOptPacketSize(192)) {
+		if d.PMT != nil {
+			pmt = d.PMT
+		}
+	}
+	assert.NotNil(t, pmt)
+	assert.Equal(t, uint16(0x1234), pmt.PCRPID)
+}
+
+func TestMuxer_BytesWritten(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+	assert.Equal(t, int64(0), muxer.BytesWritten())
+
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2*MpegTsPacketSize), muxer.BytesWritten())
+
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x1234,
+		PES: &PESData{
+			Data:   testPayload(),
+			Header: &PESHeader{},
+		},
+	})
+	assert.NoError(t, err)
+	_, err = muxer.WritePacket(&Packet{Header: &PacketHeader{PID: PIDNull}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), muxer.BytesWritten())
+}
+
+func TestMuxer_WriteDataWithInfo(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPacketSize(192))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	d := &MuxerData{
+		PID: 0x1234,
+		PES: &PESData{
+			Data:   testPayload(),
+			Header: &PESHeader{},
+		},
+	}
+
+	// Tables are written first
+	i, err := muxer.WriteDataWithInfo(d)
+	assert.NoError(t, err)
+	assert.Equal(t, buf.Len(), i.BytesWritten)
+	assert.Equal(t, buf.Len()/192, i.PacketsWritten)
+	assert.Equal(t, 2, i.TablesWritten)
+	assert.Equal(t, 0, i.TablesOffset)
+
+	n := buf.Len()
+	i, err = muxer.WriteDataWithInfo(d)
+	assert.NoError(t, err)
+	assert.Equal(t, buf.Len()-n, i.BytesWritten)
+	assert.Equal(t, (buf.Len()-n)/192, i.PacketsWritten)
+	assert.Equal(t, 0, i.TablesWritten)
+	assert.Equal(t, -1, i.TablesOffset)
+}
+
+func TestMuxer_PayloadEndFunc(t *testing.T) {
+	buf := bytes.Buffer{}
+	var ends []int
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPayloadEndFunc(func(pid uint16) {
+		assert.Equal(t, uint16(0x1234), pid)
+		ends = append(ends, buf.Len())
+	}))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	// Called once per PES, right after its last packet
+	for i := 0; i < 2; i++ {
+		_, err = muxer.WriteData(&MuxerData{
+			PID: 0x1234,
+			PES: &PESData{
+				Data:   testPayload(),
+				Header: &PESHeader{},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, ends, i+1)
+		assert.Equal(t, buf.Len(), ends[i])
+	}
+	_, err = muxer.WriteNullPackets(1)
+	assert.NoError(t, err)
+	assert.Len(t, ends, 2)
+}
+
+func TestMuxer_WriteDataPTSDTS(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	// B-frames have a PTS after their DTS
+	h := &PESOptionalHeader{
+		DTS:             &ClockReference{Base: 8589934591},
+		MarkerBits:      2,
+		PTS:             &ClockReference{Base: 3003},
+		PTSDTSIndicator: PTSDTSIndicatorBothPresent,
+	}
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x1234,
+		PES: &PESData{
+			Data:   testPayload(),
+			Header: &PESHeader{OptionalHeader: h},
+		},
+	})
+	assert.NoError(t, err)
+
+	var pes *PESData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PES != nil {
+			pes = d.PES
+		}
+	}
+	if assert.NotNil(t, pes) {
+		assert.Equal(t, uint8(PTSDTSIndicatorBothPresent), pes.Header.OptionalHeader.PTSDTSIndicator)
+		assert.Equal(t, h.PTS, pes.Header.OptionalHeader.PTS)
+		assert.Equal(t, h.DTS, pes.Header.OptionalHeader.DTS)
+		assert.Equal(t, uint8(10), pes.Header.OptionalHeader.HeaderLength)
+		assert.Equal(t, testPayload(), pes.Data)
+	}
+}
+
+func TestMuxer_WriteDataHeaderOnly(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeMetadata,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// Without optional header, only tables are written
+	_, err = muxer.WriteData(&MuxerData{PID: 0x0100, PES: &PESData{Header: &PESHeader{}}})
+	assert.NoError(t, err)
+	tablesLen := buf.Len()
+
+	n, err := muxer.WriteData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{
+					MarkerBits:      2,
+					PTS:             &ClockReference{Base: 90000},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize, n)
+	assert.Equal(t, tablesLen+n, buf.Len())
+
+	var pess []*PESData
+	var packets int
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PES != nil {
+			pess = append(pess, d.PES)
+		}
+	}
+	for bs := buf.Bytes(); len(bs) >= MpegTsPacketSize; bs = bs[MpegTsPacketSize:] {
+		if rawPacketPID(bs) == 0x0100 {
+			packets++
+		}
+	}
+	assert.Equal(t, 1, packets)
+	if assert.Len(t, pess, 1) {
+		assert.Empty(t, pess[0].Data)
+		assert.Equal(t, int64(90000), pess[0].Header.OptionalHeader.PTS.Base)
+	}
+}
+
+func TestMuxer_WriteDataPacketLength(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x1234,
+		PES: &PESData{
+			Data: testPayload(),
+			Header: &PESHeader{
+				OptionalHeader:    &PESOptionalHeader{MarkerBits: 2},
+				WritePacketLength: true,
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	var pes *PESData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PES != nil {
+			pes = d.PES
+		}
+	}
+	if assert.NotNil(t, pes) {
+		// Optional header is 3 bytes long
+		assert.Equal(t, uint16(len(testPayload())+3), pes.Header.PacketLength)
+		assert.Equal(t, testPayload(), pes.Data)
+	}
+}
+
+func TestMuxer_Stats(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPacketSize(192))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x1234,
+		PES: &PESData{
+			Data:   make([]byte, 1000),
+			Header: &PESHeader{},
+		},
+	})
+	assert.NoError(t, err)
+	_, err = muxer.WriteNullPackets(2)
+	assert.NoError(t, err)
+	_, err = muxer.WriteTDT(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+
+	s := muxer.Stats()
+	assert.Equal(t, int64(buf.Len()), s.BytesWritten)
+	assert.Equal(t, int64(buf.Len()/192), s.Packets)
+	assert.Equal(t, int64(2), s.NullPackets)
+	assert.Equal(t, int64(3), s.TablePackets)
+	assert.Equal(t, map[uint16]int64{
+		PIDPAT:      1,
+		pmtStartPID: 1,
+		0x1234:      6,
+		PIDNull:     2,
+		PIDTDT:      1,
+	}, s.PIDPackets)
+
+	// Stats are a snapshot
+	s.PIDPackets[PIDPAT] = 10
+	assert.Equal(t, int64(1), muxer.Stats().PIDPackets[PIDPAT])
+}
+
+func TestMuxer_M2TSArrivalTimestampMonotonic(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPacketSize(192), MuxerOptTablesRetransmitPeriod(1))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	// PCRs are given in 27MHz ticks: the second one crosses the 30 bits boundary, the third one goes backwards
+	atss := []uint64{0x3ffffff0, 0x40000010, 0x40000000}
+	for _, ats := range atss {
+		_, err = muxer.WriteData(&MuxerData{
+			PID: 0x1234,
+			AdaptationField: &PacketAdaptationField{
+				HasPCR: true,
+				PCR:    &ClockReference{Base: int64(ats / 300), Extension: int64(ats % 300)},
+			},
+			PES: &PESData{
+				Data:   []byte("test"),
+				Header: &PESHeader{},
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	var got []uint32
+	bs := buf.Bytes()
+	for o := 0; o < len(bs); o += 192 {
+		got = append(got, uint32(bs[o])<<24|uint32(bs[o+1])<<16|uint32(bs[o+2])<<8|uint32(bs[o+3]))
+	}
+
+	// Tables are retransmitted before each data packet, using the arrival timestamp of the previous PCR
+	assert.Equal(t, []uint32{
+		0, 0, 0x3ffffff0,
+		0x3ffffff0, 0x3ffffff0, 0x10,
+		0x10, 0x10, 0x10,
+	}, got)
+}
+
+func TestMuxer_PMTMultiplePackets(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	for i := uint16(0); i < 64; i++ {
+		err := muxer.AddElementaryStream(PMTElementaryStream{
+			ElementaryPID: 0x0100 + i,
+			StreamType:    StreamTypeAACAudio,
+		})
+		assert.NoError(t, err)
+	}
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	n, err := muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, 3*MpegTsPacketSize, n)
+
+	var pmt *PMTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PMT != nil {
+			pmt = d.PMT
+		}
+	}
+	assert.NotNil(t, pmt)
+	assert.Len(t, pmt.ElementaryStreams, 64)
+	assert.Equal(t, uint16(0x013f), pmt.ElementaryStreams[63].ElementaryPID)
+	for i, es := range pmt.ElementaryStreams {
+		assert.Equal(t, 0x0100+uint16(i), es.ElementaryPID)
+		assert.Equal(t, StreamTypeAACAudio, es.StreamType)
+	}
+
+	// Only the first PMT packet starts the payload unit, continuity counter increments on the others
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()[MpegTsPacketSize:]))
+	for i := 0; i < 2; i++ {
+		p, err := dmx.NextPacket()
+		assert.NoError(t, err)
+		assert.Equal(t, pmtStartPID, p.Header.PID)
+		assert.Equal(t, i == 0, p.Header.PayloadUnitStartIndicator)
+		assert.Equal(t, uint8(i), p.Header.ContinuityCounter)
+	}
+}
+
+func TestMuxer_PMTDescriptors(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	ds := []*Descriptor{
+		{
+			Length:       4,
+			Registration: &DescriptorRegistration{FormatIdentifier: 0x41432d33}, // AC-3
+			Tag:          DescriptorTagRegistration,
+		},
+		{
+			AC3:    &DescriptorAC3{BSID: 8, HasBSID: true},
+			Length: 2,
+			Tag:    DescriptorTagAC3,
+		},
+		{
+			ISO639LanguageAndAudioType: &DescriptorISO639LanguageAndAudioType{Language: []byte("eng")},
+			Length:                     4,
+			Tag:                        DescriptorTagISO639LanguageAndAudioType,
+		},
+	}
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID:               0x0100,
+		ElementaryStreamDescriptors: ds,
+		StreamType:                  StreamTypePrivateData,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	var pmt *PMTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PMT != nil {
+			pmt = d.PMT
+		}
+	}
+	if assert.NotNil(t, pmt) && assert.Len(t, pmt.ElementaryStreams, 1) {
+		assert.Equal(t, ds, pmt.ElementaryStreams[0].ElementaryStreamDescriptors)
+	}
+}
+
+func TestMuxer_PATMultipleSections(t *testing.T) {
+	muxer := NewMuxer(context.Background(), nil)
+	for i := uint16(2); i <= 301; i++ {
+		_, err := muxer.AddProgram(i)
+		assert.NoError(t, err)
+	}
+
+	err := muxer.generatePAT()
+	assert.NoError(t, err)
+
+	var programs []*PATProgram
+	var sections int
+	for _, d := range demuxAllData(t, muxer.patBytes.Bytes()) {
+		if d.PAT != nil {
+			programs = append(programs, d.PAT.Programs...)
+			sections++
+		}
+	}
+	assert.Equal(t, 2, sections)
+	assert.Len(t, programs, 300)
+	assert.Equal(t, uint16(301), programs[299].ProgramNumber)
+}
+
+func TestMuxer_PATMultiplePackets(t *testing.T) {
+	muxer := NewMuxer(context.Background(), nil)
+	for i := uint16(2); i <= 60; i++ {
+		_, err := muxer.AddProgram(i)
+		assert.NoError(t, err)
+	}
+
+	err := muxer.generatePAT()
+	assert.NoError(t, err)
+	assert.Equal(t, 2*MpegTsPacketSize, muxer.patBytes.Len())
+
+	// Section is split across packets with a single payload unit start and incrementing continuity counters
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(muxer.patBytes.Bytes()))
+	for i := 0; i < 2; i++ {
+		p, err := dmx.NextPacket()
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(PIDPAT), p.Header.PID)
+		assert.Equal(t, i == 0, p.Header.PayloadUnitStartIndicator)
+		assert.Equal(t, uint8(i), p.Header.ContinuityCounter)
+	}
+}
+
+type testWriteCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (w *testWriteCloser) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestMuxer_RejectLatePTS(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptRejectLatePTS(true))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0234,
+		StreamType:    StreamTypeAACAudio,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	data := func(pid uint16, pts int64, af *PacketAdaptationField) *MuxerData {
+		return &MuxerData{
+			AdaptationField: af,
+			PID:             pid,
+			PES: &PESData{
+				Data: []byte("test"),
+				Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+					MarkerBits:      2,
+					PTS:             &ClockReference{Base: pts},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				}},
+			},
+		}
+	}
+
+	// PCR carried by the data itself
+	_, err = muxer.WriteData(data(0x1234, 90000, &PacketAdaptationField{HasPCR: true, PCR: &ClockReference{Base: 90001}}))
+	assert.Equal(t, ErrPTSBehindPCR, err)
+	assert.Equal(t, 0, buf.Len())
+	_, err = muxer.WriteData(data(0x1234, 90000, &PacketAdaptationField{HasPCR: true, PCR: &ClockReference{Base: 90000}}))
+	assert.NoError(t, err)
+
+	// Last PCR written on the PCR PID
+	n := buf.Len()
+	_, err = muxer.WriteData(data(0x0234, 89999, nil))
+	assert.Equal(t, ErrPTSBehindPCR, err)
+	assert.Equal(t, n, buf.Len())
+	_, err = muxer.WriteData(data(0x0234, 90000, nil))
+	assert.NoError(t, err)
+
+	// PTS wraps around
+	_, err = muxer.WritePCR(uint64(pcrBaseMask * 300))
+	assert.NoError(t, err)
+	_, err = muxer.WriteData(data(0x0234, 10, nil))
+	assert.NoError(t, err)
+}
+
+// testCancellingWriter cancels a context once a number of bytes have been written
+type testCancellingWriter struct {
+	bytes.Buffer
+	cancel context.CancelFunc
+	left   int
+}
+
+func (w *testCancellingWriter) Write(p []byte) (int, error) {
+	if w.left -= len(p); w.left <= 0 {
+		w.cancel()
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestMuxer_WriteDataContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &testCancellingWriter{cancel: cancel, left: 5 * MpegTsPacketSize}
+	muxer := NewMuxer(ctx, w)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	// Writing stops in between packets
+	d := &MuxerData{
+		PID: 0x1234,
+		PES: &PESData{
+			Data:   make([]byte, 100*MpegTsPacketSize),
+			Header: &PESHeader{},
+		},
+	}
+	n, err := muxer.WriteData(d)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 5*MpegTsPacketSize, n)
+	assert.Equal(t, n, w.Len())
+
+	// Nothing is written once the context is cancelled
+	n, err = muxer.WriteData(d)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 5*MpegTsPacketSize, w.Len())
+}
+
+func TestMuxer_Close(t *testing.T) {
+	w := &testWriteCloser{}
+	bw := bufio.NewWriter(w)
+	muxer := NewMuxer(context.Background(), bw, MuxerOptTablesOnClose(true))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	// Writer is flushed but not closed since bufio.Writer is not an io.Closer
+	err = muxer.Close()
+	assert.NoError(t, err)
+	assert.False(t, w.closed)
+	assert.Equal(t, append(patExpectedBytes(0), pmtExpectedBytesVideoOnly(0)...), w.Bytes())
+	assert.Equal(t, int64(2*MpegTsPacketSize), muxer.BytesWritten())
+
+	assert.Equal(t, ErrMuxerClosed, muxer.Close())
+	_, err = muxer.WriteTables()
+	assert.Equal(t, ErrMuxerClosed, err)
+	_, err = muxer.WriteData(&MuxerData{PID: 0x1234, PES: &PESData{Data: []byte("test"), Header: &PESHeader{}}})
+	assert.Equal(t, ErrMuxerClosed, err)
+
+	// io.Closer writers are closed
+	muxer = NewMuxer(context.Background(), w)
+	err = muxer.Close()
+	assert.NoError(t, err)
+	assert.True(t, w.closed)
+
+	// Pending data is reported
+	w = &testWriteCloser{}
+	muxer = NewMuxer(context.Background(), w)
+	_, err = muxer.WriteDemuxerData(&DemuxerData{PID: 0x1234, PES: &PESData{Data: []byte("test"), Header: &PESHeader{}}})
+	assert.NoError(t, err)
+	err = muxer.Close()
+	assert.True(t, errors.Is(err, ErrPendingDataDropped))
+	assert.True(t, w.closed)
+	assert.Empty(t, w.Bytes())
+}
+
+func TestMuxer_ForceTables(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		opts   []func(*Muxer)
+		tables bool
+	}{
+		{name: "default", tables: true},
+		{name: "payload only", opts: []func(*Muxer){MuxerOptForceTablesFunc(func(d *MuxerData, isPCRPID bool) bool {
+			return len(d.PES.Data) > 0 && MuxerForceTablesOnRandomAccess(d, isPCRPID)
+		})}},
+		{name: "disabled", opts: []func(*Muxer){MuxerOptForceTablesFunc(nil)}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			muxer := NewMuxer(context.Background(), &buf, c.opts...)
+			err := muxer.AddElementaryStream(PMTElementaryStream{
+				ElementaryPID: 0x1234,
+				StreamType:    StreamTypeH264Video,
+			})
+			assert.NoError(t, err)
+			require.NoError(t, muxer.SetPCRPID(0x1234))
+
+			_, err = muxer.WriteTables()
+			assert.NoError(t, err)
+
+			// PCR only data with random access indicator
+			n, err := muxer.WriteData(&MuxerData{
+				PID: 0x1234,
+				AdaptationField: &PacketAdaptationField{
+					HasPCR:                true,
+					PCR:                   &ClockReference{},
+					RandomAccessIndicator: true,
+				},
+				PES: &PESData{},
+			})
+			assert.NoError(t, err)
+			if c.tables {
+				assert.Equal(t, 2*MpegTsPacketSize, n)
+			} else {
+				assert.Equal(t, 0, n)
+			}
+		})
+	}
+}
+
+func TestMuxer_WriteDataAdaptationFieldPacket(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptTablesRetransmitPeriod(100))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	pts := &ClockReference{Base: 5726623060}
+	data := func(af *PacketAdaptationField) *MuxerData {
+		return &MuxerData{
+			PID:             0x1234,
+			AdaptationField: af,
+			PES: &PESData{
+				Data: []byte("test"),
+				Header: &PESHeader{
+					OptionalHeader: &PESOptionalHeader{
+						PTS:             pts,
+						PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+					},
+				},
+			},
+		}
+	}
+
+	// Small PES with PCR fits in a single packet
+	n, err := muxer.WriteData(data(&PacketAdaptationField{
+		HasPCR: true,
+		PCR:    &ClockReference{Base: 5726623061},
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize, n)
+
+	// Adaptation field leaving no room for the PES header is written in its own packet
+	buf.Reset()
+	n, err = muxer.WriteData(data(&PacketAdaptationField{
+		HasPCR:                     true,
+		HasTransportPrivateData:    true,
+		PCR:                        &ClockReference{Base: 5726623061},
+		TransportPrivateData:       bytes.Repeat([]byte{0x1}, 170),
+		TransportPrivateDataLength: 170,
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, 2*MpegTsPacketSize, n)
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	p, err := dmx.NextPacket()
+	assert.NoError(t, err)
+	assert.False(t, p.Header.HasPayload)
+	assert.True(t, p.AdaptationField.HasPCR)
+	assert.Equal(t, uint8(0), p.Header.ContinuityCounter)
+	p, err = dmx.NextPacket()
+	assert.NoError(t, err)
+	assert.True(t, p.Header.HasPayload)
+	assert.True(t, p.Header.PayloadUnitStartIndicator)
+	assert.Equal(t, uint8(1), p.Header.ContinuityCounter)
+}
+
+func TestMuxer_AddElementaryStreamAutoPID(t *testing.T) {
+	muxer := NewMuxer(context.Background(), nil)
+	err := muxer.AddElementaryStream(PMTElementaryStream{StreamType: StreamTypeH264Video})
+	assert.NoError(t, err)
+	err = muxer.AddElementaryStream(PMTElementaryStream{StreamType: StreamTypeAACAudio})
+	assert.NoError(t, err)
+	assert.Equal(t, startPID, muxer.defaultProgram.pmt.ElementaryStreams[0].ElementaryPID)
+	assert.Equal(t, startPID+1, muxer.defaultProgram.pmt.ElementaryStreams[1].ElementaryPID)
+
+	muxer = NewMuxer(context.Background(), nil, MuxerOptStartPID(0x0200))
+	err = muxer.AddElementaryStream(PMTElementaryStream{StreamType: StreamTypeH264Video})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x0200), muxer.defaultProgram.pmt.ElementaryStreams[0].ElementaryPID)
+}
+
+func TestMuxer_AddElementaryStreamSkipsReservedPIDs(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptStartPID(0))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0021,
+		StreamType:    StreamTypeAACAudio,
+	})
+	assert.NoError(t, err)
+	err = muxer.AddElementaryStream(PMTElementaryStream{StreamType: StreamTypeH264Video})
+	assert.NoError(t, err)
+	err = muxer.AddElementaryStream(PMTElementaryStream{StreamType: StreamTypeH264Video})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0021))
+
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	var pmt *PMTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PMT != nil {
+			pmt = d.PMT
+		}
+	}
+	assert.NotNil(t, pmt)
+	var pids []uint16
+	for _, es := range pmt.ElementaryStreams {
+		pids = append(pids, es.ElementaryPID)
+	}
+	assert.Equal(t, []uint16{0x0021, 0x0020, 0x0022}, pids)
+}
+
+type testFailingWriter struct {
+	bytes.Buffer
+	left int
+}
+
+func (w *testFailingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.left {
+		n, _ := w.Buffer.Write(p[:w.left])
+		w.left = 0
+		return n, errors.New("astits: test error")
+	}
+	w.left -= len(p)
+	return w.Buffer.Write(p)
+}
+
+func TestMuxer_WriteTablesRollback(t *testing.T) {
+	w := &testFailingWriter{left: 2*MpegTsPacketSize + 100}
+	muxer := NewMuxer(context.Background(), w)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	// Writer fails
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0234,
+		StreamType:    StreamTypeAACAudio,
+	})
+	assert.NoError(t, err)
+	_, err = muxer.WriteTables()
+	assert.Error(t, err)
+	assert.Equal(t, patExpectedBytes(0), muxer.patBytes.Bytes())
+	assert.Equal(t, pmtExpectedBytesVideoOnly(0), muxer.defaultProgram.pmtBytes.Bytes())
+	assert.True(t, muxer.defaultProgram.pmtDirty)
+
+	// PMT generation fails
+	p, err := muxer.AddProgram(2)
+	assert.NoError(t, err)
+	_, err = muxer.WriteTables()
+	assert.Equal(t, ErrPCRPIDInvalid, err)
+	assert.Equal(t, patExpectedBytes(0), muxer.patBytes.Bytes())
+	assert.True(t, muxer.patDirty)
+	assert.Equal(t, 0, p.pmtBytes.Len())
+
+	// Versions have not been consumed
+	w.left = 10 * MpegTsPacketSize
+	w.Reset()
+	err = muxer.RemoveElementaryStream(0x0234)
+	assert.NoError(t, err)
+	err = p.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0234,
+		StreamType:    StreamTypeAACAudio,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, p.SetPCRPID(0x0234))
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, patExpectedBytes(1)[10], w.Bytes()[10])
+	// CCs keep increasing
+	assert.Equal(t, uint8(2), w.Bytes()[3]&0xf)
+	assert.Equal(t, withCC(pmtExpectedBytesVideoOnly(1), 1)[:22], w.Bytes()[MpegTsPacketSize:MpegTsPacketSize+22])
+}
+
+func TestMuxer_TablesContinuityCounter(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	// Tables are retransmitted and regenerated more than 16 times
+	for i := 0; i < 20; i++ {
+		if i == 10 {
+			err = muxer.AddElementaryStream(PMTElementaryStream{
+				ElementaryPID: 0x0234,
+				StreamType:    StreamTypeAACAudio,
+			})
+			assert.NoError(t, err)
+		}
+		_, err = muxer.WriteTables()
+		assert.NoError(t, err)
+	}
+
+	ccs := map[uint16][]uint8{}
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		ccs[p.Header.PID] = append(ccs[p.Header.PID], p.Header.ContinuityCounter)
+	}
+	for _, pid := range []uint16{PIDPAT, pmtStartPID} {
+		if assert.Len(t, ccs[pid], 20) {
+			for i, cc := range ccs[pid] {
+				assert.Equal(t, uint8(i%16), cc)
+			}
+		}
+	}
+}
+
+func TestMuxer_WriteTablesAfterFailure(t *testing.T) {
+	w := &testFailingWriter{left: 2*MpegTsPacketSize + 100}
+	muxer := NewMuxer(context.Background(), w)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	// Writer fails while the new PMT is written
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0234,
+		StreamType:    StreamTypeAACAudio,
+	})
+	assert.NoError(t, err)
+	_, err = muxer.WriteTables()
+	assert.Error(t, err)
+
+	// Next successful write produces the prior valid tables, the PMT having been regenerated with a new version
+	w.left = 10 * MpegTsPacketSize
+	w.Reset()
+	err = muxer.RemoveElementaryStream(0x0234)
+	assert.NoError(t, err)
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, withCC(patExpectedBytes(0), 2), w.Bytes()[:MpegTsPacketSize])
+	assert.Equal(t, withCC(pmtExpectedBytesVideoOnly(1), 1)[:22], w.Bytes()[MpegTsPacketSize:MpegTsPacketSize+22])
+}
+
+func TestMuxer_WriteDemuxerData(t *testing.T) {
+	buf := &bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), buf)
+
+	pes := func(data string) *DemuxerData {
+		return &DemuxerData{
+			FirstPacket: &Packet{AdaptationField: &PacketAdaptationField{RandomAccessIndicator: true}},
+			PES: &PESData{
+				Data: []byte(data),
+				Header: &PESHeader{
+					OptionalHeader: &PESOptionalHeader{
+						MarkerBits:      2,
+						PTS:             newClockReference(5726623061, 0),
+						PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+					},
+					StreamID: 0xe0,
+				},
+			},
+			PID: 0x100,
+		}
+	}
+
+	// PES data arriving before the PMT is buffered
+	n, err := muxer.WriteDemuxerData(pes("first"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, err = muxer.WriteDemuxerData(&DemuxerData{PAT: &PATData{}})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = muxer.WriteDemuxerData(&DemuxerData{PMT: &PMTData{
+		ElementaryStreams: []*PMTElementaryStream{{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}},
+		PCRPID:            0x100,
+		ProgramNumber:     3,
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, buf.Len(), n)
+	assert.Empty(t, muxer.pendingData)
+
+	_, err = muxer.WriteDemuxerData(pes("second"))
+	assert.NoError(t, err)
+
+	var pat *PATData
+	var pmt *PMTData
+	var ds []*DemuxerData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		switch {
+		case d.PAT != nil:
+			pat = d.PAT
+		case d.PMT != nil:
+			pmt = d.PMT
+		case d.PES != nil:
+			ds = append(ds, d)
+		}
+	}
+	assert.Equal(t, []*PATProgram{{ProgramMapID: pmtStartPID, ProgramNumber: 3}}, pat.Programs)
+	assert.Equal(t, uint16(3), pmt.ProgramNumber)
+	assert.Equal(t, uint16(0x100), pmt.PCRPID)
+	if assert.Len(t, ds, 2) {
+		assert.Equal(t, []byte("first"), ds[0].PES.Data)
+		assert.True(t, ds[0].FirstPacket.AdaptationField.RandomAccessIndicator)
+		assert.Equal(t, []byte("second"), ds[1].PES.Data)
+	}
+}
+
+func TestMuxer_WriteDemuxerDataSpliceCountdown(t *testing.T) {
+	countdowns := func(bs []byte) (cs []int) {
+		dmx := NewDemuxer(context.Background(), bytes.NewReader(bs))
+		for {
+			p, err := dmx.NextPacket()
+			if err == ErrNoMorePackets {
+				return
+			}
+			assert.NoError(t, err)
+			if p.Header.PID == 0x100 && p.Header.HasPayload {
+				c := -128
+				if p.Header.HasAdaptationField && p.AdaptationField.HasSplicingCountdown {
+					c = p.AdaptationField.SpliceCountdown
+				}
+				cs = append(cs, c)
+			}
+		}
+	}
+
+	// Splice in the middle of the second PES out of three
+	in := &bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), in)
+	err := muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video})
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.SetPCRPID(0x100))
+	assert.NoError(t, muxer.ScheduleSplice(0x100, 8))
+	for i := 0; i < 3; i++ {
+		_, err = muxer.WriteData(&MuxerData{
+			PID: 0x100,
+			PES: &PESData{
+				Data: make([]byte, 1000),
+				Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+					MarkerBits:      2,
+					PTS:             &ClockReference{Base: int64(i)},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				}},
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	// Countdowns are reproduced
+	out := &bytes.Buffer{}
+	muxer = NewMuxer(context.Background(), out)
+	for _, d := range demuxAllData(t, in.Bytes()) {
+		_, err = muxer.WriteDemuxerData(d)
+		assert.NoError(t, err)
+	}
+	cs := countdowns(in.Bytes())
+	assert.Equal(t, []int{8, 7, 6, 5, 4, 3, 2, 1, 0}, cs[:9])
+	assert.Equal(t, cs, countdowns(out.Bytes()))
+}
+
+func TestMuxer_Validate(t *testing.T) {
+	muxer := NewMuxer(context.Background(), nil)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+
+	// No PCR PID
+	err = muxer.Validate()
+	assert.True(t, errors.Is(err, ErrPCRPIDInvalid))
+	assert.EqualError(t, err, "astits: program 1 has no elementary stream with PCR PID 0: astits: PCR PID invalid")
+
+	// PCR PID not in the program
+	err = muxer.SetPCRPID(0x1235)
+	assert.EqualError(t, err, "astits: PCR PID 4661 is not an elementary stream of program 1: astits: PCR PID invalid")
+
+	assert.NoError(t, muxer.SetPCRPID(0x1234))
+	assert.NoError(t, muxer.Validate())
+
+	// Programs without elementary streams are not checked
+	_, err = muxer.AddProgram(2)
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.Validate())
+
+	// PCR PID elementary stream removed
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1235,
+		StreamType:    StreamTypeAACAudio,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.RemoveElementaryStream(0x1234))
+	assert.EqualError(t, muxer.Validate(), "astits: program 1 has no elementary stream with PCR PID 4660: astits: PCR PID invalid")
+}
+
+func TestMuxer_WriteDataWithoutPCRPID(t *testing.T) {
+	buf := &bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+
+	d := &MuxerData{
+		PID: 0x1234,
+		PES: &PESData{
+			Data:   []byte("test"),
+			Header: &PESHeader{},
+		},
+	}
+	n, err := muxer.WriteData(d)
+	assert.True(t, errors.Is(err, ErrPCRPIDInvalid))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, buf.Len())
+
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+	_, err = muxer.WriteData(d)
+	assert.NoError(t, err)
+}
+
+func TestMuxer_WritePSISection(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+
+	s := &PSISection{
+		Header: &PSISectionHeader{
+			PrivateBit: true,
+			TableID:    0xc7,
+		},
+		Syntax: &PSISectionSyntax{Data: &PSISectionSyntaxData{Raw: []byte{1, 2, 3}}},
+	}
+	for i := 0; i < 2; i++ {
+		n, err := muxer.WritePSISection(0x1ffb, s)
+		assert.NoError(t, err)
+		assert.Equal(t, MpegTsPacketSize, n)
+	}
+	assert.Equal(t, uint16(0), s.Header.SectionLength)
+
+	bs := buf.Bytes()
+	assert.Equal(t, []byte{0x47, 0x5f, 0xfb, 0x10, 0x00, 0xc7, 0x70, 0x03, 1, 2, 3, 0xff}, bs[:12])
+	// Continuity counter is kept across sections
+	assert.Equal(t, byte(0x11), bs[MpegTsPacketSize+3])
+
+	_, err = muxer.WritePSISection(0x1234, s)
+	assert.Equal(t, ErrPIDAlreadyExists, err)
+	_, err = muxer.WritePSISection(pmtStartPID, s)
+	assert.Equal(t, ErrPIDAlreadyExists, err)
+}
+
+func TestMuxer_WriteRawPSI(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+
+	// Verbatim
+	n, err := muxer.WriteRawPSI(0x1ffa, 0xc7, []byte{0x30, 0x03, 1, 2, 3}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize, n)
+	assert.Equal(t, []byte{0x47, 0x5f, 0xfa, 0x10, 0x00, 0xc7, 0x30, 0x03, 1, 2, 3, 0xff}, buf.Bytes()[:12])
+
+	// Section length and CRC32 are computed, section spans several packets
+	buf.Reset()
+	section := bytes.Repeat([]byte{0x1}, 300)
+	n, err = muxer.WriteRawPSI(0x1ffa, 0xc7, section, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*MpegTsPacketSize, n)
+
+	bs := buf.Bytes()
+	// Continuity counter is kept across sections, only the first packet has the payload unit start indicator set
+	assert.Equal(t, []byte{0x47, 0x5f, 0xfa, 0x11}, bs[:4])
+	assert.Equal(t, []byte{0x47, 0x1f, 0xfa, 0x12}, bs[MpegTsPacketSize:MpegTsPacketSize+4])
+	assert.Equal(t, []byte{0x00, 0xc7, 0xf1, 0x30}, bs[4:8])
+	psi := append(append([]byte{}, bs[5:MpegTsPacketSize]...), bs[MpegTsPacketSize+4:]...)[:3+300+4]
+	assert.Equal(t, section, psi[3:303])
+	// CRC32 of a section including its CRC32 is 0
+	assert.Equal(t, uint32(0), computeCRC32(psi))
+
+	_, err = muxer.WriteRawPSI(0x1ffa, 0xc7, make([]byte, psiSectionMaxLength), true)
+	assert.Equal(t, ErrPSISectionTooLong, err)
+	_, err = muxer.WriteRawPSI(0x1234, 0xc7, section, true)
+	assert.Equal(t, ErrPIDAlreadyExists, err)
+}
+
+func TestMuxer_WriteATSCSTT(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	n, err := muxer.WriteATSCSTT(&ATSCSTTData{SystemTime: atscGPSEpoch})
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize, n)
+
+	bs := buf.Bytes()
+	assert.Equal(t, []byte{0x47, 0x5f, 0xfb, 0x10}, bs[:4])
+	// Table ID, section syntax indicator and private bit set, section length
+	assert.Equal(t, []byte{0x00, byte(PSITableIDSTT), 0xf0, 0x11}, bs[4:8])
+}
+
+func TestMuxer_SetServiceDescription(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	err = muxer.SetServiceDescription(2, "provider", "service", ServiceTypeDigitalTelevisionService)
+	assert.Equal(t, ErrProgramNumberNotFound, err)
+	err = muxer.SetServiceDescription(programNumberStart, "provider", "service", ServiceTypeDigitalTelevisionService)
+	assert.NoError(t, err)
+
+	n, err := muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, 3*MpegTsPacketSize, n)
+
+	var sdt *SDTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.SDT != nil {
+			assert.Equal(t, PIDSDT, d.PID)
+			sdt = d.SDT
+		}
+	}
+	if assert.NotNil(t, sdt) && assert.Len(t, sdt.Services, 1) {
+		s := sdt.Services[0]
+		assert.Equal(t, programNumberStart, s.ServiceID)
+		assert.Equal(t, uint8(RunningStatusRunning), s.RunningStatus)
+		if assert.Len(t, s.Descriptors, 1) {
+			assert.Equal(t, &DescriptorService{
+				Name:     []byte("service"),
+				Provider: []byte("provider"),
+				Type:     ServiceTypeDigitalTelevisionService,
+			}, s.Descriptors[0].Service)
+		}
+	}
+
+	// SDT is cached until the description changes
+	assert.False(t, muxer.sdtDirty)
+	muxer.defaultProgram.SetServiceDescription("provider", "other", ServiceTypeDigitalTelevisionService)
+	assert.True(t, muxer.sdtDirty)
+}
+
+func TestMuxer_SetServiceDescriptors(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	ds := []*Descriptor{
+		{
+			Tag:     0x53, // CA identifier
+			Unknown: &DescriptorUnknown{Content: []byte{0x0b, 0x00}, Tag: 0x53},
+		},
+		{
+			Content: &DescriptorContent{Items: []*DescriptorContentItem{{ContentNibbleLevel1: 1, ContentNibbleLevel2: 2}}},
+			Tag:     DescriptorTagContent,
+		},
+		{
+			ServiceMove: &DescriptorServiceMove{NewOriginalNetworkID: 1, NewServiceID: 3, NewTransportStreamID: 2},
+			Tag:         DescriptorTagServiceMove,
+		},
+	}
+	err = muxer.SetServiceDescriptors(2, ds)
+	assert.Equal(t, ErrProgramNumberNotFound, err)
+	err = muxer.SetServiceDescriptors(programNumberStart, ds)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(6), ds[2].Length)
+
+	// Service descriptor comes first and descriptors are kept when the description changes
+	err = muxer.SetServiceDescription(programNumberStart, "provider", "service", ServiceTypeDigitalTelevisionService)
+	assert.NoError(t, err)
+
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	var sdt *SDTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.SDT != nil {
+			sdt = d.SDT
+		}
+	}
+	if assert.NotNil(t, sdt) && assert.Len(t, sdt.Services, 1) {
+		s := sdt.Services[0]
+		assert.Equal(t, uint8(RunningStatusRunning), s.RunningStatus)
+		if assert.Len(t, s.Descriptors, 4) {
+			assert.Equal(t, []byte("service"), s.Descriptors[0].Service.Name)
+			assert.Equal(t, ds, s.Descriptors[1:])
+		}
+	}
+
+	// Service descriptor is kept when descriptors change
+	muxer.defaultProgram.SetServiceDescriptors(nil)
+	assert.True(t, muxer.sdtDirty)
+	if assert.Len(t, muxer.defaultProgram.service.Descriptors, 1) {
+		assert.Equal(t, uint8(DescriptorTagService), muxer.defaultProgram.service.Descriptors[0].Tag)
+	}
+}
+
+func TestMuxer_SetServiceRunningStatus(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+	err = muxer.SetServiceDescription(programNumberStart, "provider", "service", ServiceTypeDigitalTelevisionService)
+	assert.NoError(t, err)
+
+	d := &MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Data:   []byte("test"),
+			Header: &PESHeader{OptionalHeader: &PESOptionalHeader{MarkerBits: 2, PTS: &ClockReference{}, PTSDTSIndicator: PTSDTSIndicatorOnlyPTS}},
+		},
+	}
+	_, err = muxer.WriteData(d)
+	assert.NoError(t, err)
+
+	// Channel goes off air mid-stream
+	err = muxer.SetServiceRunningStatus(2, RunningStatusServiceOffAir)
+	assert.Equal(t, ErrProgramNumberNotFound, err)
+	err = muxer.SetServiceRunningStatus(programNumberStart, RunningStatusServiceOffAir)
+	assert.NoError(t, err)
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	var sdts []*SDTData
+	var versions []uint8
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.SDT != nil {
+			sdts = append(sdts, d.SDT)
+			versions = append(versions, d.FirstPacket.Payload[6]>>1&0x1f)
+		}
+	}
+	if assert.Len(t, sdts, 2) {
+		assert.Equal(t, uint8(RunningStatusRunning), sdts[0].Services[0].RunningStatus)
+		assert.Equal(t, uint8(RunningStatusServiceOffAir), sdts[1].Services[0].RunningStatus)
+		assert.Equal(t, []uint8{0, 1}, versions)
+		// Service description is kept
+		assert.Equal(t, sdts[0].Services[0].Descriptors, sdts[1].Services[0].Descriptors)
+	}
+}
+
+func TestMuxer_ServiceInfo(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptServiceInfo("provider", "service", ServiceTypeDigitalTelevisionService))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	// SDT is retransmitted along with other tables
+	for i := 0; i < 2; i++ {
+		_, err = muxer.WriteData(&MuxerData{
+			AdaptationField: &PacketAdaptationField{RandomAccessIndicator: true},
+			PES: &PESData{
+				Data: []byte("test"),
+				Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+					MarkerBits:      2,
+					PTS:             newClockReference(5726623061, 0),
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				}},
+			},
+			PID: 0x0100,
+		})
+		assert.NoError(t, err)
+	}
+
+	var sdt *SDTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.SDT != nil {
+			sdt = d.SDT
+		}
+	}
+	if assert.NotNil(t, sdt) {
+		assert.Equal(t, []byte("service"), sdt.Services[0].Descriptors[0].Service.Name)
+		assert.Equal(t, []byte("provider"), sdt.Services[0].Descriptors[0].Service.Provider)
+	}
+
+	var sdtPackets int
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PID == PIDSDT {
+			sdtPackets++
+		}
+	}
+	assert.Equal(t, 2, sdtPackets)
+}
+
+func TestMuxer_TablesPeriods(t *testing.T) {
+	// tablesPIDs returns the PIDs of PAT and PMT packets in the order they're written
+	tablesPIDs := func(bs []byte) (pids []uint16) {
+		for ; len(bs) >= MpegTsPacketSize; bs = bs[MpegTsPacketSize:] {
+			if pid := uint16(bs[1]&0x1f)<<8 | uint16(bs[2]); pid == PIDPAT || pid == pmtStartPID {
+				pids = append(pids, pid)
+			}
+		}
+		return
+	}
+
+	for _, c := range []struct {
+		name string
+		opts []func(*Muxer)
+		pids []uint16
+	}{
+		{
+			name: "combined",
+			opts: []func(*Muxer){MuxerOptTablesRetransmitPeriod(2)},
+			pids: []uint16{PIDPAT, pmtStartPID, PIDPAT, pmtStartPID, PIDPAT, pmtStartPID},
+		},
+		{
+			name: "pmt more frequent than pat",
+			opts: []func(*Muxer){MuxerOptPATPeriod(4), MuxerOptPMTPeriod(2)},
+			pids: []uint16{PIDPAT, pmtStartPID, pmtStartPID, PIDPAT, pmtStartPID},
+		},
+		{
+			name: "pat falls back to combined period",
+			opts: []func(*Muxer){MuxerOptTablesRetransmitPeriod(4), MuxerOptPMTPeriod(1)},
+			pids: []uint16{PIDPAT, pmtStartPID, pmtStartPID, pmtStartPID, pmtStartPID, PIDPAT, pmtStartPID},
+		},
+		{
+			name: "interval",
+			opts: []func(*Muxer){MuxerOptPATInterval(300 * time.Millisecond), MuxerOptPMTInterval(100 * time.Millisecond)},
+			pids: []uint16{PIDPAT, pmtStartPID, pmtStartPID, pmtStartPID, PIDPAT, pmtStartPID},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			muxer := NewMuxer(context.Background(), buf, c.opts...)
+			err := muxer.AddElementaryStream(PMTElementaryStream{
+				ElementaryPID: 0x1234,
+				StreamType:    StreamTypeMPEG1Audio,
+			})
+			assert.NoError(t, err)
+			require.NoError(t, muxer.SetPCRPID(0x1234))
+
+			// PCRs are 100ms apart
+			for i := 0; i < 5; i++ {
+				_, err = muxer.WriteData(&MuxerData{
+					PID: 0x1234,
+					AdaptationField: &PacketAdaptationField{
+						HasPCR: true,
+						PCR:    &ClockReference{Base: int64(i) * 9000},
+					},
+					PES: &PESData{Data: []byte("test"), Header: &PESHeader{}},
+				})
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, c.pids, tablesPIDs(buf.Bytes()))
+		})
+	}
+}
+
+func TestMuxer_SpreadTables(t *testing.T) {
+	buf := &bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), buf, MuxerOptTablesRetransmitPeriod(2), MuxerOptSpreadTables(true))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeMPEG1Audio,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	for i := 0; i < 5; i++ {
+		_, err = muxer.WriteData(&MuxerData{
+			PID: 0x1234,
+			PES: &PESData{Data: []byte("test"), Header: &PESHeader{}},
+		})
+		assert.NoError(t, err)
+	}
+
+	var pids []uint16
+	for bs := buf.Bytes(); len(bs) >= MpegTsPacketSize; bs = bs[MpegTsPacketSize:] {
+		pids = append(pids, uint16(bs[1]&0x1f)<<8|uint16(bs[2]))
+	}
+
+	// First tables are written all at once, PAT and PMT are then spread
+	assert.Equal(t, []uint16{
+		PIDPAT, pmtStartPID, 0x1234,
+		0x1234,
+		PIDPAT, 0x1234,
+		pmtStartPID, 0x1234,
+		PIDPAT, 0x1234,
+	}, pids)
+}
+
+func TestMuxer_SITablesRoundTrip(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptServiceInfo("provider", "service", ServiceTypeDigitalTelevisionService))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	stt := &ATSCSTTData{
+		DaylightSavingsDayOfMonth: 15,
+		DaylightSavingsHour:       2,
+		DaylightSavingsStatus:     true,
+		GPSUTCOffset:              18,
+		SystemTime:                time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC),
+	}
+	_, err = muxer.WriteATSCSTT(stt)
+	assert.NoError(t, err)
+	mgt := &ATSCMGTData{
+		Tables: []*ATSCMGTTable{{
+			NumberBytes:   42,
+			PID:           PIDATSCBase,
+			Type:          ATSCMGTTableTypeTVCTCurrent,
+			VersionNumber: 3,
+		}},
+		VersionNumber: 1,
+	}
+	_, err = muxer.WriteATSCMGT(mgt)
+	assert.NoError(t, err)
+	tvct := &ATSCTVCTData{
+		Channels: []*ATSCVirtualChannel{{
+			AccessControlled:   true,
+			ChannelTSID:        2,
+			ETMLocation:        1,
+			HideGuide:          true,
+			MajorChannelNumber: 7,
+			MinorChannelNumber: 1,
+			ModulationMode:     ATSCModulationModeATSC8,
+			ProgramNumber:      programNumberStart,
+			ServiceLocation: &ATSCServiceLocation{
+				Elements: []*ATSCServiceLocationElement{
+					{ElementaryPID: 0x100, StreamType: StreamTypeH264Video},
+					{ElementaryPID: 0x101, Language: []byte("eng"), StreamType: StreamTypeAC3Audio},
+				},
+				PCRPID: 0x100,
+			},
+			ServiceType: ATSCServiceTypeDigitalTelevision,
+			ShortName:   "KABC",
+			SourceID:    4,
+		}},
+		TransportStreamID: 2,
+		VersionNumber:     3,
+	}
+	_, err = muxer.WriteATSCTVCT(tvct)
+	assert.NoError(t, err)
+
+	var sdt *SDTData
+	var ds []*DemuxerData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		switch {
+		case d.SDT != nil:
+			sdt = d.SDT
+		case d.ATSCSTT != nil, d.ATSCMGT != nil, d.ATSCTVCT != nil:
+			assert.Equal(t, PIDATSCBase, d.PID)
+			ds = append(ds, d)
+		}
+	}
+	if assert.NotNil(t, sdt) && assert.Len(t, sdt.Services, 1) {
+		assert.Equal(t, muxer.defaultProgram.service, sdt.Services[0])
+	}
+	if assert.Len(t, ds, 3) {
+		assert.Equal(t, stt, ds[0].ATSCSTT)
+		assert.Equal(t, mgt, ds[1].ATSCMGT)
+		assert.Equal(t, tvct, ds[2].ATSCTVCT)
+	}
+}
+
+func TestMuxer_SetNetworkInformation(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	muxer.SetNetworkInformation(0x3001, "network", []*NITDataTransportStream{{
+		OriginalNetworkID: 0x3001,
+		TransportStreamID: 1,
+	}})
+	n, err := muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, 3*MpegTsPacketSize, n)
+
+	var pat *PATData
+	var nit *NITData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		switch {
+		case d.PAT != nil:
+			pat = d.PAT
+		case d.NIT != nil:
+			assert.Equal(t, PIDNIT, d.PID)
+			nit = d.NIT
+		}
+	}
+	if assert.NotNil(t, pat) {
+		assert.Equal(t, []*PATProgram{
+			{ProgramMapID: PIDNIT},
+			{ProgramMapID: pmtStartPID, ProgramNumber: programNumberStart},
+		}, pat.Programs)
+	}
+	if assert.NotNil(t, nit) {
+		assert.Equal(t, &NITData{
+			NetworkDescriptors: []*Descriptor{{
+				Length:      7,
+				NetworkName: &DescriptorNetworkName{Name: []byte("network")},
+				Tag:         DescriptorTagNetworkName,
+			}},
+			NetworkID: 0x3001,
+			TransportStreams: []*NITDataTransportStream{{
+				OriginalNetworkID: 0x3001,
+				TransportStreamID: 1,
+			}},
+		}, nit)
+	}
+
+	// NIT is cached until the network changes
+	assert.False(t, muxer.nitDirty)
+	assert.False(t, muxer.patDirty)
+	muxer.SetNetworkInformation(0x3001, "other", nil)
+	assert.True(t, muxer.nitDirty)
+	assert.False(t, muxer.patDirty)
+}
+
+func TestMuxer_SetOriginalNetworkID(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptServiceInfo("provider", "service", ServiceTypeDigitalTelevisionService))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	transportStreams := []*NITDataTransportStream{
+		{TransportStreamID: 0},
+		{OriginalNetworkID: 0x3002, TransportStreamID: 2},
+	}
+	muxer.SetNetworkInformation(0x3001, "network", transportStreams)
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+	muxer.SetOriginalNetworkID(0x3001)
+	assert.True(t, muxer.sdtDirty)
+	assert.True(t, muxer.nitDirty)
+	buf.Reset()
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	var nit *NITData
+	var sdt *SDTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		switch {
+		case d.NIT != nil:
+			nit = d.NIT
+		case d.SDT != nil:
+			sdt = d.SDT
+		}
+	}
+	if assert.NotNil(t, sdt) {
+		assert.Equal(t, uint16(0x3001), sdt.OriginalNetworkID)
+	}
+	if assert.NotNil(t, nit) && assert.Len(t, nit.TransportStreams, 2) {
+		// Only the entry describing the muxed stream is overridden
+		assert.Equal(t, uint16(0x3001), nit.TransportStreams[0].OriginalNetworkID)
+		assert.Equal(t, uint16(0x3002), nit.TransportStreams[1].OriginalNetworkID)
+	}
+
+	// Caller's data is left untouched
+	assert.Equal(t, uint16(0), transportStreams[0].OriginalNetworkID)
+}
+
+func TestMuxer_SetTransportStreamDescriptors(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x0100))
+
+	ds := []*Descriptor{{
+		Registration: &DescriptorRegistration{FormatIdentifier: 0x48444d56}, // HDMV
+		Tag:          DescriptorTagRegistration,
+	}}
+	muxer.SetTransportStreamDescriptors(ds)
+	n, err := muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, 3*MpegTsPacketSize, n)
+
+	var tsdts []*PSISection
+	for bs := buf.Bytes(); len(bs) >= MpegTsPacketSize; bs = bs[MpegTsPacketSize:] {
+		if rawPacketPID(bs) != PIDTSDT {
+			continue
+		}
+		assert.Equal(t, uint8(0x40), bs[1]&0x40) // payload unit start indicator
+		d, err := parsePSIData(astikit.NewBytesIterator(bs[4:MpegTsPacketSize]), false)
+		assert.NoError(t, err)
+		for _, s := range d.Sections {
+			if s.Header.TableID != PSITableIDNull {
+				tsdts = append(tsdts, s)
+			}
+		}
+	}
+	if assert.Len(t, tsdts, 1) {
+		assert.Equal(t, PSITableIDTSDT, tsdts[0].Header.TableID)
+		assert.True(t, tsdts[0].CRCValid)
+		assert.Equal(t, uint16(0xffff), tsdts[0].Syntax.Header.TableIDExtension)
+		assert.Equal(t, &TSDTData{Descriptors: []*Descriptor{{
+			Length:       4,
+			Registration: &DescriptorRegistration{FormatIdentifier: 0x48444d56},
+			Tag:          DescriptorTagRegistration,
+		}}}, tsdts[0].Syntax.Data.TSDT)
+	}
+
+	// TSDT is cached until descriptors change, and isn't written anymore without descriptors
+	assert.False(t, muxer.tsdtDirty)
+	muxer.SetTransportStreamDescriptors(nil)
+	assert.True(t, muxer.tsdtDirty)
+	n, err = muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, 2*MpegTsPacketSize, n)
+}
+
+func TestMuxer_WriteTDTAndTOT(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	utc := time.Date(2020, time.March, 1, 12, 30, 15, 0, time.UTC)
+	n, err := muxer.WriteTDT(utc.In(time.FixedZone("UTC+2", 2*3600)))
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize, n)
+	descriptors := []*Descriptor{{
+		Length: 13,
+		LocalTimeOffset: &DescriptorLocalTimeOffset{Items: []*DescriptorLocalTimeOffsetItem{{
+			CountryCode:  []byte("FRA"),
+			TimeOfChange: time.Date(2020, time.October, 25, 1, 0, 0, 0, time.UTC),
+		}}},
+		Tag: DescriptorTagLocalTimeOffset,
+	}}
+	n, err = muxer.WriteTOT(utc, descriptors)
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize, n)
+
+	bs := buf.Bytes()
+	// TDT has no CRC32 whereas TOT does
+	assert.Equal(t, []byte{byte(PSITableIDTDT), 0x70, 0x05}, bs[5:8])
+	assert.Equal(t, []byte{byte(PSITableIDTOT), 0x70, 0x1a}, bs[MpegTsPacketSize+5:MpegTsPacketSize+8])
+
+	var tdt *TDTData
+	var tot *TOTData
+	for _, d := range demuxAllData(t, bs) {
+		assert.Equal(t, PIDTDT, d.PID)
+		switch {
+		case d.TDT != nil:
+			tdt = d.TDT
+		case d.TOT != nil:
+			tot = d.TOT
+		}
+	}
+	assert.Equal(t, &TDTData{UTCTime: utc}, tdt)
+	assert.Equal(t, &TOTData{Descriptors: descriptors, UTCTime: utc}, tot)
+}
+
+func TestMuxer_PMTReservedBits(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPMTReservedBits(&ReservedBits{}))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, muxer.SetPCRPID(0x1234))
+
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	// PCR PID, program info length, elementary PID and es info length reserved bits are zeroed
+	bs := buf.Bytes()[MpegTsPacketSize:]
+	e := pmtExpectedBytesVideoOnly(0)
+	for _, i := range []int{13, 18} {
+		e[i] &= 0x1f
+	}
+	for _, i := range []int{15, 20} {
+		e[i] &= 0x0f
+	}
+	assert.Equal(t, e[:22], bs[:22])
+
+	// Demuxer still reads the PMT, CRC32 included
+	var pmt *PMTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PMT != nil {
+			pmt = d.PMT
+		}
+	}
+	if assert.NotNil(t, pmt) {
+		assert.Equal(t, uint16(0x1234), pmt.PCRPID)
+	}
+}
+
+func BenchmarkMuxer_WriteData(b *testing.B) {
+	muxer := NewMuxer(context.Background(), &bytes.Buffer{})
+	require.NoError(b, muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	}))
+	require.NoError(b, muxer.SetPCRPID(0x1234))
+
+	// Large video frame
+	d := &MuxerData{
+		PID: 0x1234,
+		PES: &PESData{
+			Data:   make([]byte, 4<<20),
+			Header: &PESHeader{},
+		},
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(d.PES.Data)))
+	for i := 0; i < b.N; i++ {
+		muxer.WriteData(d)
+	}
+}
+
+func TestMuxer_PMTData(t *testing.T) {
+	muxer := NewMuxer(context.Background(), &bytes.Buffer{})
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryStreamDescriptors: []*Descriptor{{Tag: DescriptorTagISO639LanguageAndAudioType}},
+		StreamType:                  StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	err = muxer.SetPCRPID(0x0100)
+	assert.NoError(t, err)
+
+	d := muxer.PMTData()
+	assert.Equal(t, programNumberStart, d.ProgramNumber)
+	assert.Equal(t, uint16(0x0100), d.PCRPID)
+	if assert.Len(t, d.ElementaryStreams, 1) {
+		assert.Equal(t, uint16(0x0100), d.ElementaryStreams[0].ElementaryPID)
+		assert.Equal(t, StreamTypeH264Video, d.ElementaryStreams[0].StreamType)
+	}
+
+	// Modifying the copy doesn't alter the muxer
+	d.PCRPID = 0x0200
+	d.ElementaryStreams[0].ElementaryPID = 0x0200
+	d.ElementaryStreams[0].ElementaryStreamDescriptors[0].Tag = DescriptorTagAC3
+	d.ElementaryStreams = append(d.ElementaryStreams, &PMTElementaryStream{})
+	assert.Equal(t, uint16(0x0100), muxer.PMTData().PCRPID)
+	assert.Equal(t, uint16(0x0100), muxer.PMTData().ElementaryStreams[0].ElementaryPID)
+	assert.Equal(t, uint8(DescriptorTagISO639LanguageAndAudioType), muxer.PMTData().ElementaryStreams[0].ElementaryStreamDescriptors[0].Tag)
+	assert.Len(t, muxer.PMTData().ElementaryStreams, 1)
+}
+
+func TestMuxer_SubtitlingDescriptorRoundTrip(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.SetPCRPID(0x0100))
+
+	subtitling := &DescriptorSubtitling{Items: []*DescriptorSubtitlingItem{
+		{
+			AncillaryPageID:   1,
+			CompositionPageID: 2,
+			Language:          []byte("eng"),
+			Type:              0x10,
+		},
+		{
+			AncillaryPageID:   3,
+			CompositionPageID: 4,
+			Language:          []byte("fra"),
+			Type:              0x20,
+		},
+	}}
+	err = muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0101,
+		ElementaryStreamDescriptors: []*Descriptor{{
+			Tag:        DescriptorTagSubtitling,
+			Subtitling: subtitling,
+		}},
+		StreamType: StreamTypePrivateData,
+	})
+	assert.NoError(t, err)
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	var pmt *PMTData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PMT != nil {
+			pmt = d.PMT
+		}
+	}
+	if assert.NotNil(t, pmt) && assert.Len(t, pmt.ElementaryStreams, 2) {
+		es := pmt.ElementaryStreams[1]
+		assert.Equal(t, uint16(0x0101), es.ElementaryPID)
+		if assert.Len(t, es.ElementaryStreamDescriptors, 1) {
+			assert.Equal(t, uint8(16), es.ElementaryStreamDescriptors[0].Length)
+			assert.Equal(t, subtitling, es.ElementaryStreamDescriptors[0].Subtitling)
+		}
+	}
+}
+
+func TestMuxer_PATPID(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPATPID(0x1ff0))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.SetPCRPID(0x0100))
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	_, err = muxer.WriteRawPSI(0x1ff0, 0x80, []byte{0x1}, true)
+	assert.Equal(t, ErrPIDAlreadyExists, err)
+
+	var pats int
+	for bs := buf.Bytes(); len(bs) >= MpegTsPacketSize; bs = bs[MpegTsPacketSize:] {
+		pid := rawPacketPID(bs)
+		assert.NotEqual(t, PIDPAT, pid)
+		if pid == 0x1ff0 {
+			// Pointer field and table id
+			assert.Equal(t, []byte{0x00, byte(PSITableIDPAT)}, bs[4:6])
+			pats++
+		}
+	}
+	assert.Equal(t, 1, pats)
+}
+
+func TestMuxer_SetScramblingControl(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	assert.Equal(t, ErrPIDNotFound, muxer.SetScramblingControl(0x0100, ScramblingControlScrambledWithEvenKey))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.SetPCRPID(0x0100))
+	assert.Equal(t, ErrScramblingControlInvalid, muxer.SetScramblingControl(0x0100, 4))
+	assert.NoError(t, muxer.SetScramblingControl(0x0100, ScramblingControlScrambledWithOddKey))
+
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x0100,
+		PES: &PESData{
+			Data:   bytes.Repeat([]byte{0x1}, 300),
+			Header: &PESHeader{},
+		},
+	})
+	assert.NoError(t, err)
+
+	var payloads int
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PID == 0x0100 {
+			assert.Equal(t, uint8(ScramblingControlScrambledWithOddKey), p.Header.TransportScramblingControl)
+			payloads++
+		} else {
+			// Tables are never scrambled
+			assert.Equal(t, uint8(ScramblingControlNotScrambled), p.Header.TransportScramblingControl)
+		}
+	}
+	assert.Equal(t, 2, payloads)
+}
+
+func TestMuxer_SetPacketization(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	assert.Equal(t, ErrPIDNotFound, muxer.SetPacketization(0x0100, MuxerPacketizationAligned))
+
+	for _, pid := range []uint16{0x0100, 0x0101} {
+		err := muxer.AddElementaryStream(PMTElementaryStream{
+			ElementaryPID: pid,
+			StreamType:    StreamTypeH264Video,
+		})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, muxer.SetPCRPID(0x0100))
+	assert.NoError(t, muxer.SetPacketization(0x0101, MuxerPacketizationAligned))
+
+	data := bytes.Repeat([]byte{0x1}, 300)
+	h := &PESOptionalHeader{
+		MarkerBits:      2,
+		PTS:             &ClockReference{Base: 3003},
+		PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+	}
+	for _, pid := range []uint16{0x0100, 0x0101} {
+		_, err := muxer.WriteData(&MuxerData{
+			PID: pid,
+			PES: &PESData{
+				Data:   data,
+				Header: &PESHeader{OptionalHeader: h},
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	// Aligned PES data starts in the packet following the PES header
+	payloads := map[uint16][][]byte{}
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		payloads[p.Header.PID] = append(payloads[p.Header.PID], p.Payload)
+	}
+	assert.Len(t, payloads[0x0100], 2)
+	assert.Len(t, payloads[0x0101], 3)
+	assert.Len(t, payloads[0x0101][0], pesHeaderLength+int(calcPESOptionalHeaderLength(h)))
+	assert.Equal(t, data, bytes.Join(payloads[0x0101][1:], nil))
+
+	// PES are demuxed the same way
+	var ds [][]byte
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PES != nil {
+			ds = append(ds, d.PES.Data)
+		}
+	}
+	assert.Equal(t, [][]byte{data, data}, ds)
+}
+
+func TestMuxer_ScheduleSplice(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	assert.Equal(t, ErrPIDNotFound, muxer.ScheduleSplice(0x0100, 3))
+
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x0100,
+		StreamType:    StreamTypeH264Video,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, muxer.SetPCRPID(0x0100))
+	assert.Equal(t, ErrSpliceCountdownInvalid, muxer.ScheduleSplice(0x0100, 128))
+	assert.NoError(t, muxer.ScheduleSplice(0x0100, 3))
+
+	payload := make([]byte, 1000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	af := &PacketAdaptationField{
+		HasPCR:                true,
+		PCR:                   &ClockReference{Base: 900000},
+		RandomAccessIndicator: true,
+	}
+	_, err = muxer.WriteData(&MuxerData{
+		AdaptationField: af,
+		PID:             0x0100,
+		PES: &PESData{
+			Data: payload,
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{
+					MarkerBits:      2,
+					PTS:             &ClockReference{Base: 900000},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	// Caller's adaptation field is left untouched
+	assert.False(t, af.HasSplicingCountdown)
+
+	var countdowns []int
+	var packets int
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PID != 0x0100 {
+			continue
+		}
+		packets++
+		if p.Header.HasAdaptationField && p.AdaptationField.HasSplicingCountdown {
+			countdowns = append(countdowns, p.AdaptationField.SpliceCountdown)
+			if packets == 1 {
+				// PCR and splice countdown share the adaptation field
+				assert.True(t, p.AdaptationField.HasPCR)
+			}
+		}
+	}
+	assert.True(t, packets > 4)
+	assert.Equal(t, []int{3, 2, 1, 0}, countdowns)
+
+	// PES is not corrupted by splice countdowns
+	var pes *PESData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PES != nil {
+			pes = d.PES
+		}
+	}
+	if assert.NotNil(t, pes) {
+		assert.Equal(t, payload, pes.Data)
+	}
+}
+
+func TestMuxer_WriteDataRawOptionalHeader(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeMPEG1Audio})
+	assert.NoError(t, err)
+	err = muxer.SetPCRPID(0x100)
+	assert.NoError(t, err)
+
+	// Data alignment, PTS of 0 and 3 stuffing bytes
+	raw := []byte{0x84, 0x80, 0x8, 0x21, 0x0, 0x1, 0x0, 0x1, 0xff, 0xff, 0xff}
+	payload := []byte{0x1, 0x2, 0x3}
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x100,
+		PES: &PESData{
+			Data:   payload,
+			Header: &PESHeader{RawOptionalHeader: raw, StreamID: 0xc0},
+		},
+	})
+	assert.NoError(t, err)
+
+	var pes *PESData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		if d.PES != nil {
+			pes = d.PES
+		}
+	}
+	if assert.NotNil(t, pes) {
+		assert.Equal(t, payload, pes.Data)
+		assert.Equal(t, uint16(len(raw)+len(payload)), pes.Header.PacketLength)
+		assert.True(t, pes.Header.OptionalHeader.DataAlignmentIndicator)
+		assert.Equal(t, uint8(8), pes.Header.OptionalHeader.HeaderLength)
+		assert.Equal(t, &ClockReference{}, pes.Header.OptionalHeader.PTS)
+	}
+
+	// PES_header_data_length must match
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x100,
+		PES: &PESData{
+			Data:   payload,
+			Header: &PESHeader{RawOptionalHeader: raw[:10], StreamID: 0xc0},
+		},
+	})
+	assert.True(t, errors.Is(err, ErrRawOptionalHeaderInvalid))
+
+	// Raw optional header must fit in the first packet
+	rawOfLength := func(n int) []byte {
+		return append([]byte{0x80, 0x0, uint8(n - 3)}, bytes.Repeat([]byte{0xff}, n-3)...)
+	}
+	for _, af := range []*PacketAdaptationField{nil, {RandomAccessIndicator: true}} {
+		buf.Reset()
+		_, err = muxer.WriteData(&MuxerData{
+			AdaptationField: af,
+			PID:             0x100,
+			PES: &PESData{
+				Data:   payload,
+				Header: &PESHeader{RawOptionalHeader: rawOfLength(203), StreamID: 0xc0},
+			},
+		})
+		assert.True(t, errors.Is(err, ErrRawOptionalHeaderInvalid))
+		assert.Equal(t, 0, buf.Len())
+	}
+	_, err = muxer.WriteData(&MuxerData{
+		AdaptationField: &PacketAdaptationField{RandomAccessIndicator: true},
+		PID:             0x100,
+		PES: &PESData{
+			Data:   payload,
+			Header: &PESHeader{RawOptionalHeader: rawOfLength(177), StreamID: 0xc0},
+		},
+	})
+	assert.True(t, errors.Is(err, ErrRawOptionalHeaderInvalid))
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x100,
+		PES: &PESData{
+			Data:   payload,
+			Header: &PESHeader{RawOptionalHeader: rawOfLength(178), StreamID: 0xc0},
+		},
+	})
+	assert.NoError(t, err)
+}
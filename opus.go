@@ -0,0 +1,169 @@
+package astits
+
+import (
+	"fmt"
+)
+
+// Opus is carried as private data, its elementary streams being described by a registration descriptor and an Opus
+// extension descriptor, and its PES data being made of access units each starting with a control header
+// Link: https://opus-codec.org/docs/ETSI_TS_opus-v0.1.3-draft.pdf
+const (
+	opusControlHeaderPrefix = 0x7fe0 // 11 bits 0x3ff
+	opusTrimMask            = 0x1fff
+)
+
+// OpusDescriptors returns the descriptors of an Opus elementary stream, whose stream type must be
+// StreamTypePrivateData, see DescriptorExtensionOpus for channel config codes
+func OpusDescriptors(channelConfigCode uint8) []*Descriptor {
+	ds := []*Descriptor{
+		{
+			Registration: &DescriptorRegistration{FormatIdentifier: RegistrationFormatIdentifierOpus},
+			Tag:          DescriptorTagRegistration,
+		},
+		{
+			Extension: &DescriptorExtension{
+				Opus: &DescriptorExtensionOpus{ChannelConfigCode: channelConfigCode},
+				Tag:  DescriptorTagExtensionOpus,
+			},
+			Tag: DescriptorTagExtension,
+		},
+	}
+	// Lengths are set so that the descriptors match the ones read back by the demuxer
+	for _, d := range ds {
+		d.Length = calcDescriptorLength(d)
+	}
+	return ds
+}
+
+// IsOpus checks whether the elementary stream is an Opus one, which is private data with an Opus registration
+// descriptor
+func (es *PMTElementaryStream) IsOpus() bool {
+	if es.StreamType != StreamTypePrivateData {
+		return false
+	}
+	for _, d := range es.ElementaryStreamDescriptors {
+		if d.Tag == DescriptorTagRegistration && d.Registration != nil && d.Registration.FormatIdentifier == RegistrationFormatIdentifierOpus {
+			return true
+		}
+	}
+	return false
+}
+
+// OpusAccessUnit represents an Opus packet found in the PES data of an Opus elementary stream
+// Trims are the number of samples, at 48kHz, to discard at the start or the end of the packet, 0 meaning none
+type OpusAccessUnit struct {
+	Data      []byte
+	EndTrim   uint16 // 13 bits
+	StartTrim uint16 // 13 bits
+}
+
+// WriteOpusAccessUnits frames Opus packets into PES data, each of them being prefixed with its control header
+func WriteOpusAccessUnits(aus []*OpusAccessUnit) (data []byte, err error) {
+	for idx, au := range aus {
+		if au.StartTrim > opusTrimMask || au.EndTrim > opusTrimMask {
+			err = fmt.Errorf("astits: trims of Opus access unit #%d are %d and %d: %w", idx, au.StartTrim, au.EndTrim, ErrAudioFrameInvalid)
+			return
+		}
+
+		// Prefix and flags
+		h := uint16(opusControlHeaderPrefix)
+		if au.StartTrim > 0 {
+			h |= 0x10
+		}
+		if au.EndTrim > 0 {
+			h |= 0x8
+		}
+		data = append(data, byte(h>>8), byte(h))
+
+		// Size is a sum of bytes, the last one being lower than 0xff
+		for n := len(au.Data); ; n -= 0xff {
+			if n < 0xff {
+				data = append(data, byte(n))
+				break
+			}
+			data = append(data, 0xff)
+		}
+
+		// Trims
+		if au.StartTrim > 0 {
+			data = append(data, byte(au.StartTrim>>8), byte(au.StartTrim))
+		}
+		if au.EndTrim > 0 {
+			data = append(data, byte(au.EndTrim>>8), byte(au.EndTrim))
+		}
+		data = append(data, au.Data...)
+	}
+	return
+}
+
+// ParseOpusAccessUnits splits the PES data of an Opus elementary stream into access units, whose data points to the
+// PES data. Control extensions are skipped.
+func ParseOpusAccessUnits(data []byte) (aus []*OpusAccessUnit, err error) {
+	for offset := 0; offset < len(data); {
+		// Get next bytes
+		next := func(n int) (bs []byte, err error) {
+			if offset+n > len(data) {
+				err = fmt.Errorf("astits: Opus access unit needs %d bytes at offset %d but only %d bytes are left: %w", n, offset, len(data)-offset, ErrAudioFrameInvalid)
+				return
+			}
+			bs = data[offset : offset+n]
+			offset += n
+			return
+		}
+
+		// Prefix and flags
+		var bs []byte
+		if bs, err = next(2); err != nil {
+			return
+		}
+		h := uint16(bs[0])<<8 | uint16(bs[1])
+		if h&0xffe0 != opusControlHeaderPrefix {
+			err = fmt.Errorf("astits: Opus control header prefix not found at offset %d: %w", offset-2, ErrAudioFrameInvalid)
+			return
+		}
+
+		// Size
+		size := 0
+		for {
+			if bs, err = next(1); err != nil {
+				return
+			}
+			size += int(bs[0])
+			if bs[0] < 0xff {
+				break
+			}
+		}
+
+		// Trims
+		au := &OpusAccessUnit{}
+		if h&0x10 > 0 {
+			if bs, err = next(2); err != nil {
+				return
+			}
+			au.StartTrim = (uint16(bs[0])<<8 | uint16(bs[1])) & opusTrimMask
+		}
+		if h&0x8 > 0 {
+			if bs, err = next(2); err != nil {
+				return
+			}
+			au.EndTrim = (uint16(bs[0])<<8 | uint16(bs[1])) & opusTrimMask
+		}
+
+		// Control extension
+		if h&0x4 > 0 {
+			if bs, err = next(1); err != nil {
+				return
+			}
+			if _, err = next(int(bs[0])); err != nil {
+				return
+			}
+		}
+
+		// Data
+		if au.Data, err = next(size); err != nil {
+			return
+		}
+		aus = append(aus, au)
+	}
+	return
+}
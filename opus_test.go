@@ -0,0 +1,87 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpusAccessUnits(t *testing.T) {
+	aus := []*OpusAccessUnit{
+		{Data: bytes.Repeat([]byte{0x1}, 300), StartTrim: 312},
+		{Data: []byte{0x2, 0x3}, EndTrim: 0x1fff},
+	}
+	data, err := WriteOpusAccessUnits(aus)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x7f, 0xf0, 0xff, 0x2d, 0x1, 0x38}, data[:6])
+	assert.Equal(t, []byte{0x7f, 0xe8, 0x2, 0x1f, 0xff, 0x2, 0x3}, data[306:])
+	paus, err := ParseOpusAccessUnits(data)
+	assert.NoError(t, err)
+	assert.Equal(t, aus, paus)
+
+	// Control extension is skipped
+	paus, err = ParseOpusAccessUnits([]byte{0x7f, 0xe4, 0x1, 0x2, 0xa, 0xb, 0x4})
+	assert.NoError(t, err)
+	assert.Equal(t, []*OpusAccessUnit{{Data: []byte{0x4}}}, paus)
+
+	// Errors
+	_, err = WriteOpusAccessUnits([]*OpusAccessUnit{{StartTrim: 0x2000}})
+	assert.True(t, errors.Is(err, ErrAudioFrameInvalid))
+	_, err = ParseOpusAccessUnits([]byte{0x7f, 0xc0, 0x0})
+	assert.True(t, errors.Is(err, ErrAudioFrameInvalid))
+	_, err = ParseOpusAccessUnits(data[:len(data)-1])
+	assert.True(t, errors.Is(err, ErrAudioFrameInvalid))
+}
+
+func TestOpusElementaryStream(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID:               0x100,
+		ElementaryStreamDescriptors: OpusDescriptors(2),
+		StreamType:                  StreamTypePrivateData,
+	})
+	assert.NoError(t, err)
+	err = muxer.SetPCRPID(0x100)
+	assert.NoError(t, err)
+
+	aus := []*OpusAccessUnit{{Data: []byte{0xfc, 0xff, 0xfe}}}
+	data, err := WriteOpusAccessUnits(aus)
+	assert.NoError(t, err)
+	_, err = muxer.WriteData(&MuxerData{
+		PID: 0x100,
+		PES: &PESData{
+			Data: data,
+			Header: &PESHeader{
+				OptionalHeader: &PESOptionalHeader{MarkerBits: 2, PTS: &ClockReference{Base: 1}, PTSDTSIndicator: PTSDTSIndicatorOnlyPTS},
+				StreamID:       StreamTypePrivateData.ToPESStreamID(),
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	var pmt *PMTData
+	var pes *PESData
+	for _, d := range demuxAllData(t, buf.Bytes()) {
+		switch {
+		case d.PMT != nil:
+			pmt = d.PMT
+		case d.PES != nil:
+			pes = d.PES
+		}
+	}
+	if assert.NotNil(t, pmt) && assert.Len(t, pmt.ElementaryStreams, 1) {
+		es := pmt.ElementaryStreams[0]
+		assert.True(t, es.IsOpus())
+		assert.Equal(t, OpusDescriptors(2), es.ElementaryStreamDescriptors)
+	}
+	if assert.NotNil(t, pes) {
+		paus, err := ParseOpusAccessUnits(pes.Data)
+		assert.NoError(t, err)
+		assert.Equal(t, aus, paus)
+	}
+	assert.False(t, (&PMTElementaryStream{StreamType: StreamTypePrivateData}).IsOpus())
+}
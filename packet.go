@@ -25,6 +25,7 @@ type Packet struct {
 	AdaptationField *PacketAdaptationField
 	Header          *PacketHeader
 	Payload         []byte // This is only the payload content
+	Raw             []byte // Only set when demuxing with DemuxerOptKeepRawPackets. Packet bytes as read, TP_extra_header included.
 }
 
 // PacketHeader represents a packet header
@@ -55,6 +56,7 @@ type PacketAdaptationField struct {
 	OPCR                              *ClockReference // Original Program clock reference. Helps when one TS is copied into another
 	PCR                               *ClockReference // Program clock reference
 	RandomAccessIndicator             bool            // Set when the stream may be decoded without errors from this point
+	ReservedBits                      *ReservedBits   // Only used when writing, spec values are used when nil. Applies to the extension field as well
 	SpliceCountdown                   int             // Indicates how many TS packets from this one a splicing point occurs (Two's complement signed; may be negative)
 	TransportPrivateDataLength        int
 	TransportPrivateData              []byte
@@ -83,9 +85,25 @@ func parsePacket(i *astikit.BytesIterator) (p *Packet, err error) {
 	}
 
 	// Packet must start with a sync byte
+	// 192 bytes M2TS packets are the exception: they start with a 4 bytes TP_extra_header instead
 	if b != syncByte {
-		err = ErrPacketMustStartWithASyncByte
-		return
+		if i.Len() <= MpegTsPacketSize {
+			err = ErrPacketMustStartWithASyncByte
+			return
+		}
+
+		// Get sync byte
+		i.Seek(i.Len() - MpegTsPacketSize)
+		if b, err = i.NextByte(); err != nil {
+			err = fmt.Errorf("astits: getting next byte failed: %w", err)
+			return
+		}
+
+		// Sync byte must follow the TP_extra_header
+		if b != syncByte {
+			err = ErrPacketMustStartWithASyncByte
+			return
+		}
 	}
 
 	// Create packet
@@ -316,6 +334,34 @@ func parsePCR(i *astikit.BytesIterator) (cr *ClockReference, err error) {
 }
 
 func writePacket(w *astikit.BitsWriter, p *Packet, targetPacketSize int) (written int, retErr error) {
+	if written, retErr = writePacketHeaderAndAdaptationField(w, p); retErr != nil {
+		return
+	}
+
+	if retErr = checkPacketPayloadLength(p, targetPacketSize-written); retErr != nil {
+		return 0, retErr
+	}
+
+	if p.Header.HasPayload {
+		retErr = w.Write(p.Payload)
+		if retErr != nil {
+			return
+		}
+		written += len(p.Payload)
+	}
+
+	for written < targetPacketSize {
+		if retErr = w.Write(uint8(0xff)); retErr != nil {
+			return
+		}
+		written++
+	}
+
+	return written, nil
+}
+
+// writePacketHeaderAndAdaptationField writes the sync byte, the header and the adaptation field of a packet
+func writePacketHeaderAndAdaptationField(w *astikit.BitsWriter, p *Packet) (written int, retErr error) {
 	if retErr = w.Write(uint8(syncByte)); retErr != nil {
 		return
 	}
@@ -334,31 +380,19 @@ func writePacket(w *astikit.BitsWriter, p *Packet, targetPacketSize int) (writte
 		}
 		written += n
 	}
+	return
+}
 
-	if targetPacketSize-written < len(p.Payload) {
-		return 0, fmt.Errorf(
+// checkPacketPayloadLength checks whether the payload of a packet fits in the bytes available
+func checkPacketPayloadLength(p *Packet, bytesAvailable int) error {
+	if bytesAvailable < len(p.Payload) {
+		return fmt.Errorf(
 			"writePacket: can't write %d bytes of payload: only %d is available",
 			len(p.Payload),
-			targetPacketSize-written,
+			bytesAvailable,
 		)
 	}
-
-	if p.Header.HasPayload {
-		retErr = w.Write(p.Payload)
-		if retErr != nil {
-			return
-		}
-		written += len(p.Payload)
-	}
-
-	for written < targetPacketSize {
-		if retErr = w.Write(uint8(0xff)); retErr != nil {
-			return
-		}
-		written++
-	}
-
-	return written, nil
+	return nil
 }
 
 func writePacketHeader(w *astikit.BitsWriter, h *PacketHeader) (written int, retErr error) {
@@ -376,11 +410,11 @@ func writePacketHeader(w *astikit.BitsWriter, h *PacketHeader) (written int, ret
 	return mpegTsPacketHeaderSize, b.Err()
 }
 
-func writePCR(w *astikit.BitsWriter, cr *ClockReference) (int, error) {
+func writePCR(w *astikit.BitsWriter, cr *ClockReference, rb *ReservedBits) (int, error) {
 	b := astikit.NewBitsWriterBatch(w)
 
 	b.WriteN(uint64(cr.Base), 33)
-	b.WriteN(uint8(0xff), 6)
+	b.WriteN(rb.Reserved, 6)
 	b.WriteN(uint64(cr.Extension), 9)
 	return pcrBytesSize, b.Err()
 }
@@ -414,6 +448,7 @@ func writePacketAdaptationField(w *astikit.BitsWriter, af *PacketAdaptationField
 		return 1, nil
 	}
 
+	rb := af.ReservedBits.orDefault()
 	length := calcPacketAdaptationFieldLength(af)
 	b.Write(length)
 	bytesWritten++
@@ -430,7 +465,7 @@ func writePacketAdaptationField(w *astikit.BitsWriter, af *PacketAdaptationField
 	bytesWritten++
 
 	if af.HasPCR {
-		n, err := writePCR(w, af.PCR)
+		n, err := writePCR(w, af.PCR, rb)
 		if err != nil {
 			return 0, err
 		}
@@ -438,7 +473,7 @@ func writePacketAdaptationField(w *astikit.BitsWriter, af *PacketAdaptationField
 	}
 
 	if af.HasOPCR {
-		n, err := writePCR(w, af.OPCR)
+		n, err := writePCR(w, af.OPCR, rb)
 		if err != nil {
 			return 0, err
 		}
@@ -461,7 +496,7 @@ func writePacketAdaptationField(w *astikit.BitsWriter, af *PacketAdaptationField
 	}
 
 	if af.HasAdaptationExtensionField {
-		n, err := writePacketAdaptationFieldExtension(w, af.AdaptationExtensionField)
+		n, err := writePacketAdaptationFieldExtension(w, af.AdaptationExtensionField, rb)
 		if err != nil {
 			return 0, err
 		}
@@ -492,7 +527,7 @@ func calcPacketAdaptationFieldExtensionLength(afe *PacketAdaptationExtensionFiel
 	return length
 }
 
-func writePacketAdaptationFieldExtension(w *astikit.BitsWriter, afe *PacketAdaptationExtensionField) (bytesWritten int, retErr error) {
+func writePacketAdaptationFieldExtension(w *astikit.BitsWriter, afe *PacketAdaptationExtensionField, rb *ReservedBits) (bytesWritten int, retErr error) {
 	b := astikit.NewBitsWriterBatch(w)
 
 	length := calcPacketAdaptationFieldExtensionLength(afe)
@@ -502,7 +537,7 @@ func writePacketAdaptationFieldExtension(w *astikit.BitsWriter, afe *PacketAdapt
 	b.Write(afe.HasLegalTimeWindow)
 	b.Write(afe.HasPiecewiseRate)
 	b.Write(afe.HasSeamlessSplice)
-	b.WriteN(uint8(0xff), 5) // reserved
+	b.WriteN(rb.Reserved, 5)
 	bytesWritten++
 
 	if afe.HasLegalTimeWindow {
@@ -512,13 +547,13 @@ func writePacketAdaptationFieldExtension(w *astikit.BitsWriter, afe *PacketAdapt
 	}
 
 	if afe.HasPiecewiseRate {
-		b.WriteN(uint8(0xff), 2)
+		b.WriteN(rb.Reserved, 2)
 		b.WriteN(afe.PiecewiseRate, 22)
 		bytesWritten += 3
 	}
 
 	if afe.HasSeamlessSplice {
-		n, err := writePTSOrDTS(w, afe.SpliceType, afe.DTSNextAccessUnit)
+		n, err := writePTSOrDTS(w, afe.SpliceType, afe.DTSNextAccessUnit, rb)
 		if err != nil {
 			return 0, err
 		}
@@ -10,9 +10,12 @@ import (
 
 // packetBuffer represents a packet buffer
 type packetBuffer struct {
+	keepRaw          bool // Whether packets keep a copy of their raw bytes
 	packetSize       int
 	r                io.Reader
 	packetReadBuffer []byte
+	recover          bool          // Whether corrupt packets are dropped and sync bytes looked for instead of failing
+	stats            *DemuxerStats // Updated when recovering
 }
 
 // newPacketBuffer creates a new packet buffer
@@ -36,10 +39,11 @@ func newPacketBuffer(r io.Reader, packetSize int) (pb *packetBuffer, err error)
 
 // autoDetectPacketSize updates the packet size based on the first bytes
 // Minimum packet size is 188 and is bounded by 2 sync bytes
-// Assumption is made that the first byte of the reader is a sync byte
+// Assumption is made that the first byte of the reader is a sync byte, unless packets are 192 bytes M2TS packets
+// starting with their 4 bytes TP_extra_header
 func autoDetectPacketSize(r io.Reader) (packetSize int, err error) {
 	// Read first bytes
-	const l = 193
+	const l = MpegTsPacketSize + 2*m2tsHeaderSize + 1
 	var b = make([]byte, l)
 	shouldRewind, rerr := peek(r, b)
 	if rerr != nil {
@@ -47,38 +51,45 @@ func autoDetectPacketSize(r io.Reader) (packetSize int, err error) {
 		return
 	}
 
-	// Packet must start with a sync byte
-	if b[0] != syncByte {
+	switch {
+	case b[0] == syncByte && b[MpegTsPacketSize] == syncByte:
+		packetSize = MpegTsPacketSize
+	case b[m2tsHeaderSize] == syncByte && b[m2tsHeaderSize+MpegTsPacketSize+m2tsHeaderSize] == syncByte:
+		packetSize = MpegTsPacketSize + m2tsHeaderSize
+	case b[0] == syncByte:
+		// Look for sync bytes
+		for idx, b := range b {
+			if b == syncByte && idx >= MpegTsPacketSize {
+				packetSize = idx
+				break
+			}
+		}
+		if packetSize == 0 {
+			err = fmt.Errorf("astits: only one sync byte detected in first %d bytes", l)
+			return
+		}
+	default:
+		// Packet must start with a sync byte
 		err = ErrPacketMustStartWithASyncByte
 		return
 	}
 
-	// Look for sync bytes
-	for idx, b := range b {
-		if b == syncByte && idx >= MpegTsPacketSize {
-			// Update packet size
-			packetSize = idx
-
-			if !shouldRewind {
-				return
-			}
+	if !shouldRewind {
+		return
+	}
 
-			// Rewind or sync reader
-			var n int64
-			if n, err = rewind(r); err != nil {
-				err = fmt.Errorf("astits: rewinding failed: %w", err)
-				return
-			} else if n == -1 {
-				var ls = packetSize - (l - packetSize)
-				if _, err = r.Read(make([]byte, ls)); err != nil {
-					err = fmt.Errorf("astits: reading %d bytes to sync reader failed: %w", ls, err)
-					return
-				}
-			}
+	// Rewind or sync reader
+	var n int64
+	if n, err = rewind(r); err != nil {
+		err = fmt.Errorf("astits: rewinding failed: %w", err)
+		return
+	} else if n == -1 {
+		var ls = packetSize - (l - packetSize)
+		if _, err = io.ReadFull(r, make([]byte, ls)); err != nil {
+			err = fmt.Errorf("astits: reading %d bytes to sync reader failed: %w", ls, err)
 			return
 		}
 	}
-	err = fmt.Errorf("astits: only one sync byte detected in first %d bytes", l)
 	return
 }
 
@@ -96,7 +107,11 @@ func peek(r io.Reader, b []byte) (shouldRewind bool, err error) {
 		return false, nil
 	}
 
-	_, err = r.Read(b)
+	// Reads may return fewer bytes than requested, which is common with sockets
+	// Streams shorter than b are left to the caller to deal with
+	if _, err = io.ReadFull(r, b); err == io.ErrUnexpectedEOF {
+		err = nil
+	}
 	shouldRewind = true
 	return
 }
@@ -120,20 +135,70 @@ func (pb *packetBuffer) next() (p *Packet, err error) {
 	if pb.packetReadBuffer == nil || len(pb.packetReadBuffer) != pb.packetSize {
 		pb.packetReadBuffer = make([]byte, pb.packetSize)
 	}
+	if err = pb.read(pb.packetReadBuffer); err != nil {
+		return
+	}
+
+	resyncing := false
+	for {
+		// Parse packet
+		if p, err = parsePacket(astikit.NewBytesIterator(pb.packetReadBuffer)); err == nil {
+			break
+		} else if !pb.recover {
+			err = fmt.Errorf("astits: building packet failed: %w", err)
+			return
+		}
+
+		// Packet is aligned but corrupt, or the reader needs to be resynchronized on the next sync byte
+		n := len(pb.packetReadBuffer)
+		if pb.packetReadBuffer[pb.syncByteOffset()] == syncByte {
+			pb.stats.CorruptPackets++
+		} else {
+			// Garbage spanning several packets counts as a single resync
+			if !resyncing {
+				pb.stats.Resyncs++
+				resyncing = true
+			}
+			for n = 1; n < len(pb.packetReadBuffer)-pb.syncByteOffset(); n++ {
+				if pb.packetReadBuffer[n+pb.syncByteOffset()] == syncByte {
+					break
+				}
+			}
+			pb.stats.SkippedBytes += int64(n)
+		}
+
+		// Read missing bytes
+		copy(pb.packetReadBuffer, pb.packetReadBuffer[n:])
+		if err = pb.read(pb.packetReadBuffer[len(pb.packetReadBuffer)-n:]); err != nil {
+			return
+		}
+	}
 
-	if _, err = io.ReadFull(pb.r, pb.packetReadBuffer); err != nil {
+	// Read buffer is reused, raw bytes must be copied
+	if pb.keepRaw {
+		p.Raw = make([]byte, len(pb.packetReadBuffer))
+		copy(p.Raw, pb.packetReadBuffer)
+	}
+	return
+}
+
+// read reads exactly len(b) bytes
+func (pb *packetBuffer) read(b []byte) (err error) {
+	if _, err = io.ReadFull(pb.r, b); err != nil {
 		if err == io.EOF || err == io.ErrUnexpectedEOF {
 			err = ErrNoMorePackets
 		} else {
-			err = fmt.Errorf("astits: reading %d bytes failed: %w", pb.packetSize, err)
+			err = fmt.Errorf("astits: reading %d bytes failed: %w", len(b), err)
 		}
-		return
 	}
+	return
+}
 
-	// Parse packet
-	if p, err = parsePacket(astikit.NewBytesIterator(pb.packetReadBuffer)); err != nil {
-		err = fmt.Errorf("astits: building packet failed: %w", err)
-		return
+// syncByteOffset returns the offset of the sync byte in packets, which follows the 4 bytes TP_extra_header of 192
+// bytes M2TS packets
+func (pb *packetBuffer) syncByteOffset() int {
+	if pb.packetSize == MpegTsPacketSize+m2tsHeaderSize {
+		return m2tsHeaderSize
 	}
-	return
+	return 0
 }
@@ -3,6 +3,7 @@ package astits
 import (
 	"bytes"
 	"testing"
+	"testing/iotest"
 
 	"github.com/asticode/go-astikit"
 	"github.com/stretchr/testify/assert"
@@ -31,4 +32,36 @@ func TestAutoDetectPacketSize(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, MpegTsPacketSize, p)
 	assert.Equal(t, 380, r.Len())
+
+	// M2TS packets start with a 4 bytes TP_extra_header, which may contain a sync byte
+	buf.Reset()
+	for i := 0; i < 2; i++ {
+		w.Write([]byte{syncByte, 0, 0, 0})
+		w.Write(byte(syncByte))
+		w.Write(make([]byte, 187))
+	}
+	p, err = autoDetectPacketSize(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize+m2tsHeaderSize, p)
+}
+
+func TestPacketBufferPartialReads(t *testing.T) {
+	// Reader returns 1 byte at a time and can't be rewinded
+	bs1, _ := packet(*packetHeader, *packetAdaptationField, []byte("1"), false)
+	bs2, _ := packet(*packetHeader, *packetAdaptationField, []byte("2"), false)
+	bs3, ep3 := packet(*packetHeader, *packetAdaptationField, []byte("3"), false)
+	r := iotest.OneByteReader(bytes.NewReader(bytes.Join([][]byte{bs1, bs2, bs3, bs3[:100]}, nil)))
+
+	pb, err := newPacketBuffer(r, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize, pb.packetSize)
+
+	// First packets are consumed by the packet size auto detection
+	p, err := pb.next()
+	assert.NoError(t, err)
+	assert.Equal(t, ep3, p)
+
+	// Truncated packet
+	_, err = pb.next()
+	assert.Equal(t, ErrNoMorePackets, err)
 }
@@ -45,6 +45,10 @@ func (b *packetPool) add(p *Packet) (ps []*Packet) {
 
 	// Empty buffer if we detect a discontinuity
 	if hasDiscontinuity(mps, p) {
+		// A signaled discontinuity starting a new payload unit means the buffered one is complete
+		if len(mps) > 0 && p.Header.PayloadUnitStartIndicator && p.Header.HasAdaptationField && p.AdaptationField.DiscontinuityIndicator {
+			ps = mps
+		}
 		mps = []*Packet{}
 	}
 
@@ -38,6 +38,10 @@ func TestPacketPool(t *testing.T) {
 	assert.Len(t, ps, 0)
 	ps = b.add(&Packet{Header: &PacketHeader{ContinuityCounter: 7, HasPayload: true, PID: 1}})
 	assert.Len(t, ps, 0)
+	ps = b.add(&Packet{AdaptationField: &PacketAdaptationField{DiscontinuityIndicator: true}, Header: &PacketHeader{ContinuityCounter: 2, HasAdaptationField: true, HasPayload: true, PayloadUnitStartIndicator: true, PID: 1}})
+	assert.Len(t, ps, 2)
+	ps = b.add(&Packet{Header: &PacketHeader{ContinuityCounter: 3, HasPayload: true, PID: 1}})
+	assert.Len(t, ps, 0)
 	ps = b.dump()
 	assert.Len(t, ps, 2)
 	assert.Equal(t, uint16(1), ps[0].Header.PID)
@@ -234,7 +234,7 @@ func TestParsePCR(t *testing.T) {
 func TestWritePCR(t *testing.T) {
 	buf := &bytes.Buffer{}
 	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
-	bytesWritten, err := writePCR(w, pcr)
+	bytesWritten, err := writePCR(w, pcr, defaultReservedBits)
 	assert.NoError(t, err)
 	assert.Equal(t, bytesWritten, 6)
 	assert.Equal(t, bytesWritten, buf.Len())
@@ -249,7 +249,7 @@ func BenchmarkWritePCR(b *testing.B) {
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		buf.Reset()
-		writePCR(w, pcr)
+		writePCR(w, pcr, defaultReservedBits)
 	}
 }
 
@@ -261,3 +261,23 @@ func BenchmarkParsePacket(b *testing.B) {
 		parsePacket(astikit.NewBytesIterator(bs))
 	}
 }
+
+func TestWritePacketAdaptationFieldReservedBits(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	_, err := writePacketAdaptationField(w, &PacketAdaptationField{
+		HasPCR:       true,
+		PCR:          pcr,
+		ReservedBits: &ReservedBits{MarkerBit: true, PESMarkerBits: 0b10},
+	})
+	assert.NoError(t, err)
+
+	bufExpected := &bytes.Buffer{}
+	wExpected := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: bufExpected})
+	wExpected.Write(uint8(7))                            // Length
+	wExpected.Write("00010000")                          // Flags
+	wExpected.Write("101010101010101010101010101010101") // Base
+	wExpected.Write("000000")                            // Reserved
+	wExpected.Write("101010101")                         // Extension
+	assert.Equal(t, bufExpected.Bytes(), buf.Bytes())
+}
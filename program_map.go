@@ -1,11 +1,15 @@
 package astits
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 // programMap represents a program ids map
 type programMap struct {
 	m *sync.Mutex
 	p map[uint16]uint16 // map[ProgramMapID]ProgramNumber
+	s map[uint16]bool   // elementary stream PIDs carrying sections instead of PES
 }
 
 // newProgramMap creates a new program ids map
@@ -13,6 +17,7 @@ func newProgramMap() programMap {
 	return programMap{
 		m: &sync.Mutex{},
 		p: make(map[uint16]uint16),
+		s: make(map[uint16]bool),
 	}
 }
 
@@ -31,6 +36,20 @@ func (m programMap) set(pid, number uint16) {
 	m.p[pid] = number
 }
 
+// setSections marks the elementary stream pid as carrying sections instead of PES
+func (m programMap) setSections(pid uint16) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.s[pid] = true
+}
+
+// carriesSections checks whether the elementary stream pid carries sections instead of PES
+func (m programMap) carriesSections(pid uint16) bool {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return m.s[pid]
+}
+
 func (m programMap) unset(pid uint16) {
 	m.m.Lock()
 	defer m.m.Unlock()
@@ -53,5 +72,10 @@ func (m programMap) toPATData() *PATData {
 		})
 	}
 
+	// map iteration order is random, sort programs so that the PAT is stable
+	sort.Slice(d.Programs, func(i, j int) bool {
+		return d.Programs[i].ProgramNumber < d.Programs[j].ProgramNumber
+	})
+
 	return d
 }
@@ -0,0 +1,27 @@
+package astits
+
+// ReservedBits represents the values written in the marker and reserved bits of PES headers and adaptation fields.
+// Overriding spec values is only useful to generate non conformant streams, e.g. to test analyzers.
+type ReservedBits struct {
+	MarkerBit     bool  // Written in every marker_bit, spec value is true
+	PESMarkerBits uint8 // 2 bits starting the PES optional header, spec value is 0b10
+	Reserved      uint8 // Lowest bits are written in every reserved field, spec value is 0xff
+}
+
+// NewReservedBits creates reserved bits with spec values
+func NewReservedBits() *ReservedBits {
+	return &ReservedBits{
+		MarkerBit:     true,
+		PESMarkerBits: 0b10,
+		Reserved:      0xff,
+	}
+}
+
+var defaultReservedBits = NewReservedBits()
+
+func (rb *ReservedBits) orDefault() *ReservedBits {
+	if rb == nil {
+		return defaultReservedBits
+	}
+	return rb
+}
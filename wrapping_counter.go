@@ -20,3 +20,11 @@ func (c *wrappingCounter) get() int {
 	}
 	return ret
 }
+
+// returns the last value returned by get without incrementing internal value
+func (c *wrappingCounter) last() int {
+	if c.value == 0 {
+		return c.wrapAt
+	}
+	return c.value - 1
+}